@@ -0,0 +1,201 @@
+package main
+
+// epdtest runs a UCI engine on EPD test suites and counts the positions it
+// solves, eg: epdtest -engine ./engine -movetime 1s wac.epd sts1.epd
+// A position is solved when the engine's move is one of the bm moves and
+// none of the am moves
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kahnaisehC/chessboard/pkg/epd"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code, so that the engine is stopped before exiting
+func run() int {
+	enginePath := flag.String("engine", "", "UCI engine to test")
+	movetime := flag.Duration("movetime", time.Second, "time to search every position")
+	verbose := flag.Bool("v", false, "print every position")
+	flag.Parse()
+	if *enginePath == "" || flag.NArg() == 0 {
+		fmt.Println("usage: epdtest -engine path [-movetime 1s] [-v] SUITE.epd...")
+		return 2
+	}
+
+	e, err := startEngine(*enginePath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	defer e.quit()
+
+	solved, total := 0, 0
+	for _, name := range flag.Args() {
+		positions, err := readSuite(name)
+		if err != nil {
+			fmt.Println(name + ": " + err.Error())
+			return 1
+		}
+		suiteSolved := 0
+		for i, p := range positions {
+			move, err := e.bestMove(p.Board.GetFEN(), *movetime)
+			if err != nil {
+				fmt.Println(name + ": " + err.Error())
+				return 1
+			}
+			ok := p.Solved(move)
+			if ok {
+				suiteSolved++
+			}
+			if *verbose {
+				id := p.ID
+				if id == "" {
+					id = "#" + strconv.Itoa(i+1)
+				}
+				status := "failed"
+				if ok {
+					status = "solved"
+				}
+				expected := "bm " + strings.Join(p.BestMoves, " ")
+				if len(p.AvoidMoves) > 0 {
+					expected += " am " + strings.Join(p.AvoidMoves, " ")
+				}
+				fmt.Printf("%-12s %s %-7s %s\n", id, status, move, expected)
+			}
+		}
+		fmt.Printf("%s: solved %d/%d\n", name, suiteSolved, len(positions))
+		solved += suiteSolved
+		total += len(positions)
+	}
+	if flag.NArg() > 1 {
+		fmt.Printf("total: solved %d/%d\n", solved, total)
+	}
+	return 0
+}
+
+func readSuite(name string) ([]epd.Position, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return epd.Read(f)
+}
+
+// engine talks UCI to an engine process
+type engine struct {
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	lines chan string // closed when the engine exits
+}
+
+func startEngine(path string) (*engine, error) {
+	cmd := exec.Command(path)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e := &engine{cmd: cmd, in: in, lines: make(chan string, 64)}
+	go func() {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			e.lines <- scanner.Text()
+		}
+		close(e.lines)
+	}()
+
+	e.send("uci")
+	if _, err := e.waitFor("uciok", 10*time.Second); err != nil {
+		e.quit()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *engine) send(command string) {
+	io.WriteString(e.in, command+"\n")
+}
+
+// waitFor returns the first line that starts with prefix
+func (e *engine) waitFor(prefix string, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return "", errors.New("the engine exited")
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, nil
+			}
+		case <-timer.C:
+			return "", errors.New("the engine didn't answer " + prefix + " in time")
+		}
+	}
+}
+
+// bestMove searches the position for movetime and returns the move in UCI
+func (e *engine) bestMove(fen string, movetime time.Duration) (string, error) {
+	e.send("ucinewgame")
+	e.send("isready")
+	if _, err := e.waitFor("readyok", 10*time.Second); err != nil {
+		return "", err
+	}
+	e.send("position fen " + fen)
+	e.send("go movetime " + strconv.FormatInt(movetime.Milliseconds(), 10))
+
+	// engines overrun a little, give them some slack before stopping them
+	line, err := e.waitFor("bestmove", movetime+5*time.Second)
+	if err != nil {
+		e.send("stop")
+		if line, err = e.waitFor("bestmove", 5*time.Second); err != nil {
+			return "", err
+		}
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", errors.New("bestmove without a move")
+	}
+	return fields[1], nil
+}
+
+// quit asks the engine to quit, and kills it if it is still running after a second
+func (e *engine) quit() {
+	e.send("quit")
+	e.in.Close()
+	done := make(chan struct{})
+	go func() {
+		// Wait closes stdout, the scanner has to read everything first
+		for range e.lines {
+		}
+		e.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		e.cmd.Process.Kill()
+		<-done
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+// explorer builds an opening explorer index of PGN collections and queries it:
+//
+//	explorer build [-maxply 30] club.idx games.pgn more.pgn
+//	explorer query club.idx e4 c5 Nf3
+//	explorer query -fen "rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2" club.idx
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/explorer"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "build":
+		err = build(os.Args[2:])
+	case "query":
+		err = query(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println("usage: explorer build [-maxply plies] INDEX PGN...")
+	fmt.Println("       explorer query [-fen FEN] INDEX [MOVE...]")
+	os.Exit(2)
+}
+
+func build(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	maxPly := flags.Int("maxply", 30, "plies of every game to index, 0 for all")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		usage()
+	}
+
+	builder := explorer.NewBuilder(*maxPly)
+	for _, name := range flags.Args()[1:] {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = builder.AddPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return builder.Save(flags.Arg(0))
+}
+
+func query(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	fen := flags.String("fen", "", "position to query, the initial position if empty")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		usage()
+	}
+
+	if *fen != "" {
+		if ok, logs := chessboard.ValidateFEN(*fen); !ok {
+			return fmt.Errorf("invalid FEN: %s", logs)
+		}
+	}
+	board := chessboard.CreateChessboard(*fen)
+	for _, move := range flags.Args()[1:] {
+		if err := board.MakeSANMove(move); err != nil {
+			if err := board.MakeUCIMove(move); err != nil {
+				return err
+			}
+		}
+	}
+
+	index, err := explorer.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	moves, err := index.Moves(&board)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(board.GetFEN())
+	if len(moves) == 0 {
+		fmt.Println("no games")
+		return nil
+	}
+	fmt.Printf("%-8s %6s %6s %6s %6s %6s %6s\n", "move", "games", "white", "draws", "black", "score", "rating")
+	for _, m := range moves {
+		fmt.Printf("%-8s %6d %6d %6d %6d %5.0f%% %6d\n", m.SAN, m.Games, m.WhiteWins, m.Draws, m.BlackWins, 100*m.Score(), m.AverageRating)
+		for _, id := range m.Examples {
+			g, err := index.Game(id)
+			if err != nil {
+				return err
+			}
+			line := player(g.White, g.WhiteElo) + " - " + player(g.Black, g.BlackElo) + " " + g.Result
+			for _, s := range []string{g.Event, g.Date} {
+				if s != "" && s != "?" && s != "????.??.??" {
+					line += ", " + s
+				}
+			}
+			fmt.Println("         " + line)
+		}
+	}
+	return nil
+}
+
+func player(name string, elo int) string {
+	if name == "" {
+		name = "?"
+	}
+	if elo > 0 {
+		return fmt.Sprintf("%s (%d)", name, elo)
+	}
+	return name
+}
@@ -0,0 +1,38 @@
+package main
+
+// server plays games over HTTP, eg: server -addr localhost:8080 -data games
+// Games are journaled in the data directory, the ones in progress are
+// restored when the server starts again
+//
+//	POST /games              {"fen", "variant", "timeControl", "white", "black"}, all optional
+//	GET  /games              stored games, ?player=Ann&result=1-0&from=2024.01.01&to=2024.12.31
+//	GET  /games/{id}         the game as JSON
+//	GET  /games/{id}/fen     the position as FEN
+//	GET  /games/{id}/pgn     the game as PGN
+//	POST /games/{id}/moves   {"move": "e2e4"}, UCI or SAN
+//	GET  /games/{id}/ws      a WebSocket that pushes moves and clock ticks
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/kahnaisehC/chessboard/pkg/storage"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	data := flag.String("data", "games", "directory to keep the games in")
+	flag.Parse()
+
+	store, err := storage.NewFileStorage(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := newServer(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/clock"
+	"github.com/kahnaisehC/chessboard/pkg/eco"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+	"github.com/kahnaisehC/chessboard/pkg/storage"
+)
+
+// tickInterval is how often clock times are pushed to the WebSockets
+const tickInterval = time.Second
+
+type server struct {
+	mux   *http.ServeMux
+	store storage.Storage
+
+	mu    sync.Mutex
+	games map[string]*game // games in progress, and the ones finished since the server started
+}
+
+type game struct {
+	*chessboard.Game
+	header storage.Header
+	// moveMu keeps the journal in the order of the moves
+	moveMu sync.Mutex
+}
+
+type createRequest struct {
+	FEN         string `json:"fen"`
+	Variant     string `json:"variant"`
+	TimeControl string `json:"timeControl"` // like the PGN tag, "300+2"
+	White       string `json:"white"`
+	Black       string `json:"black"`
+}
+
+type moveRequest struct {
+	Move string `json:"move"`
+}
+
+type gameState struct {
+	ID        string   `json:"id"`
+	Variant   string   `json:"variant"`
+	FEN       string   `json:"fen"`
+	PGN       string   `json:"pgn"`
+	Moves     []string `json:"moves"`
+	Turn      string   `json:"turn"`
+	Result    string   `json:"result"`
+	WhiteTime int64    `json:"whiteTime,omitempty"` // milliseconds
+	BlackTime int64    `json:"blackTime,omitempty"`
+}
+
+// message is an event pushed to the WebSockets
+type message struct {
+	Type      string `json:"type"`
+	White     bool   `json:"white,omitempty"`
+	SAN       string `json:"san,omitempty"`
+	UCI       string `json:"uci,omitempty"`
+	FEN       string `json:"fen,omitempty"`
+	Ply       int    `json:"ply,omitempty"`
+	Result    string `json:"result,omitempty"`
+	WhiteTime int64  `json:"whiteTime,omitempty"`
+	BlackTime int64  `json:"blackTime,omitempty"`
+
+	State *gameState `json:"state,omitempty"`
+}
+
+var eventNames = map[chessboard.EventType]string{
+	chessboard.MOVEMADE:        "move",
+	chessboard.CHECK:           "check",
+	chessboard.DRAWOFFER:       "drawOffer",
+	chessboard.TAKEBACKREQUEST: "takebackRequest",
+	chessboard.TAKEBACK:        "takeback",
+	chessboard.GAMEOVER:        "gameOver",
+	chessboard.CLOCKTICK:       "clock",
+}
+
+var upgrader = websocket.Upgrader{
+	// players and spectators may come from any page
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// newServer serves the games of store, and plays on the ones in progress
+func newServer(store storage.Storage) (*server, error) {
+	s := &server{mux: http.NewServeMux(), store: store, games: map[string]*game{}}
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+	s.mux.HandleFunc("POST /games", s.createGame)
+	s.mux.HandleFunc("GET /games", s.listGames)
+	s.mux.HandleFunc("GET /games/{id}", s.getGame)
+	s.mux.HandleFunc("GET /games/{id}/fen", s.getFEN)
+	s.mux.HandleFunc("GET /games/{id}/pgn", s.getPGN)
+	s.mux.HandleFunc("POST /games/{id}/moves", s.postMove)
+	s.mux.HandleFunc("GET /games/{id}/ws", s.watch)
+	return s, nil
+}
+
+// restore replays the journals of the games in progress
+func (s *server) restore() error {
+	headers, err := s.store.List(storage.Query{Result: chessboard.ONGOING.String()})
+	if err != nil {
+		return err
+	}
+	for _, header := range headers {
+		journal, err := s.store.Load(header.ID)
+		if err != nil {
+			return err
+		}
+		board, err := journal.Replay()
+		if err != nil {
+			return err
+		}
+		s.play(header, board)
+		log.Println("restored game", header.ID, "after", len(board.Moves), "moves")
+	}
+	return nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *server) createGame(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	// an empty body is a standard game
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch _, known := chessboard.VariantByName(req.Variant); {
+	case req.Variant == "Chess960" && req.FEN == "":
+		// the journal keeps the position that was drawn
+		req.FEN, _ = chessboard.Chess960FEN(rand.IntN(960))
+	case req.Variant != "" && req.Variant != "Chess960" && !known:
+		http.Error(w, "unknown variant "+req.Variant+", known: Chess960, "+strings.Join(chessboard.Variants(), ", "), http.StatusBadRequest)
+		return
+	}
+	header := storage.Header{
+		ID:          storage.NewID(),
+		Variant:     req.Variant,
+		FEN:         req.FEN,
+		TimeControl: req.TimeControl,
+		Created:     time.Now(),
+	}
+	header.Tags.White, header.Tags.Black = req.White, req.Black
+	header.Tags.Date = header.Created.Format("2006.01.02")
+	header.Tags.Result = chessboard.ONGOING.String()
+
+	board, err := header.Board()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.Create(header); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g := s.play(header, board)
+
+	w.Header().Set("Location", "/games/"+header.ID)
+	writeJSON(w, http.StatusCreated, g.state())
+}
+
+// play starts serving a game, board is its current position
+func (s *server) play(header storage.Header, board chessboard.Chessboard) *game {
+	g := &game{Game: chessboard.NewGame(board), header: header}
+	stopClock := func() {}
+	if board.Clock != nil {
+		stopClock = g.RunClock(tickInterval)
+	}
+	// BLOCK, the result has to reach the journal
+	g.OnEvent(func(event chessboard.Event) {
+		if event.Type == chessboard.GAMEOVER {
+			stopClock()
+			if err := s.store.Finish(header.ID, event.Result); err != nil {
+				log.Println("game", header.ID, "saving the result:", err)
+			}
+		}
+	}, 16, chessboard.BLOCK)
+	if result := board.GetResult(); result != chessboard.ONGOING {
+		// the result was lost with the server
+		stopClock()
+		s.store.Finish(header.ID, result)
+	}
+
+	s.mu.Lock()
+	s.games[header.ID] = g
+	s.mu.Unlock()
+	return g
+}
+
+func (s *server) game(w http.ResponseWriter, r *http.Request) *game {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	g := s.games[id]
+	s.mu.Unlock()
+	if g == nil {
+		if _, err := s.store.Load(id); err == nil {
+			// finished before the server started
+			http.Error(w, "the game is over", http.StatusConflict)
+		} else {
+			http.Error(w, "no game "+id, http.StatusNotFound)
+		}
+	}
+	return g
+}
+
+// listGames returns the headers of the stored games, filtered by the query
+// parameters player, result, from and to, dates like "2024.03.01"
+func (s *server) listGames(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := storage.Query{Player: params.Get("player"), Result: params.Get("result")}
+	for _, bound := range []struct {
+		name string
+		date *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		if value := params.Get(bound.name); value != "" {
+			date, err := time.Parse("2006.01.02", value)
+			if err != nil {
+				http.Error(w, "invalid date "+value+", should be like 2024.03.01", http.StatusBadRequest)
+				return
+			}
+			*bound.date = date
+		}
+	}
+	headers, err := s.store.List(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if headers == nil {
+		headers = []storage.Header{}
+	}
+	writeJSON(w, http.StatusOK, headers)
+}
+
+// view returns the state of any stored game. The games that aren't being
+// served, finished before the server started, are replayed from their journal
+func (s *server) view(w http.ResponseWriter, r *http.Request) *gameState {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	g := s.games[id]
+	s.mu.Unlock()
+	if g != nil {
+		return g.state()
+	}
+
+	journal, err := s.store.Load(id)
+	if err != nil {
+		http.Error(w, "no game "+id, http.StatusNotFound)
+		return nil
+	}
+	board, err := journal.Replay()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	if board.Clock != nil {
+		// the times of the last move
+		board.Clock.Stop()
+	}
+	return newState(journal.Header, board, board.PGNTags.Result)
+}
+
+func (s *server) getGame(w http.ResponseWriter, r *http.Request) {
+	if state := s.view(w, r); state != nil {
+		writeJSON(w, http.StatusOK, state)
+	}
+}
+
+func (s *server) getFEN(w http.ResponseWriter, r *http.Request) {
+	if state := s.view(w, r); state != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(state.FEN + "\n"))
+	}
+}
+
+func (s *server) getPGN(w http.ResponseWriter, r *http.Request) {
+	if state := s.view(w, r); state != nil {
+		w.Header().Set("Content-Type", "application/x-chess-pgn")
+		w.Write([]byte(state.PGN))
+	}
+}
+
+func (s *server) postMove(w http.ResponseWriter, r *http.Request) {
+	g := s.game(w, r)
+	if g == nil {
+		return
+	}
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g.moveMu.Lock()
+	if err := g.Move(req.Move); err != nil {
+		g.moveMu.Unlock()
+		status := http.StatusBadRequest
+		if err == clock.ErrFlagFell || g.Result() != chessboard.ONGOING {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	board := g.Snapshot()
+	err := s.store.Append(g.header.ID, storage.NewEntry(&board))
+	g.moveMu.Unlock()
+	if err != nil {
+		log.Println("game", g.header.ID, "saving a move:", err)
+		http.Error(w, "the move was played but not saved: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, g.state())
+}
+
+// watch pushes the state of the game, then every event, to a WebSocket.
+// Messages from the client are ignored
+func (s *server) watch(w http.ResponseWriter, r *http.Request) {
+	g := s.game(w, r)
+	if g == nil {
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already answered the request
+		return
+	}
+	defer conn.Close()
+
+	// a slow connection loses old clock ticks and moves, the FEN of the
+	// latest move is all it needs to catch up
+	events, unsubscribe := g.Subscribe(64, chessboard.DROPOLDEST)
+	defer unsubscribe()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(message{Type: "state", State: g.state()}); err != nil {
+		return
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(newMessage(event)); err != nil {
+				log.Println("game", g.header.ID, "websocket:", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func newMessage(event chessboard.Event) message {
+	m := message{
+		Type:      eventNames[event.Type],
+		White:     event.White,
+		SAN:       event.SAN,
+		UCI:       event.UCI,
+		FEN:       event.FEN,
+		Ply:       event.Ply,
+		WhiteTime: event.WhiteTime.Milliseconds(),
+		BlackTime: event.BlackTime.Milliseconds(),
+	}
+	if event.Type == chessboard.GAMEOVER {
+		m.Result = event.Result.String()
+	}
+	return m
+}
+
+func (g *game) state() *gameState {
+	return newState(g.header, g.Snapshot(), g.Result().String())
+}
+
+func newState(header storage.Header, board chessboard.Chessboard, result string) *gameState {
+	state := &gameState{
+		ID:      header.ID,
+		Variant: header.Variant,
+		FEN:     board.GetFEN(),
+		PGN:     pgn(board, result),
+		Moves:   board.Moves,
+		Turn:    "white",
+		Result:  result,
+	}
+	if state.Variant == "" {
+		state.Variant = "Standard"
+	}
+	if state.Moves == nil {
+		state.Moves = []string{}
+	}
+	if !board.WhiteToMove {
+		state.Turn = "black"
+	}
+	if board.Clock != nil {
+		state.WhiteTime = board.Clock.Remaining(chessboard.WHITE).Milliseconds()
+		state.BlackTime = board.Clock.Remaining(chessboard.BLACK).Milliseconds()
+	}
+	return state
+}
+
+// pgn writes the game with its result, which may not be on the board, and
+// its opening
+func pgn(board chessboard.Chessboard, result string) string {
+	// SetTags shouldn't change the tags of the game
+	board.PGNTags.Extra = append([]pgntags.Tag(nil), board.PGNTags.Extra...)
+	board.PGNTags.Result = result
+	eco.SetTags(&board.PGNTags, &board)
+	return board.GetPGN()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("writing response:", err)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// tbgen generates distance to mate tables, eg: tbgen -dir tables KBNK KPK
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kahnaisehC/chessboard/pkg/tablebase"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory to save the tables in")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Println("usage: tbgen [-dir directory] SIGNATURE...")
+		os.Exit(2)
+	}
+
+	tb := tablebase.New(*dir)
+	for _, signature := range flag.Args() {
+		table, err := tb.Generate(signature)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("generated", table.Signature)
+	}
+}
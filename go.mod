@@ -4,4 +4,7 @@ go 1.24.2
 
 replace github.com/kahnaisehC/chessboard => ./pkg/chessboard
 
-require github.com/kahnaisehC/chessboard v0.0.0-20250516185228-30787e13794b
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/kahnaisehC/chessboard v0.0.0-20250516185228-30787e13794b
+)
@@ -0,0 +1,61 @@
+package chessboard
+
+import "errors"
+
+// Antichess: capturing is compulsory and the side that runs out of pieces,
+// or of moves, wins. The king is an ordinary piece: there is no check, it can
+// be captured and pawns can promote to it. There is no castling.
+type Antichess struct{ Standard }
+
+func init() {
+	RegisterVariant(Antichess{})
+}
+
+func (Antichess) Name() string        { return "Antichess" }
+func (Antichess) StartingFEN() string { return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1" }
+
+func (Antichess) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	if ok, logs := validateFEN(FEN, false); !ok {
+		return "", errors.New(logs)
+	}
+	return FEN, nil
+}
+
+func (Antichess) PseudoLegalMoves(c *Chessboard, moves []Move) []Move {
+	var allowed []Move
+	for _, move := range moves {
+		if move.castling {
+			continue
+		}
+		allowed = append(allowed, move)
+		if move.promotion == pieceColor(WQUEEN, c.WhiteToMove) {
+			move.promotion = pieceColor(WKING, c.WhiteToMove)
+			allowed = append(allowed, move)
+		}
+	}
+	return allowed
+}
+
+func (Antichess) Legal(c *Chessboard, m Move, next *Chessboard) bool { return true }
+
+// FilterMoves keeps only the captures if there are any
+func (Antichess) FilterMoves(c *Chessboard, moves []Move) []Move {
+	var captures []Move
+	for _, move := range moves {
+		if captured, _ := c.capturedBy(move); captured != 0 {
+			captures = append(captures, move)
+		}
+	}
+	if len(captures) > 0 {
+		return captures
+	}
+	return moves
+}
+
+// Result: the side to move wins when it has nothing left to move
+func (Antichess) Result(c *Chessboard, moves []Move) Result {
+	if len(moves) > 0 {
+		return ONGOING
+	}
+	return winner(c.WhiteToMove)
+}
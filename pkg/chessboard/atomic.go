@@ -0,0 +1,70 @@
+package chessboard
+
+// Atomic: a capture explodes the capturing piece, the captured one and every
+// piece but pawns around them. Exploding the other king wins, a king can't
+// capture, and kings can stand next to each other: a king next to the other
+// king is not in check since capturing it would blow up both.
+type Atomic struct{ Standard }
+
+func init() {
+	RegisterVariant(Atomic{})
+}
+
+func (Atomic) Name() string { return "Atomic" }
+
+// Legal doesn't let a side blow up its own king, and lets it get away with
+// anything that blows up the other one
+func (Atomic) Legal(c *Chessboard, m Move, next *Chessboard) bool {
+	switch {
+	case next.GetKingPosition(c.WhiteToMove) == intToPair(64):
+		return false
+	case next.GetKingPosition(!c.WhiteToMove) == intToPair(64):
+		return true
+	}
+	return !atomicCheck(next, c.WhiteToMove)
+}
+
+func (Atomic) AfterMove(before *Chessboard, m Move, c *Chessboard) {
+	if captured, _ := before.capturedBy(m); captured == 0 {
+		return
+	}
+	c.erasePiece(m.to)
+	for _, offset := range kingMoves {
+		square := addPair(m.to, offset)
+		if piece := c.getPiece(square); piece != 0 && piece != WPAWN && piece != BPAWN {
+			c.erasePiece(square)
+		}
+	}
+	c.updateCastlingRights(0)
+}
+
+func (Atomic) FilterMoves(c *Chessboard, moves []Move) []Move {
+	if c.BoardState[WKING] == 0 || c.BoardState[BKING] == 0 {
+		return nil
+	}
+	return moves
+}
+
+func (Atomic) Result(c *Chessboard, moves []Move) Result {
+	switch {
+	case c.BoardState[WKING] == 0:
+		return BLACKWINS
+	case c.BoardState[BKING] == 0:
+		return WHITEWINS
+	case len(moves) > 0:
+		return ONGOING
+	case atomicCheck(c, c.WhiteToMove):
+		return winner(!c.WhiteToMove)
+	}
+	return DRAW
+}
+
+// atomicCheck reports if the king of color is in check, which it isn't
+// while it touches the other king
+func atomicCheck(c *Chessboard, color bool) bool {
+	king, other := c.GetKingPosition(color), c.GetKingPosition(!color)
+	if dc, dr := king.col-other.col, king.row-other.row; dc >= -1 && dc <= 1 && dr >= -1 && dr <= 1 {
+		return false
+	}
+	return c.SquareIsThreatened(!color, king)
+}
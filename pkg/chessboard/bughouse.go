@@ -0,0 +1,92 @@
+package chessboard
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Bughouse is one board of a bughouse game: crazyhouse, but captured pieces
+// go to the partner on the other board, see BughousePairing
+type Bughouse struct{ Crazyhouse }
+
+func init() {
+	RegisterVariant(Bughouse{})
+}
+
+func (Bughouse) Name() string { return "Bughouse" }
+
+func (Bughouse) AfterMove(before *Chessboard, m Move, c *Chessboard) {
+	trackPockets(before, m, c)
+}
+
+// BughousePairing is a bughouse game: white on one board and black on the
+// other play as a team. Captured pieces keep their color and go to the
+// pocket of the capturer's partner, promoted pieces as pawns
+type BughousePairing struct {
+	Boards [2]Chessboard
+}
+
+func NewBughousePairing() BughousePairing {
+	var pairing BughousePairing
+	for i := range pairing.Boards {
+		pairing.Boards[i], _ = CreateVariantChessboard(Bughouse{}, "")
+	}
+	return pairing
+}
+
+// MakeMove plays a move written in UCI notation on board 0 or 1
+func (b *BughousePairing) MakeMove(board int, uci string) error {
+	if board != 0 && board != 1 {
+		return errors.New("there is no board " + strconv.Itoa(board))
+	}
+	m, err := b.Boards[board].MoveFromUCI(uci)
+	if err != nil {
+		return err
+	}
+	return b.play(board, m)
+}
+
+// MakeSANMove plays a move written in Standard Algebraic Notation on board 0 or 1
+func (b *BughousePairing) MakeSANMove(board int, san string) error {
+	if board != 0 && board != 1 {
+		return errors.New("there is no board " + strconv.Itoa(board))
+	}
+	m, err := b.Boards[board].MoveFromSAN(san)
+	if err != nil {
+		return err
+	}
+	return b.play(board, m)
+}
+
+func (b *BughousePairing) play(board int, m Move) error {
+	c := &b.Boards[board]
+	captured, square := c.capturedBy(m)
+	if captured != 0 && c.Promoted&(1<<pairToInt(square)) != 0 {
+		captured = pieceColor(WPAWN, isWhite(captured))
+	}
+	if err := c.playMove(m); err != nil {
+		return err
+	}
+	if captured != 0 {
+		b.Boards[1-board].Pockets[captured]++
+	}
+	return nil
+}
+
+// GetResult returns the result of the first board that is over, ONGOING if
+// neither is. The winner's partner wins too.
+// TODO: a mate that a piece the partner may still send could block is
+// taken as mate, over the board players would wait
+func (b *BughousePairing) GetResult() (board int, result Result) {
+	for i := range b.Boards {
+		if result := b.Boards[i].GetResult(); result != ONGOING {
+			return i, result
+		}
+	}
+	return 0, ONGOING
+}
+
+// GetFEN returns the FENs of both boards, separated by " | " like BPGN does
+func (b *BughousePairing) GetFEN() string {
+	return b.Boards[0].GetFEN() + " | " + b.Boards[1].GetFEN()
+}
@@ -0,0 +1,160 @@
+package chessboard
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// squares of the two knights among the five left after the bishops and the
+// queen, in the order of the Scharnagl numbering
+var knightPlacements = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4},
+	{1, 2}, {1, 3}, {1, 4},
+	{2, 3}, {2, 4},
+	{3, 4},
+}
+
+// Chess960FEN returns the start position number index, 0 to 959, in the
+// Scharnagl numbering. 518 is the standard start position
+func Chess960FEN(index int) (string, error) {
+	if index < 0 || index >= 960 {
+		return "", errors.New("Chess960 index out of range: " + strconv.Itoa(index))
+	}
+
+	var row [8]byte
+	// place puts piece on the nth empty square
+	place := func(piece byte, nth int) {
+		for col := range row {
+			if row[col] != 0 {
+				continue
+			}
+			if nth == 0 {
+				row[col] = piece
+				return
+			}
+			nth--
+		}
+	}
+
+	n := index
+	row[2*(n%4)+1] = 'B' // light squared bishop
+	n /= 4
+	row[2*(n%4)] = 'B' // dark squared bishop
+	n /= 4
+	place('Q', n%6)
+	n /= 6
+	// the second knight first, so the first one still counts the same empty squares
+	place('N', knightPlacements[n][1])
+	place('N', knightPlacements[n][0])
+	// the king always ends up between the rooks
+	place('R', 0)
+	place('K', 0)
+	place('R', 0)
+
+	white := string(row[:])
+	return strings.ToLower(white) + "/pppppppp/8/8/8/8/PPPPPPPP/" + white + " w KQkq - 0 1", nil
+}
+
+// CreateChess960 sets up the Chess960 start position number index, see Chess960FEN
+func CreateChess960(index int) (Chessboard, error) {
+	FEN, err := Chess960FEN(index)
+	if err != nil {
+		return Chessboard{}, err
+	}
+	chessgame := CreateChessboard(FEN)
+	chessgame.Chess960 = true
+	return chessgame, nil
+}
+
+// GetShredderFEN is GetFEN with the castling rights written as the files of
+// the rooks, eg "HAha" for the standard start position
+func (c *Chessboard) GetShredderFEN() string {
+	fields := strings.Fields(c.GetFEN())
+	fields[2] = c.castlingField(true)
+	return strings.Join(fields, " ")
+}
+
+// parseCastling reads the castling field of a FEN. KQkq stand for the
+// outermost rook on each side of the king (X-FEN), file letters for the rook
+// on that file (Shredder-FEN, or X-FEN when the outermost rook is not the one)
+func (c *Chessboard) parseCastling(field string) {
+	rights := c.castlingRights()
+	for i := 0; i < len(field); i++ {
+		letter, color, first, homeRow := field[i], WHITE, 0, int8(0)
+		if letter >= 'a' {
+			letter, color, first, homeRow = letter-'a'+'A', BLACK, 2, 7
+		}
+		king := c.GetKingPosition(color)
+		rook := pieceColor(WROOK, color)
+
+		side, rookCol := first, int8(-1)
+		switch letter {
+		case 'K':
+			for col := int8(7); col > king.col && rookCol == -1; col-- {
+				if c.hasPiece(pair{col: col, row: homeRow}, rook) {
+					rookCol = col
+				}
+			}
+		case 'Q':
+			side++
+			for col := int8(0); col < king.col && rookCol == -1; col++ {
+				if c.hasPiece(pair{col: col, row: homeRow}, rook) {
+					rookCol = col
+				}
+			}
+		default:
+			rookCol = int8(letter - 'A')
+			if rookCol < king.col {
+				side++
+			}
+		}
+
+		// NOTE: a right without its rook is kept, the first move drops it
+		if rookCol == -1 {
+			rookCol = c.castlingRooks[side]
+		}
+		*rights[side] = true
+		c.castlingRooks[side] = rookCol
+		if king.col != 4 || (rookCol != 0 && rookCol != 7) {
+			c.Chess960 = true
+		}
+	}
+}
+
+// castlingField writes the castling rights for a FEN, in X-FEN unless shredder is set
+func (c *Chessboard) castlingField(shredder bool) string {
+	field := ""
+	for i, right := range c.castlingRights() {
+		if !*right {
+			continue
+		}
+		rook, homeRow := WROOK, int8(0)
+		if i >= 2 {
+			rook, homeRow = BROOK, 7
+		}
+
+		letter := byte('A' + c.castlingRooks[i])
+		if !shredder {
+			// KQ unless another rook is further out on the same side
+			letter = "KQ"[i%2]
+			outwards := int8(1)
+			if i%2 == 1 {
+				outwards = -1
+			}
+			for col := c.castlingRooks[i] + outwards; col >= 0 && col < 8; col += outwards {
+				if c.hasPiece(pair{col: col, row: homeRow}, rook) {
+					letter = byte('A' + c.castlingRooks[i])
+				}
+			}
+		}
+		if i >= 2 {
+			letter += 'a' - 'A'
+		}
+		field += string(letter)
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
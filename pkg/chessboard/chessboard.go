@@ -3,9 +3,12 @@ package chessboard
 import (
 	"errors"
 	"fmt"
+	"math/bits"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/kahnaisehC/chessboard/pkg/clock"
 	"github.com/kahnaisehC/chessboard/pkg/pgntags"
 )
 
@@ -80,13 +83,13 @@ var pieceToChar = map[int]byte{
 	WQUEEN:  'Q',
 	WROOK:   'R',
 	WBISHOP: 'B',
-	WKNIGHT: 'K',
+	WKNIGHT: 'N',
 	WPAWN:   'P',
 	BKING:   'k',
 	BQUEEN:  'q',
 	BROOK:   'r',
 	BBISHOP: 'b',
-	BKNIGHT: 'k',
+	BKNIGHT: 'n',
 	BPAWN:   'p',
 }
 
@@ -102,15 +105,23 @@ type pair struct {
 	row int8
 }
 
+// Move is a move of the side to move. Castling is stored as the king
+// taking its own rook, so it can't be confused with a king move in Chess960.
+// Drops (crazyhouse) have no from square
 type Move struct {
 	from      pair
 	to        pair
 	promotion int
+	castling  bool
+	drop      int
 }
 
 type Chessboard struct {
-	// Variant     string
-	PGNTags PGNTags.PGNTags
+	// Variant changes the rules, nil plays standard chess
+	Variant Variant
+	PGNTags pgntags.PGNTags
+	// Clock is pressed by every move if set. Copies of the board share it
+	Clock *clock.Clock
 
 	BoardState       [13]uint64
 	WhiteToMove      bool
@@ -119,23 +130,57 @@ type Chessboard struct {
 	BlackQueenCastle bool
 	WhiteKingCastle  bool
 	WhiteQueenCastle bool
-	Moves            []string
+	// Chess960 writes castling moves as the king taking its rook, see GetUCI
+	Chess960 bool
+	Moves    []string
 
 	HalfmoveClock   int
 	FullmoveCounter int
+
+	// crazyhouse: pieces in hand, indexed like BoardState, and the squares of promoted pieces
+	Pockets  [13]int
+	Promoted uint64
+
+	// three-check: checks given by each side
+	WhiteChecksGiven int
+	BlackChecksGiven int
+
+	// files of the castling rooks: white king side, white queen side, black king side, black queen side
+	castlingRooks [4]int8
+
+	// FEN the board was set up from, before the first of Moves
+	start string
 }
 type Result int
 
+const (
+	ONGOING Result = iota
+	WHITEWINS
+	BLACKWINS
+	DRAW
+)
+
+// String returns the result like the PGN Result tag writes it
+func (r Result) String() string {
+	switch r {
+	case WHITEWINS:
+		return "1-0"
+	case BLACKWINS:
+		return "0-1"
+	case DRAW:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
 // WARNING: FUNCTION VERY PERIGLOSA. Use at your own risk or smth
 func sq(s string) pair {
 	return pair{col: int8(s[0] - 'a'), row: int8(s[1] - '1')}
 }
 
 func pairToString(p pair) string {
-	ret := ""
-	ret += string(int8('a') + p.col)
-	ret += string(int8('1') + p.row)
-	return ret
+	return string([]byte{byte('a' + p.col), byte('1' + p.row)})
 }
 
 func addPair(a, b pair) pair {
@@ -146,88 +191,176 @@ func addPair(a, b pair) pair {
 }
 
 func intToPair(n int) pair {
-	return pair{col: int8(n / 8), row: int8(n % 8)}
+	return pair{col: int8(n % 8), row: int8(n / 8)}
 }
 
 func pairToInt(a pair) int {
 	return int(8*a.row + a.col)
 }
 
-// NOTE: write a FEN validator
+func isSquareString(s string) bool {
+	return len(s) == 2 && s[0] >= 'a' && s[0] <= 'h' && s[1] >= '1' && s[1] <= '8'
+}
+
+// ValidateFEN checks that FEN is well formed. The halfmove and fullmove
+// fields may be left out. logs says what is wrong with it
 func ValidateFEN(FEN string) (ok bool, logs string) {
+	return validateFEN(FEN, true)
+}
+
+// validateFEN is ValidateFEN for variants that don't need one king per side
+// when oneKingEach is false
+func validateFEN(FEN string, oneKingEach bool) (ok bool, logs string) {
+	FENparts := strings.Fields(FEN)
+	if len(FENparts) != 4 && len(FENparts) != 6 {
+		return false, "FEN should have 6 fields"
+	}
+
+	rows := strings.Split(FENparts[0], "/")
+	if len(rows) != 8 {
+		return false, "position should have 8 rows"
+	}
+	kings := map[byte]int{}
+	for _, row := range rows {
+		squares := 0
+		for i := 0; i < len(row); i++ {
+			c := row[i]
+			switch {
+			case c > '0' && c < '9':
+				squares += int(c - '0')
+			case charToPiece[c] != 0:
+				squares++
+				if c == 'K' || c == 'k' {
+					kings[c]++
+				}
+			default:
+				return false, "invalid character in position: " + string(c)
+			}
+		}
+		if squares != 8 {
+			return false, "row " + row + " does not have 8 squares"
+		}
+	}
+	if oneKingEach && (kings['K'] != 1 || kings['k'] != 1) {
+		return false, "each side should have exactly one king"
+	}
+
+	if FENparts[1] != "w" && FENparts[1] != "b" {
+		return false, "side to move should be w or b"
+	}
+
+	if FENparts[2] != "-" {
+		for _, c := range FENparts[2] {
+			// KQkq, or the files of the rooks in Shredder-FEN and X-FEN
+			if !strings.ContainsRune("KQkqABCDEFGHabcdefgh", c) {
+				return false, "invalid castling rights: " + FENparts[2]
+			}
+		}
+	}
+
+	if FENparts[3] != "-" && !isSquareString(FENparts[3]) {
+		return false, "invalid en passant square: " + FENparts[3]
+	}
+
+	if len(FENparts) == 6 {
+		if n, err := strconv.Atoi(FENparts[4]); err != nil || n < 0 {
+			return false, "invalid halfmove clock: " + FENparts[4]
+		}
+		if n, err := strconv.Atoi(FENparts[5]); err != nil || n < 1 {
+			return false, "invalid fullmove counter: " + FENparts[5]
+		}
+	}
+
 	return true, ""
 }
 
+// CreateChessboard sets up a board from FEN. An empty or invalid FEN gives the initial position
 func CreateChessboard(FEN string) Chessboard {
 	chessgame := Chessboard{}
-	row, col := int8(7), int8(0)
-
 	if ok, _ := ValidateFEN(FEN); !ok {
 		FEN = initialFEN
 	}
-	FENparts := strings.Split(initialFEN, " ")
+	chessgame.loadFEN(FEN)
+	chessgame.start = FEN
+	return chessgame
+}
+
+// StartingBoard returns the board before the first of Moves, with the same
+// variant and Chess960 castling. The clock and the tags are not copied
+func (c *Chessboard) StartingBoard() (Chessboard, error) {
+	if c.start == "" {
+		// not set up by CreateChessboard or CreateVariantChessboard
+		if len(c.Moves) > 0 {
+			return Chessboard{}, errors.New("the starting position of the board is unknown")
+		}
+		board := *c
+		board.Clock, board.PGNTags = nil, pgntags.PGNTags{}
+		return board, nil
+	}
+	board := Chessboard{}
+	if c.Variant == nil {
+		board = CreateChessboard(c.start)
+	} else {
+		var err error
+		if board, err = CreateVariantChessboard(c.Variant, c.start); err != nil {
+			return Chessboard{}, err
+		}
+	}
+	board.Chess960 = c.Chess960
+	return board, nil
+}
+
+// startingFEN returns the usual starting position of variant, nil for standard chess
+func startingFEN(variant Variant) string {
+	if variant == nil {
+		return initialFEN
+	}
+	return variant.StartingFEN()
+}
+
+// loadFEN sets the fields of c that a FEN describes. FEN has to be valid
+func (c *Chessboard) loadFEN(FEN string) {
+	row, col := int8(7), int8(0)
+	FENparts := strings.Fields(FEN)
+	if len(FENparts) == 4 {
+		FENparts = append(FENparts, "0", "1")
+	}
 
 	// position parsing
 	for i := 0; i < len(FENparts[0]); i++ {
-		c := FENparts[0][i]
-		if c > '0' && c < '9' {
-			col += int8(c - '0')
+		ch := FENparts[0][i]
+		if ch > '0' && ch < '9' {
+			col += int8(ch - '0')
 			continue
 		}
-		if c == '/' {
+		if ch == '/' {
 			row--
 			col = 0
 			continue
 		}
-		piece := charToPiece[c]
+		piece := charToPiece[ch]
 		position := pairToInt(pair{col, row})
-		chessgame.BoardState[piece] |= 1 << position
+		c.BoardState[piece] |= 1 << position
 		col++
 	}
 
 	// side to move parsing
-	chessgame.WhiteToMove = FENparts[1] == "w"
+	c.WhiteToMove = FENparts[1] == "w"
 
 	// castling rights parsing
-	for _, c := range FENparts[2] {
-		// "KQkq"
-		if c == 'K' {
-			chessgame.WhiteKingCastle = true
-		}
-		if c == 'Q' {
-			chessgame.WhiteQueenCastle = true
-		}
-		if c == 'k' {
-			chessgame.BlackKingCastle = true
-		}
-		if c == 'q' {
-			chessgame.BlackQueenCastle = true
-		}
+	c.castlingRooks = [4]int8{7, 0, 7, 0}
+	if FENparts[2] != "-" {
+		c.parseCastling(FENparts[2])
 	}
 
 	// en passant
-	// TODO: prettify this piece of shit
 	if FENparts[3] != "-" {
-		chessgame.EnPassantSquare.row = int8(FENparts[3][1] - '0')
-		chessgame.EnPassantSquare.col = int8(FENparts[3][2] - '`')
-	}
-
-	// half
-	// TODO: handle a little more gracefully the error
-	halfMove, err := strconv.ParseInt(FENparts[4], 10, 0)
-	if err != nil {
-		panic(err)
+		c.EnPassantSquare = sq(FENparts[3])
 	}
-	chessgame.HalfmoveClock = int(halfMove)
 
-	// full
-	fullMove, err := strconv.ParseInt(FENparts[5], 10, 0)
-	if err != nil {
-		panic(err)
-	}
-	chessgame.FullmoveCounter = int(fullMove)
-
-	return chessgame
+	// half and full move counters. ValidateFEN already checked they are numbers
+	c.HalfmoveClock, _ = strconv.Atoi(FENparts[4])
+	c.FullmoveCounter, _ = strconv.Atoi(FENparts[5])
 }
 
 func inBounds(p pair) bool {
@@ -253,31 +386,78 @@ func (c *Chessboard) putPiece(s pair, piece int) {
 	c.BoardState[piece] |= bitAux
 }
 
-// yo yo yo
-func (c *Chessboard) GetPGN() string {
-	PGN := ""
+// PGNLINELENGTH is the longest movetext line PGN export format allows
+const PGNLINELENGTH = 79
+
+// WrapPGN joins the words of movetext in lines no longer than PGNLINELENGTH
+func WrapPGN(words []string) string {
+	var b strings.Builder
+	lineLength := 0
+	for _, word := range words {
+		switch {
+		case lineLength == 0:
+		case lineLength+1+len(word) > PGNLINELENGTH:
+			b.WriteByte('\n')
+			lineLength = 0
+		default:
+			b.WriteByte(' ')
+			lineLength++
+		}
+		b.WriteString(word)
+		lineLength += len(word)
+	}
+	return b.String()
+}
 
-	/*
+// GetPGN writes the game as PGN: PGNTags, then the moves in SAN. The Result
+// tag is the result on the board unless it was set, eg for a resignation.
+// Variant, SetUp, FEN and TimeControl are filled in from the board. If the
+// starting position is unknown, the game is written from the current one
+func (c *Chessboard) GetPGN() string {
+	tags := c.PGNTags
+	// Set shouldn't change the tags of c
+	tags.Extra = append([]pgntags.Tag(nil), tags.Extra...)
+	if tags.Result == "" || tags.Result == ONGOING.String() {
+		tags.Result = c.GetResult().String()
+	}
+	switch {
+	case c.Variant != nil && c.Variant.Name() != (Standard{}).Name():
+		tags.Set("Variant", c.Variant.Name())
+	case c.Chess960:
+		tags.Set("Variant", "Chess960")
+	}
 
-		[Event "F/S Return Match"]
-		[Site "Belgrade, Serbia JUG"]
-		[Date "1992.11.04"]
-		[Round "29"]
-		[White "Fischer, Robert J."]
-		[Black "Spassky, Boris V."]
-		[Result "1/2-1/2"]
-
-		1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6 8. c3
-		O-O 9. h3 Nb8 10. d4 Nbd7 11. c4 c6 12. cxb5 axb5 13. Nc3 Bb7 14. Bg5 b4 15.
-		Nb1 h6 16. Bh4 c5 17. dxe5 Nxe4 18. Bxe7 Qxe7 19. exd6 Qf6 20. Nbd2 Nxd6 21.
-		Nc4 Nxc4 22. Bxc4 Nb6 23. Ne5 Rae8 24. Bxf7+ Rxf7 25. Nxf7 Rxe1+ 26. Qxe1 Kxf7
-		27. Qe3 Qg5 28. Qxg5 hxg5 29. b3 Ke6 30. a3 Kd6 31. axb4 cxb4 32. Ra5 Nd5 33.
-		f3 Bc8 34. Kf2 Bf5 35. Ra7 g6 36. Ra6+ Kc5 37. Ke1 Nf4 38. g3 Nxh3 39. Kd2 Kb5
-		40. Rd6 Kc5 41. Ra6 Nf2 42. g4 Bd3 43. Re6 1/2-1/2
+	moves := c.Moves
+	start, err := c.StartingBoard()
+	if err != nil {
+		start, moves = *c, nil
+		start.Clock = nil
+	}
+	if fen := start.GetFEN(); fen != startingFEN(c.Variant) || c.Chess960 {
+		tags.Set("SetUp", "1")
+		tags.Set("FEN", fen)
+	}
+	if _, ok := tags.Get("TimeControl"); !ok && c.Clock != nil {
+		tags.Set("TimeControl", c.Clock.Control().String())
+	}
 
-	*/
+	var words []string
+	for i, uci := range moves {
+		m, err := start.MoveFromUCI(uci)
+		if err != nil {
+			break
+		}
+		if start.WhiteToMove {
+			words = append(words, strconv.Itoa(start.FullmoveCounter)+".")
+		} else if i == 0 {
+			words = append(words, strconv.Itoa(start.FullmoveCounter)+"...")
+		}
+		words = append(words, start.GetSAN(m))
+		start.MakeUCIMove(uci)
+	}
+	words = append(words, tags.Result)
 
-	return PGN
+	return tags.String() + "\n" + WrapPGN(words) + "\n"
 }
 
 func (c *Chessboard) GetFEN() string {
@@ -286,7 +466,7 @@ func (c *Chessboard) GetFEN() string {
 	//"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
 	// position parsing
-	for row := int8(7); row > 0; row-- {
+	for row := int8(7); row >= 0; row-- {
 		if row != 7 {
 			FEN += "/"
 		}
@@ -321,21 +501,7 @@ func (c *Chessboard) GetFEN() string {
 	FEN += " "
 
 	// Castling rights
-	if c.WhiteKingCastle {
-		FEN += "K"
-	}
-	if c.WhiteQueenCastle {
-		FEN += "Q"
-	}
-	if c.BlackKingCastle {
-		FEN += "k"
-	}
-	if c.BlackQueenCastle {
-		FEN += "q"
-	}
-	if FEN[len(FEN)-1] == ' ' {
-		FEN += "-"
-	}
+	FEN += c.castlingField(false)
 	FEN += " "
 
 	// En passant Square
@@ -353,139 +519,67 @@ func (c *Chessboard) GetFEN() string {
 	// FullmoveClock
 	FEN += strconv.Itoa(c.FullmoveCounter)
 
+	if c.Variant != nil {
+		return c.Variant.WriteFEN(c, FEN)
+	}
 	return FEN
 }
 
+// SquareIsThreatened reports if a piece of color attacks the square p
 func (c *Chessboard) SquareIsThreatened(color bool, p pair) bool {
-	var auxSquare pair
-	fmt.Printf("%v", p)
+	// GetKingPosition is off the board when there is no king
+	if !inBounds(p) {
+		return false
+	}
+	king, queen, rook, bishop, knight, pawn := WKING, WQUEEN, WROOK, WBISHOP, WKNIGHT, WPAWN
+	// pawns attack forward, so the attacking pawn is one row behind p
+	pawnRow := int8(-1)
 	if color == BLACK {
-		// pawn
-		auxSquare = addPair(p, pair{col: 1, row: 1})
-		if c.getPiece(auxSquare) == BPAWN {
-			fmt.Println("Black king threatened by white pawn in %s", auxSquare)
-			return true
-		}
-		auxSquare = addPair(p, pair{col: 1, row: -1})
-		if c.getPiece(auxSquare) == BPAWN {
-			fmt.Println("Black king threatened by black pawn in %s", auxSquare)
-			return true
-		}
-		// knight
-		for _, move := range knightMoves {
-			piece := BKNIGHT
-			nextSquare := addPair(p, move)
-			if c.getPiece(nextSquare) == BKNIGHT {
-				fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-				return true
-			}
-		}
-		// king
-		for _, move := range kingMoves {
-			piece := BKING
-			nextSquare := addPair(p, move)
-			if c.getPiece(nextSquare) == BKING {
-				fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-				return true
-			}
-		}
-		// bishop
-		for _, direction := range bishopSlides {
-			for nextSquare := addPair(p, direction); inBounds(nextSquare); nextSquare = addPair(nextSquare, direction) {
-				piece := c.getPiece(nextSquare)
-				if isWhite(piece) {
-					break
-				}
-				if piece == BQUEEN || piece == BBISHOP {
-					fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-					return true
-				}
-				if piece != 0 {
-					break
-				}
-
-			}
-		}
-		// rook
-		for _, direction := range rookSlides {
-			for nextSquare := addPair(p, direction); inBounds(nextSquare); nextSquare = addPair(nextSquare, direction) {
-				piece := c.getPiece(nextSquare)
-				if isWhite(piece) {
-					break
-				}
-				if piece == BQUEEN || piece == BROOK {
-					fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-					return true
-				}
-				if piece != 0 {
-					break
-				}
+		king, queen, rook, bishop, knight, pawn = BKING, BQUEEN, BROOK, BBISHOP, BKNIGHT, BPAWN
+		pawnRow = 1
+	}
 
-			}
-		}
-	} else {
-		// pawn
-		nextSquare := addPair(p, pair{col: -1, row: -1})
-		if c.getPiece(nextSquare) == WPAWN {
-			fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[WPAWN], nextSquare)
+	// pawn
+	if c.hasPiece(addPair(p, pair{col: -1, row: pawnRow}), pawn) ||
+		c.hasPiece(addPair(p, pair{col: 1, row: pawnRow}), pawn) {
+		return true
+	}
+	// knight
+	for _, move := range knightMoves {
+		if c.hasPiece(addPair(p, move), knight) {
 			return true
 		}
-		nextSquare = addPair(p, pair{col: -1, row: -1})
-		if c.getPiece(nextSquare) == WPAWN {
-			fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[WPAWN], nextSquare)
+	}
+	// king
+	for _, move := range kingMoves {
+		if c.hasPiece(addPair(p, move), king) {
 			return true
 		}
-		// knight
-		for _, move := range knightMoves {
-			nextSquare := addPair(p, move)
-			if c.getPiece(nextSquare) == WKNIGHT {
-				fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[WKING], nextSquare)
-				return true
+	}
+
+	occupied := c.occupied()
+	// bishop and queen
+	for _, direction := range bishopSlides {
+		for nextSquare := addPair(p, direction); inBounds(nextSquare); nextSquare = addPair(nextSquare, direction) {
+			if occupied&(1<<pairToInt(nextSquare)) == 0 {
+				continue
 			}
-		}
-		// king
-		for _, move := range kingMoves {
-			nextSquare := addPair(p, move)
-			if c.getPiece(nextSquare) == WKING {
-				fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[WKING], nextSquare)
+			if c.hasPiece(nextSquare, queen) || c.hasPiece(nextSquare, bishop) {
 				return true
 			}
+			break
 		}
-		// bishop
-		for _, direction := range bishopSlides {
-			nextSquare := addPair(p, direction)
-			for inBounds(nextSquare) {
-				piece := c.getPiece(nextSquare)
-				if !isWhite(piece) {
-					break
-				}
-				if piece == WQUEEN || piece == WBISHOP {
-					fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-					return true
-				}
-				if piece != 0 {
-					break
-				}
-
+	}
+	// rook and queen
+	for _, direction := range rookSlides {
+		for nextSquare := addPair(p, direction); inBounds(nextSquare); nextSquare = addPair(nextSquare, direction) {
+			if occupied&(1<<pairToInt(nextSquare)) == 0 {
+				continue
 			}
-		}
-		// rook
-		for _, direction := range rookSlides {
-			nextSquare := addPair(p, direction)
-			for inBounds(nextSquare) {
-				piece := c.getPiece(nextSquare)
-				if !isWhite(piece) {
-					break
-				}
-				if piece == WQUEEN || piece == WROOK {
-					fmt.Printf("Square: %v is threatenned by a %c in %v", p, pieceToChar[piece], nextSquare)
-					return true
-				}
-				if piece != 0 {
-					break
-				}
-
+			if c.hasPiece(nextSquare, queen) || c.hasPiece(nextSquare, rook) {
+				return true
 			}
+			break
 		}
 	}
 	return false
@@ -496,13 +590,15 @@ func (c *Chessboard) GetKingPosition(color bool) pair {
 	if color == WHITE {
 		king = WKING
 	}
-	for i := 0; i < 64; i++ {
-		if ((1 << i) & c.BoardState[king]) != 0 {
-			println(i)
-			return intToPair(i)
-		}
+	if c.BoardState[king] == 0 {
+		return intToPair(64)
 	}
-	return intToPair(64)
+	return intToPair(bits.TrailingZeros64(c.BoardState[king]))
+}
+
+// InCheck reports if the side to move is in check
+func (c *Chessboard) InCheck() bool {
+	return c.SquareIsThreatened(!c.WhiteToMove, c.GetKingPosition(c.WhiteToMove))
 }
 
 func (c *Chessboard) PrintBoard() {
@@ -515,129 +611,33 @@ func (c *Chessboard) PrintBoard() {
 	}
 }
 
-// TODO: FIX THIS FUNCTION makes changes to the chessboard instead of being stateless
 func (c *Chessboard) CheckMoveLegality(move Move) bool {
-	// check inbounds
-	if !(inBounds(move.from) || inBounds(move.to)) {
-		fmt.Println("out of bounds error")
-		return false
+	variant := c.variant()
+	if !judgesMovesAlone(variant) {
+		return slices.Contains(c.GetMoveList(), move)
 	}
-	fromPiece := c.getPiece(move.from)
-	//  check if frompiece is not empty
-	if fromPiece == 0 {
-		fmt.Println("from square without pieces")
-		return false
+	// only the moves of the piece on from, and whatever the variant adds
+	var pieceMoves []Move
+	if move.drop == 0 {
+		pieceMoves = c.pieceMoves(move.from)
 	}
-	toPiece := c.getPiece(move.to)
-
-	// toPiece is not of the same color
-	if toPiece != 0 && isWhite(toPiece) == isWhite(fromPiece) {
-		fmt.Println("from piece and piece fro to square are the same color")
+	if !slices.Contains(variant.PseudoLegalMoves(c, pieceMoves), move) {
 		return false
 	}
+	next := *c
+	next.makeMove(move)
+	return variant.Legal(c, move, &next) && len(variant.FilterMoves(c, []Move{move})) == 1
+}
 
-	// if is the correct turn to play
-	if isWhite(fromPiece) != c.WhiteToMove {
-		fmt.Println("wrong turn to move")
-		return false
-	}
-
-	// make move
-	switch {
-	// castling
-	case fromPiece == BKING:
-		if (move.from == sq("e8")) && (move.to == sq("c8")) {
-			if !c.BlackQueenCastle {
-				fmt.Println("black cant queen castle")
-				return false
-			}
-			if c.SquareIsThreatened(WHITE, sq("e8")) || c.SquareIsThreatened(WHITE, sq("d8")) || c.SquareIsThreatened(WHITE, sq("c8")) {
-				fmt.Println("kings passing square is threatened")
-				return false
-			}
-			if c.getPiece(sq("d8")) != 0 || c.getPiece(sq("c8")) != 0 || c.getPiece(sq("b8")) != 0 {
-				fmt.Println("squares are interrupted")
-				return false
-			}
-
-			// WARNING: I THINK THERE IS NO OTHER EDGE CASE.
-			return true
-		}
-		if (move.from == sq("e8")) && (move.to == sq("g8")) {
-			if !c.BlackKingCastle {
-				fmt.Println("black cant king castle")
-				return false
-			}
-			if c.SquareIsThreatened(WHITE, sq("e8")) || c.SquareIsThreatened(WHITE, sq("f8")) || c.SquareIsThreatened(WHITE, sq("g8")) {
-				fmt.Println("kings passing square is threatened")
-				return false
-			}
-			if c.getPiece(sq("f8")) != 0 || c.getPiece(sq("g8")) != 0 {
-				fmt.Println("squares are interrupted")
-				return false
-			}
-			// WARNING: I THINK THERE IS NO OTHER EDGE CASE
-			return true
-		}
-	case fromPiece == WKING:
-		if (move.from == pair{col: 4, row: 0}) && (move.to == pair{col: 2, row: 0}) {
-			if !c.WhiteQueenCastle {
-				fmt.Println("white cant queen castle")
-				return false
-			}
-			if c.SquareIsThreatened(BLACK, sq("e1")) || c.SquareIsThreatened(BLACK, sq("d1")) || c.SquareIsThreatened(BLACK, sq("c1")) {
-				fmt.Println("kings passing square is threatened")
-				return false
-			}
-			if c.getPiece(sq("d1")) != 0 || c.getPiece(sq("c1")) != 0 || c.getPiece(sq("b1")) != 0 {
-				fmt.Println("squares are interrupted")
-				return false
-			}
-			// WARNING: I THINK THERE IS NO OTHER EDGE CASE
-			return true
-		}
-		if (move.from == pair{col: 4, row: 0}) && (move.to == pair{col: 6, row: 0}) {
-			if !c.WhiteKingCastle {
-				fmt.Println("white cant king castle")
-				return false
-			}
-			if c.SquareIsThreatened(BLACK, sq("e1")) || c.SquareIsThreatened(BLACK, sq("f1")) || c.SquareIsThreatened(BLACK, sq("g1")) {
-				fmt.Println("kings passing square is threatened")
-				return false
-			}
-			if c.getPiece(sq("f1")) != 0 || c.getPiece(sq("g1")) != 0 {
-				fmt.Println("squares are interrupted")
-				return false
-			}
-			// WARNING: I THINK THERE IS NO OTHER EDGE CASE
-			return true
-		}
-	// TODO: Write en passant edge case
-	// en passant
-
-	// TODO: write Promotion edge ccase
-	// promotion
-
-	// if non special move
-	default:
-		c.erasePiece(move.from)
-		c.putPiece(move.to, fromPiece)
-	}
-
-	// check legality of c.BoardState
-	kingPosition := c.GetKingPosition(c.WhiteToMove)
-
-	threat := c.SquareIsThreatened(!c.WhiteToMove, kingPosition)
-
-	// restore c.BoardState
-	c.putPiece(move.to, toPiece)
-	c.putPiece(move.from, fromPiece)
-	if threat == true {
-		fmt.Println("king square is threatenned")
-		return false
+// judgesMovesAlone reports if the FilterMoves of variant keeps or drops a
+// move whatever the other legal moves are. Antichess keeps captures only if
+// there are some, and unknown variants may look at every move too
+func judgesMovesAlone(variant Variant) bool {
+	switch variant.(type) {
+	case Standard, Crazyhouse, Bughouse, Atomic, ThreeCheck, KingOfTheHill, Horde:
+		return true
 	}
-
-	return true
+	return false
 }
 
 func (c *Chessboard) getPiece(square pair) int {
@@ -653,332 +653,371 @@ func (c *Chessboard) getPiece(square pair) int {
 	return 0
 }
 
+func (c *Chessboard) hasPiece(square pair, piece int) bool {
+	return inBounds(square) && c.BoardState[piece]&(1<<pairToInt(square)) != 0
+}
+
+func (c *Chessboard) occupied() uint64 {
+	occupied := uint64(0)
+	for _, bitboard := range c.BoardState {
+		occupied |= bitboard
+	}
+	return occupied
+}
+
 func isWhite(piece int) bool {
 	return piece > 0 && piece < BKING
 }
 
-func (c *Chessboard) getMoveList() []Move {
-	// Check all possible moves of all pieces
-	// Check move legality of every move
-	// Return filtered movement list
+func pieceColor(piece int, color bool) int {
+	if color == BLACK && piece != 0 && piece < BKING {
+		return piece + BKING - WKING
+	}
+	return piece
+}
+
+// GetMoveList returns the legal moves of the side to move
+func (c *Chessboard) GetMoveList() []Move {
+	variant := c.variant()
 	var movements []Move
-	for col := int8(0); col <= 8; col++ {
-		for row := int8(0); row <= 8; row++ {
-			var directions []pair
-			var toSquares []pair
-			var auxSquare pair
-			from := pair{col: col, row: row}
-			piece := c.getPiece(from)
-			pieceColor := isWhite(piece)
-			switch piece {
-			case BPAWN:
-				if row == 6 &&
-					c.getPiece(pair{col: col, row: row - 1}) == 0 &&
-					c.getPiece(pair{col: col, row: row - 2}) == 0 {
-					toSquares = append(toSquares, pair{row: row - 2, col: col})
-				}
-				auxSquare = pair{col: col - 1, row: row - 1}
-				if isWhite(c.getPiece(auxSquare)) || c.EnPassantSquare == auxSquare {
-					toSquares = append(toSquares, auxSquare)
-				}
-				auxSquare = pair{col: col + 1, row: row - 1}
-				if isWhite(c.getPiece(auxSquare)) || c.EnPassantSquare == auxSquare {
-					toSquares = append(toSquares, auxSquare)
-				}
-				if c.getPiece(pair{col: col, row: row - 1}) == 0 {
-					toSquares = append(toSquares, pair{col: col, row: row - 1})
-				}
+	for _, move := range variant.PseudoLegalMoves(c, c.pseudoLegalMoves()) {
+		// play the move on a copy and let the variant judge the position it leaves
+		next := *c
+		next.makeMove(move)
+		if variant.Legal(c, move, &next) {
+			movements = append(movements, move)
+		}
+	}
+	return variant.FilterMoves(c, movements)
+}
 
-			case WPAWN:
-				if row == 1 &&
-					c.getPiece(pair{col: col, row: row + 1}) == 0 &&
-					c.getPiece(pair{col: col, row: row + 2}) == 0 {
-					toSquares = append(toSquares, pair{row: row + 2, col: col})
-				}
-				auxSquare = pair{col: col - 1, row: row + 1}
-				if !isWhite(c.getPiece(auxSquare)) || c.EnPassantSquare == auxSquare {
-					toSquares = append(toSquares, pair{col: col - 1, row: row + 1})
-				}
-				auxSquare = pair{col: col + 1, row: row + 1}
-				if !isWhite(c.getPiece(auxSquare)) || c.EnPassantSquare == auxSquare {
-					toSquares = append(toSquares, pair{col: col + 1, row: row + 1})
-				}
-				if c.getPiece(pair{col: col, row: row + 1}) == 0 {
-					toSquares = append(toSquares, pair{col: col, row: row + 1})
-				}
-			case BKING:
-				// TODO: handle castling
-				if c.BlackKingCastle {
-					toSquares = append(toSquares, pair{col: col + 2, row: row})
-				}
-				if c.BlackQueenCastle {
-					toSquares = append(toSquares, pair{col: col - 2, row: row})
-				}
-				fallthrough
-			case WKING:
-				if c.WhiteKingCastle && piece == WKING {
-					toSquares = append(toSquares, pair{col: col + 2, row: row})
-				}
-				if c.WhiteQueenCastle && piece == WKING {
-					toSquares = append(toSquares, pair{col: col - 2, row: row})
-				}
-				for i := int8(-1); i < 2; i++ {
-					for j := int8(-1); j < 2; j++ {
-						if i == j && i == 0 {
-							continue
-						}
-						move := pair{col + i, row + j}
-						toSquares = append(toSquares, move)
-					}
-				}
-			case BKNIGHT:
-				fallthrough
-			case WKNIGHT:
-				for i := int8(-2); i < 5; i += 4 {
-					for j := int8(-1); j < 2; j += 2 {
-						toSquares = append(toSquares, pair{col: col + i, row: row + j})
-						toSquares = append(toSquares, pair{col: col + j, row: row + i})
-					}
-				}
+// GetResult returns the result of the game, ONGOING if it isn't over. A
+// side that runs out of time loses, unless the other side can't mate
+func (c *Chessboard) GetResult() Result {
+	result := c.variant().Result(c, c.GetMoveList())
+	if result != ONGOING || c.Clock == nil {
+		return result
+	}
+	if white, flagged := c.Clock.Flagged(); flagged {
+		if !c.canMate(!white) {
+			return DRAW
+		}
+		return winner(!white)
+	}
+	return ONGOING
+}
 
-			case BBISHOP:
-				fallthrough
-			case WBISHOP:
-				directions = []pair{
-					{
-						col: -1,
-						row: -1,
-					},
-					{
-						col: 1,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 1,
-					},
-				}
+// InsufficientMaterial reports if neither side can checkmate
+func (c *Chessboard) InsufficientMaterial() bool {
+	return !c.canMate(WHITE) && !c.canMate(BLACK)
+}
 
-			case BROOK:
-				fallthrough
-			case WROOK:
-				directions = []pair{
-					{
-						col: 1,
-						row: 0,
-					},
-					{
-						col: 0,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 0,
-					},
-					{
-						col: 0,
-						row: -1,
-					},
-				}
-			case BQUEEN:
-				fallthrough
-			case WQUEEN:
-				directions = []pair{
-					{
-						col: 1,
-						row: 0,
-					},
-					{
-						col: 0,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 0,
-					},
-					{
-						col: 0,
-						row: -1,
-					},
-					{
-						col: -1,
-						row: -1,
-					},
-					{
-						col: 1,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 1,
-					},
-					{
-						col: -1,
-						row: 1,
-					},
-				}
+// the light squares, b1, a2...
+const lightSquares = uint64(0x55aa55aa55aa55aa)
+
+// canMate reports if color has the material to mate with some series of
+// legal moves. Like most servers it only knows the plain cases: a lone king,
+// a king and a minor piece against a lone king, and bishops that are all on
+// squares of one color with nothing else on the board but the kings. Pieces
+// in the pocket (crazyhouse, bughouse) count, they can be dropped anywhere
+func (c *Chessboard) canMate(color bool) bool {
+	queen, rook, pawn := pieceColor(WQUEEN, color), pieceColor(WROOK, color), pieceColor(WPAWN, color)
+	bishop, knight := pieceColor(WBISHOP, color), pieceColor(WKNIGHT, color)
+	if c.BoardState[queen]|c.BoardState[rook]|c.BoardState[pawn] != 0 || c.Pockets[queen]+c.Pockets[rook]+c.Pockets[pawn] > 0 {
+		return true
+	}
+	pocketMinors := c.Pockets[bishop] + c.Pockets[knight]
+	minors := bits.OnesCount64(c.BoardState[bishop]|c.BoardState[knight]) + pocketMinors
+	if minors == 0 {
+		return false
+	}
+
+	// everything but the kings and color's minor pieces
+	others := c.occupied() &^ (c.BoardState[WKING] | c.BoardState[BKING] | c.BoardState[bishop] | c.BoardState[knight])
+	if minors == 1 && others == 0 {
+		return false
+	}
+
+	// only bishops left, all on one color
+	bishops := c.BoardState[WBISHOP] | c.BoardState[BBISHOP]
+	if c.BoardState[knight] == 0 && pocketMinors == 0 && others&^bishops == 0 && (bishops&lightSquares == 0 || bishops&^lightSquares == 0) {
+		return false
+	}
+	return true
+}
+
+// pseudoLegalMoves returns every move of the side to move, without
+// checking if they leave the king in check
+func (c *Chessboard) pseudoLegalMoves() []Move {
+	var movements []Move
+	for position := 0; position < 64; position++ {
+		movements = append(movements, c.pieceMoves(intToPair(position))...)
+	}
+	return movements
+}
+
+// pieceMoves returns the pseudo legal moves of the piece on from, none if it
+// isn't a piece of the side to move
+func (c *Chessboard) pieceMoves(from pair) []Move {
+	var movements []Move
+	color := c.WhiteToMove
+	piece := c.getPiece(from)
+	if piece == 0 || isWhite(piece) != color {
+		return nil
+	}
+
+	var toSquares []pair
+	switch piece {
+	case WPAWN, BPAWN:
+		forward, startRow, lastRow := int8(1), int8(1), int8(7)
+		if piece == BPAWN {
+			forward, startRow, lastRow = -1, 6, 0
+		}
+		oneStep := addPair(from, pair{row: forward})
+		if inBounds(oneStep) && c.getPiece(oneStep) == 0 {
+			toSquares = append(toSquares, oneStep)
+			twoStep := addPair(oneStep, pair{row: forward})
+			if from.row == startRow && c.getPiece(twoStep) == 0 {
+				toSquares = append(toSquares, twoStep)
 			}
-			for _, direction := range directions {
-				currSquare := addPair(direction, from)
-				currSquarePieceColor := isWhite(c.getPiece(currSquare))
-				for {
-					if !inBounds(currSquare) {
-						break
-					}
-					if currSquarePieceColor == pieceColor {
-						break
-					}
-					if currSquarePieceColor != pieceColor {
-						toSquares = append(toSquares, currSquare)
-						break
-					}
-					toSquares = append(toSquares, currSquare)
-					currSquare = addPair(currSquare, direction)
-				}
+		}
+		for _, col := range []int8{-1, 1} {
+			capture := addPair(from, pair{col: col, row: forward})
+			if !inBounds(capture) {
+				continue
 			}
-			for _, to := range toSquares {
-				move := Move{
-					from:      from,
-					to:        to,
-					promotion: 'Q',
-				}
+			toPiece := c.getPiece(capture)
+			if (toPiece != 0 && isWhite(toPiece) != color) ||
+				(c.EnPassantSquare != (pair{}) && c.EnPassantSquare == capture) {
+				toSquares = append(toSquares, capture)
+			}
+		}
+		for _, to := range toSquares {
+			if to.row != lastRow {
+				movements = append(movements, Move{from: from, to: to})
+				continue
+			}
+			for _, promotion := range []int{WQUEEN, WROOK, WBISHOP, WKNIGHT} {
+				movements = append(movements, Move{from: from, to: to, promotion: pieceColor(promotion, color)})
+			}
+		}
+		return movements
 
-				if c.CheckMoveLegality(move) {
-					movements = append(movements, move)
-					if c.getPiece(from) == WPAWN && from.row == 6 ||
-						c.getPiece(from) == BPAWN && from.row == 1 {
-						movements = append(movements, Move{from, to, 'R'})
-						movements = append(movements, Move{from, to, 'N'})
-						movements = append(movements, Move{from, to, 'B'})
-					}
+	case WKNIGHT, BKNIGHT:
+		for _, move := range knightMoves {
+			toSquares = append(toSquares, addPair(from, move))
+		}
+
+	case WKING, BKING:
+		for _, move := range kingMoves {
+			toSquares = append(toSquares, addPair(from, move))
+		}
+		movements = append(movements, c.castlingMoves(from)...)
+
+	case WBISHOP, BBISHOP, WROOK, BROOK, WQUEEN, BQUEEN:
+		var directions []pair
+		if piece != WROOK && piece != BROOK {
+			directions = append(directions, bishopSlides...)
+		}
+		if piece != WBISHOP && piece != BBISHOP {
+			directions = append(directions, rookSlides...)
+		}
+		for _, direction := range directions {
+			for currSquare := addPair(from, direction); inBounds(currSquare); currSquare = addPair(currSquare, direction) {
+				toSquares = append(toSquares, currSquare)
+				if c.getPiece(currSquare) != 0 {
+					break
 				}
 			}
 		}
 	}
+
+	for _, to := range toSquares {
+		toPiece := c.getPiece(to)
+		if !inBounds(to) || (toPiece != 0 && isWhite(toPiece) == color) {
+			continue
+		}
+		movements = append(movements, Move{from: from, to: to})
+	}
 	return movements
 }
 
-func stringToMove(s string) (Move, error) {
-	return Move{}, nil
+// castlingMoves returns the castling moves of the king on from. The king
+// can't castle out of or through check, landing in check is left to GetMoveList.
+// Wherever the king and rook start, they end up where they would in standard
+// chess: the king on the g or c file and the rook next to it on the f or d file
+func (c *Chessboard) castlingMoves(from pair) []Move {
+	var moves []Move
+	rights := c.castlingRights()
+	first, rook, homeRow := 0, WROOK, int8(0)
+	if !c.WhiteToMove {
+		first, rook, homeRow = 2, BROOK, 7
+	}
+	if from.row != homeRow || c.SquareIsThreatened(!c.WhiteToMove, from) {
+		return nil
+	}
+
+	for i := first; i < first+2; i++ {
+		rookFrom := pair{col: c.castlingRooks[i], row: homeRow}
+		if !*rights[i] || !c.hasPiece(rookFrom, rook) {
+			continue
+		}
+		kingTo, rookTo := castlingDestinations(i, homeRow)
+
+		// the squares both pieces cross have to be empty, apart from the king and the rook themselves
+		others := c.occupied() &^ (1<<pairToInt(from) | 1<<pairToInt(rookFrom))
+		if others&(rowSpan(from, kingTo)|rowSpan(rookFrom, rookTo)) != 0 {
+			continue
+		}
+		safe := true
+		for col := min(from.col, kingTo.col); col <= max(from.col, kingTo.col); col++ {
+			if col != from.col && c.SquareIsThreatened(!c.WhiteToMove, pair{col: col, row: homeRow}) {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			moves = append(moves, Move{from: from, to: rookFrom, castling: true})
+		}
+	}
+	return moves
+}
+
+// castlingRights returns the castling rights in the order of castlingRooks
+func (c *Chessboard) castlingRights() [4]*bool {
+	return [4]*bool{&c.WhiteKingCastle, &c.WhiteQueenCastle, &c.BlackKingCastle, &c.BlackQueenCastle}
 }
 
-func (c *Chessboard) MakeMove(move string) error {
-	/*
-		version 0 format: _fromsquare_tosquare_promotion
-		eg: 0e2e4_
-		eg: 0e7e8Q
-		eg: 0g1f3_
-	*/
+// castlingDestinations returns where the king and the rook of castlingRooks[i] end up
+func castlingDestinations(i int, homeRow int8) (king, rook pair) {
+	if i%2 == 1 {
+		return pair{col: 2, row: homeRow}, pair{col: 3, row: homeRow}
+	}
+	return pair{col: 6, row: homeRow}, pair{col: 5, row: homeRow}
+}
+
+// rowSpan returns the squares from a to b, both included. a and b are on the same row
+func rowSpan(a, b pair) uint64 {
+	span := uint64(0)
+	for col := min(a.col, b.col); col <= max(a.col, b.col); col++ {
+		span |= 1 << pairToInt(pair{col: col, row: a.row})
+	}
+	return span
+}
 
+// version 0 format, see MakeMove
+func (c *Chessboard) stringToMove(move string) (Move, error) {
 	if len(move) < 6 {
-		fmt.Println(move)
-		return errors.New("Invalid move string: " + move)
+		return Move{}, errors.New("Invalid move string: " + move)
 	}
 	version := move[0]
-	var from pair
-	var to pair
-	var promotion int
 	switch version {
 	case '0':
-		{
-			from = pair{
-				col: int8(move[1] - 'a'),
-				row: int8(move[2] - '1'),
-			}
-			to = pair{
-				col: int8(move[3] - 'a'),
-				row: int8(move[4] - '1'),
-			}
-			promotion = int(move[5] - '0')
+		if !isSquareString(move[1:3]) || !isSquareString(move[3:5]) {
+			return Move{}, errors.New("Invalid move string: " + move)
+		}
+		uci := move[1:5]
+		promotion := 0
+		if move[5] != '_' {
+			uci += strings.ToLower(move[5:6])
+			promotion = pieceColor(charToPiece[strings.ToUpper(move[5:6])[0]], c.WhiteToMove)
+		}
+		// castling is stored as king takes rook, let MoveFromUCI find it
+		if m, err := c.MoveFromUCI(uci); err == nil {
+			return m, nil
 		}
+		return Move{from: sq(move[1:3]), to: sq(move[3:5]), promotion: promotion}, nil
 	default:
-		return errors.New("Invalid version of move")
+		return Move{}, errors.New("Invalid version of move")
 	}
+}
 
-	fromPiece := c.getPiece(from)
-	toPiece := c.getPiece(to)
-
-	if !c.CheckMoveLegality(Move{from: from, to: to, promotion: int(promotion)}) {
-		fmt.Println("from: %v, to: %v, promotion: %v\n", from, to, promotion)
-		return errors.New("the move is Illegal")
+func (c *Chessboard) MakeMove(move string) error {
+	/*
+		version 0 format: _fromsquare_tosquare_promotion
+		eg: 0e2e4_
+		eg: 0e7e8Q
+		eg: 0g1f3_
+	*/
+	m, err := c.stringToMove(move)
+	if err != nil {
+		return err
 	}
+	return c.playMove(m)
+}
 
-	// update state of the board
-	c.putPiece(to, fromPiece)
-	c.erasePiece(from)
-	if promotion > 0 && promotion < 14 {
-		c.putPiece(to, promotion)
+// playMove plays m if it is legal and records it in c.Moves
+func (c *Chessboard) playMove(m Move) error {
+	if !c.CheckMoveLegality(m) {
+		return errors.New("the move is Illegal: " + m.String())
 	}
-
-	// edge cases
-	// sWcastle
-	if fromPiece == WKING &&
-		from == sq("e1") &&
-		to == sq("g1") {
-		c.erasePiece(sq("h1"))
-		c.putPiece(sq("f1"), WROOK)
+	if c.Clock != nil {
+		// a move made after the flag fell doesn't count
+		if err := c.Clock.Press(c.WhiteToMove); err != nil {
+			return err
+		}
 	}
+	c.Moves = append(c.Moves, c.GetUCI(m))
+	c.makeMove(m)
+	if c.Clock != nil && c.GetResult() != ONGOING {
+		c.Clock.Stop()
+	}
+	return nil
+}
 
-	// sBCastle
-	if fromPiece == BKING &&
-		from == sq("e8") &&
-		to == sq("g8") {
-		c.erasePiece(sq("h8"))
-		c.putPiece(sq("f8"), BROOK)
+// makeMove updates the board with m without checking that it is legal
+func (c *Chessboard) makeMove(m Move) {
+	if c.Variant == nil {
+		c.makeStandardMove(m)
+		return
 	}
+	before := *c
+	c.makeStandardMove(m)
+	c.Variant.AfterMove(&before, m, c)
+}
 
-	// lwcastle
-	if fromPiece == WKING &&
-		from == sq("e1") &&
-		to == sq("c1") {
-		c.erasePiece(sq("a1"))
-		c.putPiece(sq("d1"), WROOK)
+func (c *Chessboard) makeStandardMove(m Move) {
+	if m.drop != 0 {
+		c.putPiece(m.to, m.drop)
+		c.HalfmoveClock++
+		c.passTurn()
+		c.EnPassantSquare = pair{}
+		return
 	}
 
-	// lbcastle
-	if fromPiece == BKING &&
-		from == sq("e8") &&
-		to == sq("c8") {
-		c.erasePiece(sq("a8"))
-		c.putPiece(sq("d8"), BROOK)
+	from, to := m.from, m.to
+	fromPiece := c.getPiece(from)
+	toPiece := c.getPiece(to)
+
+	// update state of the board
+	if m.castling {
+		// the king "takes" its rook, both land on their castling squares
+		side := 0
+		if to.col < from.col {
+			side = 1
+		}
+		kingTo, rookTo := castlingDestinations(side, from.row)
+		c.erasePiece(from)
+		c.erasePiece(to)
+		c.putPiece(kingTo, fromPiece)
+		c.putPiece(rookTo, toPiece)
+		toPiece = 0
+	} else {
+		c.erasePiece(from)
+		c.putPiece(to, fromPiece)
+		if m.promotion != 0 {
+			c.putPiece(to, m.promotion)
+		}
 	}
 
 	// en passant edge case
-	if c.EnPassantSquare == to &&
-		(fromPiece == WPAWN || fromPiece == BPAWN) {
-		c.erasePiece(pair{col: c.EnPassantSquare.col, row: from.row})
+	if (fromPiece == WPAWN || fromPiece == BPAWN) && from.col != to.col && toPiece == 0 {
+		c.erasePiece(pair{col: to.col, row: from.row})
 	}
 
 	// update chessboard hidden properties
 	// update castling rights
-	if fromPiece == BKING {
-		c.BlackKingCastle = false
-		c.BlackQueenCastle = false
-	}
-
-	if c.getPiece(sq("h8")) != BROOK {
-
-		fmt.Println(sq("h8"))
-		c.BlackKingCastle = false
-	}
-	if c.getPiece(sq("a8")) != BROOK {
-		c.BlackQueenCastle = false
-	}
-	if fromPiece == WKING {
-		c.WhiteKingCastle = false
-		c.WhiteQueenCastle = false
-	}
-
-	if c.getPiece(sq("h1")) != WROOK {
-		c.WhiteKingCastle = false
-	}
-	if c.getPiece(sq("a1")) != WROOK {
-		c.WhiteQueenCastle = false
-	}
+	c.updateCastlingRights(fromPiece)
 
 	// HalfmoveClock update
 	if fromPiece == WPAWN || fromPiece == BPAWN || toPiece != 0 {
@@ -987,6 +1026,32 @@ func (c *Chessboard) MakeMove(move string) error {
 		c.HalfmoveClock++
 	}
 
+	c.passTurn()
+
+	// two step pawn en passant update
+	c.EnPassantSquare = pair{}
+	if (fromPiece == WPAWN || fromPiece == BPAWN) &&
+		(from.row-to.row == 2 || from.row-to.row == -2) {
+		c.EnPassantSquare = pair{col: from.col, row: (from.row + to.row) / 2}
+	}
+}
+
+// updateCastlingRights drops the castling rights lost because moved, the
+// piece that just moved, is the king, or because a rook left its square
+func (c *Chessboard) updateCastlingRights(moved int) {
+	for i, right := range c.castlingRights() {
+		king, rook, homeRow := WKING, WROOK, int8(0)
+		if i >= 2 {
+			king, rook, homeRow = BKING, BROOK, 7
+		}
+		if moved == king || !c.hasPiece(pair{col: c.castlingRooks[i], row: homeRow}, rook) {
+			*right = false
+		}
+	}
+}
+
+// passTurn updates the FullmoveCounter and whose turn it is
+func (c *Chessboard) passTurn() {
 	// FullmoveCounter update
 	if !(c.WhiteToMove) {
 		c.FullmoveCounter++
@@ -994,15 +1059,6 @@ func (c *Chessboard) MakeMove(move string) error {
 
 	// Update Whose turn it is
 	c.WhiteToMove = !(c.WhiteToMove)
-
-	// two step pawn en passant update
-	c.EnPassantSquare = pair{}
-	if (fromPiece == WPAWN || fromPiece == BPAWN) &&
-		(from.col-to.col == 2 || from.col-to.col == -2) {
-		c.EnPassantSquare = addPair(from, pair{col: (from.col - to.col) / 2})
-	}
-
-	return nil
 }
 
 func (c *Chessboard) DoSomething() {
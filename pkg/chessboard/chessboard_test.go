@@ -1,7 +1,15 @@
 package chessboard
 
 import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/kahnaisehC/chessboard/pkg/clock"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
 )
 
 func TestAddPair(t *testing.T) {
@@ -18,4 +26,814 @@ func TestAddPair(t *testing.T) {
 
 func TestCreateChessgame(t *testing.T) {
 	chessgame := CreateChessboard("new game")
+	if chessgame.GetFEN() != initialFEN {
+		t.Errorf(`CreateChessboard("new game").GetFEN() = %s, should fall back to %s`, chessgame.GetFEN(), initialFEN)
+	}
+
+	FEN := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+	chessgame = CreateChessboard(FEN)
+	if chessgame.GetFEN() != FEN {
+		t.Errorf(`CreateChessboard(%s).GetFEN() = %s`, FEN, chessgame.GetFEN())
+	}
+}
+
+func perft(c Chessboard, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	nodes := 0
+	for _, move := range c.GetMoveList() {
+		next := c
+		next.makeMove(move)
+		nodes += perft(next, depth-1)
+	}
+	return nodes
+}
+
+func TestPerft(t *testing.T) {
+	// https://www.chessprogramming.org/Perft_Results
+	tests := []struct {
+		FEN   string
+		depth int
+		nodes int
+	}{
+		{initialFEN, 3, 8902},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2, 2039},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 3, 2812},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 2, 264},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 2, 1486},
+	}
+	for _, test := range tests {
+		if nodes := perft(CreateChessboard(test.FEN), test.depth); nodes != test.nodes {
+			t.Errorf("perft(%s, %d) = %d, should equal %d", test.FEN, test.depth, nodes, test.nodes)
+		}
+	}
+}
+
+func TestSAN(t *testing.T) {
+	c := CreateChessboard("")
+	game := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Ba4", "Nf6", "O-O", "Be7", "Re1", "b5", "Bb3", "d6", "c3", "O-O", "h3", "Nb8", "d4", "Nbd7"}
+	for _, san := range game {
+		move, err := c.MoveFromSAN(san)
+		if err != nil {
+			t.Fatalf("MoveFromSAN(%s): %v", san, err)
+		}
+		if got := c.GetSAN(move); got != san {
+			t.Errorf("GetSAN(%v) = %s, should equal %s", move, got, san)
+		}
+		c.MakeSANMove(san)
+	}
+	if c.Moves[8] != "e1g1" {
+		t.Errorf("c.Moves[8] = %s, should equal e1g1", c.Moves[8])
+	}
+
+	c = CreateChessboard("7k/P7/8/8/8/8/8/R3K2R w KQ - 0 1")
+	if err := c.MakeMove("0a7a8Q"); err != nil {
+		t.Fatalf(`MakeMove("0a7a8Q"): %v`, err)
+	}
+	if c.GetFEN() != "Q6k/8/8/8/8/8/8/R3K2R b KQ - 0 1" {
+		t.Errorf("GetFEN() after promoting = %s", c.GetFEN())
+	}
+}
+
+func TestHash(t *testing.T) {
+	c := CreateChessboard("")
+	hash := c.Hash()
+
+	c.WhiteToMove = !c.WhiteToMove
+	if c.Hash() == hash {
+		t.Errorf("Hash() did not change with the side to move")
+	}
+	c.WhiteToMove = !c.WhiteToMove
+
+	c.erasePiece(sq("e2"))
+	c.putPiece(sq("e4"), WPAWN)
+	if c.Hash() == hash {
+		t.Errorf("Hash() did not change after moving a pawn")
+	}
+	c.erasePiece(sq("e4"))
+	c.putPiece(sq("e2"), WPAWN)
+	if c.Hash() != hash {
+		t.Errorf("Hash() = %x after restoring the position, should equal %x", c.Hash(), hash)
+	}
+}
+
+func TestChess960(t *testing.T) {
+	for index, want := range map[int]string{0: "bbqnnrkr", 518: "rnbqkbnr", 959: "rkrnnqbb"} {
+		FEN, err := Chess960FEN(index)
+		if err != nil || !strings.HasPrefix(FEN, want+"/") {
+			t.Errorf("Chess960FEN(%d) = %s, %v, should start with %s", index, FEN, err, want)
+		}
+	}
+	if _, err := Chess960FEN(960); err == nil {
+		t.Errorf("Chess960FEN(960) should fail")
+	}
+
+	// https://www.chessprogramming.org/Chess960_Perft_Results
+	tests := []struct {
+		FEN   string
+		depth int
+		nodes int
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", 3, 12189},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", 3, 13440},
+		{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", 3, 31058},
+	}
+	for _, test := range tests {
+		c := CreateChessboard(test.FEN)
+		if c.GetShredderFEN() != test.FEN {
+			t.Errorf("CreateChessboard(%s).GetShredderFEN() = %s", test.FEN, c.GetShredderFEN())
+		}
+		if nodes := perft(c, test.depth); nodes != test.nodes {
+			t.Errorf("perft(%s, %d) = %d, should equal %d", test.FEN, test.depth, nodes, test.nodes)
+		}
+	}
+
+	// the king castles by taking its rook, ending on c1 like in standard chess
+	c := CreateChessboard("r3k1r1/8/8/8/8/8/8/RK5R w HAga - 0 1")
+	if FEN := c.GetFEN(); FEN != "r3k1r1/8/8/8/8/8/8/RK5R w KQkq - 0 1" {
+		t.Errorf("X-FEN = %s", FEN)
+	}
+	if err := c.MakeUCIMove("b1a1"); err != nil {
+		t.Fatal(err)
+	}
+	if FEN := c.GetFEN(); FEN != "r3k1r1/8/8/8/8/8/8/2KR3R b kq - 1 1" {
+		t.Errorf("after b1a1 FEN = %s", FEN)
+	}
+	c.Chess960 = true
+	if err := c.MakeSANMove("O-O"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Moves[1] != "e8g8" || c.GetFEN() != "r4rk1/8/8/8/8/8/8/2KR3R w - - 2 2" {
+		t.Errorf("after O-O moves = %v, FEN = %s", c.Moves, c.GetFEN())
+	}
+
+	// Shredder-FEN of a standard position is standard chess
+	c = CreateChessboard("r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1")
+	if err := c.MakeSANMove("O-O"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Chess960 || c.Moves[0] != "e1g1" {
+		t.Errorf("HAha: Chess960 = %t, O-O = %s", c.Chess960, c.Moves[0])
+	}
+}
+
+// noCastling is standard chess without castling. Its FEN ends in " nc"
+type noCastling struct{ Standard }
+
+func (noCastling) Name() string { return "No castling" }
+
+func (noCastling) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	return Standard{}.ParseFEN(c, strings.TrimSuffix(FEN, " nc"))
+}
+
+func (noCastling) WriteFEN(c *Chessboard, FEN string) string { return FEN + " nc" }
+
+func (noCastling) PseudoLegalMoves(c *Chessboard, moves []Move) []Move {
+	var allowed []Move
+	for _, move := range moves {
+		if !move.castling {
+			allowed = append(allowed, move)
+		}
+	}
+	return allowed
+}
+
+func TestVariant(t *testing.T) {
+	RegisterVariant(noCastling{})
+	variant, ok := VariantByName("No castling")
+	if !ok {
+		t.Fatalf("VariantByName(No castling) not found in %v", Variants())
+	}
+
+	FEN := "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 nc"
+	c, err := CreateVariantChessboard(variant, FEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetFEN() != FEN {
+		t.Errorf("GetFEN() = %s, should equal %s", c.GetFEN(), FEN)
+	}
+	if err := c.MakeSANMove("O-O"); err == nil {
+		t.Errorf("O-O should be illegal")
+	}
+	if len(c.GetMoveList()) != 24 {
+		t.Errorf("len(GetMoveList()) = %d, should equal 24", len(c.GetMoveList()))
+	}
+
+	c = CreateChessboard("")
+	for _, san := range []string{"f3", "e5", "g4", "Qh4"} {
+		if result := c.GetResult(); result != ONGOING {
+			t.Fatalf("GetResult() before %s = %s", san, result)
+		}
+		c.MakeSANMove(san)
+	}
+	if c.GetResult() != BLACKWINS {
+		t.Errorf("GetResult() after fool's mate = %s, should be 0-1", c.GetResult())
+	}
+}
+
+func TestCrazyhouse(t *testing.T) {
+	c, _ := CreateVariantChessboard(Crazyhouse{}, "")
+	for _, san := range []string{"e4", "d5", "exd5", "Qxd5"} {
+		if err := c.MakeSANMove(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if FEN := c.GetFEN(); FEN != "rnb1kbnr/ppp1pppp/8/3q4/8/8/PPPP1PPP/RNBQKBNR[Pp] w KQkq - 0 3" {
+		t.Errorf("FEN after 2... Qxd5 = %s", FEN)
+	}
+	if _, err := c.MoveFromSAN("P@e8"); err == nil {
+		t.Errorf("pawns can't be dropped on the last row")
+	}
+	hash := c.Hash()
+	if err := c.MakeSANMove("@c4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MakeUCIMove("N@f6"); err == nil {
+		t.Errorf("black has no knight to drop")
+	}
+	if c.Moves[4] != "P@c4" || c.Pockets[WPAWN] != 0 || c.Hash() == hash {
+		t.Errorf("after P@c4 moves = %v, pockets = %v", c.Moves, c.Pockets)
+	}
+
+	// a promoted piece goes back to the pocket as a pawn
+	c, err := CreateVariantChessboard(Crazyhouse{}, "7r/1P2k3/8/8/8/8/8/4K3/ w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MakeSANMove("b8=Q")
+	if FEN := c.GetFEN(); FEN != "1Q~5r/4k3/8/8/8/8/8/4K3[] b - - 0 1" {
+		t.Errorf("FEN after b8=Q = %s", FEN)
+	}
+	c.MakeSANMove("Rxb8")
+	if FEN := c.GetFEN(); FEN != "1r6/4k3/8/8/8/8/8/4K3[p] w - - 0 2" {
+		t.Errorf("FEN after Rxb8 = %s", FEN)
+	}
+	if again, _ := CreateVariantChessboard(Crazyhouse{}, "1Q~5r/4k3/8/8/8/8/8/4K3[] b - - 0 1"); again.Promoted != 1<<57 {
+		t.Errorf("promoted pieces read from FEN = %x, should be b8", again.Promoted)
+	}
+}
+
+func TestBughouse(t *testing.T) {
+	b := NewBughousePairing()
+	for _, move := range []string{"e2e4", "d7d5", "e4d5"} {
+		if err := b.MakeMove(0, move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// white took a black pawn on board 0, it goes to black on board 1
+	if FEN := b.Boards[1].GetFEN(); FEN != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[p] w KQkq - 0 1" {
+		t.Errorf("board 1 FEN = %s", FEN)
+	}
+	if b.Boards[0].Pockets != [13]int{} {
+		t.Errorf("board 0 pockets = %v, should be empty", b.Boards[0].Pockets)
+	}
+	b.MakeSANMove(1, "e4")
+	if err := b.MakeSANMove(1, "P@d4"); err != nil {
+		t.Error(err)
+	}
+	if _, result := b.GetResult(); result != ONGOING {
+		t.Errorf("GetResult() = %s, should be ongoing", result)
+	}
+}
+
+func TestCasualVariants(t *testing.T) {
+	c, err := CreateVariantChessboard(ThreeCheck{}, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 3+2 0 1")
+	if err != nil || c.GetFEN() != initialFEN+" +0+1" {
+		t.Errorf("three-check FEN = %s, %v", c.GetFEN(), err)
+	}
+	c, _ = CreateVariantChessboard(ThreeCheck{}, "rnbqkbnr/ppp2ppp/8/3pp3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3 +2+0")
+	c.MakeSANMove("Bb5+")
+	if c.WhiteChecksGiven != 3 || c.GetResult() != WHITEWINS || len(c.GetMoveList()) != 0 {
+		t.Errorf("after the third check: checks = %d, result = %s", c.WhiteChecksGiven, c.GetResult())
+	}
+
+	c, _ = CreateVariantChessboard(KingOfTheHill{}, "4k3/8/8/8/8/4K3/8/8 w - - 0 1")
+	c.MakeSANMove("Kd4")
+	if c.GetResult() != WHITEWINS {
+		t.Errorf("king of the hill result = %s, should be 1-0", c.GetResult())
+	}
+
+	c, _ = CreateVariantChessboard(RacingKings{}, "")
+	if nodes := perft(c, 3); nodes != 11264 {
+		t.Errorf("racing kings perft(3) = %d, should equal 11264", nodes)
+	}
+	for _, move := range c.GetMoveList() {
+		next := c
+		next.makeMove(move)
+		if next.InCheck() {
+			t.Errorf("racing kings move %s gives check", move)
+		}
+	}
+	c, _ = CreateVariantChessboard(RacingKings{}, "7K/8/k7/8/8/8/8/8 b - - 0 1")
+	if c.GetResult() != WHITEWINS {
+		t.Errorf("racing kings result = %s, should be 1-0 when black can't catch up", c.GetResult())
+	}
+	c, _ = CreateVariantChessboard(RacingKings{}, "7K/k7/8/8/8/8/8/8 b - - 0 1")
+	if c.GetResult() != ONGOING {
+		t.Errorf("racing kings result = %s, black can still draw", c.GetResult())
+	}
+	c.MakeSANMove("Kb8")
+	if c.GetResult() != DRAW {
+		t.Errorf("racing kings result = %s, should be a draw", c.GetResult())
+	}
+}
+
+func TestCapturingVariants(t *testing.T) {
+	// https://github.com/niklasf/python-chess/tree/master/examples/perft
+	tests := []struct {
+		variant Variant
+		FEN     string
+		depth   int
+		nodes   int
+	}{
+		{Atomic{}, "", 4, 197326},
+		{Antichess{}, "", 4, 153299},
+		{Horde{}, "", 4, 23310},
+	}
+	for _, test := range tests {
+		c, err := CreateVariantChessboard(test.variant, test.FEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nodes := perft(c, test.depth); nodes != test.nodes {
+			t.Errorf("%s perft(%s, %d) = %d, should equal %d", test.variant.Name(), c.GetFEN(), test.depth, nodes, test.nodes)
+		}
+	}
+	c, _ := CreateVariantChessboard(Atomic{}, "")
+	for _, san := range []string{"e4", "d5", "exd5"} {
+		c.MakeSANMove(san)
+	}
+	if FEN := c.GetFEN(); FEN != "rnbqkbnr/ppp1pppp/8/8/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2" {
+		t.Errorf("atomic FEN after exd5 = %s, both pawns should explode", FEN)
+	}
+	c, _ = CreateVariantChessboard(Atomic{}, "4k3/3p4/8/8/8/8/8/3QK3 w - - 0 1")
+	c.MakeSANMove("Qxd7")
+	if c.GetResult() != WHITEWINS {
+		t.Errorf("atomic result = %s after blowing up the black king", c.GetResult())
+	}
+
+	c, _ = CreateVariantChessboard(Antichess{}, "")
+	c.MakeSANMove("e3")
+	c.MakeSANMove("b5")
+	if moves := c.GetMoveList(); len(moves) != 1 || c.GetSAN(moves[0]) != "Bxb5" {
+		t.Errorf("antichess moves = %v, Bxb5 is compulsory", moves)
+	}
+
+	c, err := CreateVariantChessboard(Horde{}, "4k3/8/8/8/8/8/8/P7 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MakeUCIMove("a1a3"); err != nil || c.GetFEN() != "4k3/8/8/8/8/P7/8/8 b - - 0 1" {
+		t.Errorf("horde FEN after a1a3 = %s, %v", c.GetFEN(), err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		FEN     string
+		result  Result
+	}{
+		{Standard{}, "4k3/8/8/8/8/8/8/3QK3 b - - 0 1", WHITEWINS},
+		// white runs out of time but black can't mate with a lone king
+		{Standard{}, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", DRAW},
+		{Standard{}, "4k3/8/8/8/8/8/8/4KN2 b - - 0 1", DRAW},
+		{Standard{}, "4kb2/8/8/8/8/8/8/2B1K3 b - - 0 1", DRAW},
+		{Standard{}, "4k1b1/8/8/8/8/8/8/2B1K3 b - - 0 1", WHITEWINS},
+		// unless it has a piece to drop
+		{Crazyhouse{}, "4k3/8/8/8/8/8/8/3QK3[q] w - - 0 1", BLACKWINS},
+		{Crazyhouse{}, "4k3/8/8/8/8/8/8/4K3[n] w - - 0 1", DRAW},
+		{Crazyhouse{}, "4k3/8/8/8/8/8/8/4K3[bb] w - - 0 1", BLACKWINS},
+	}
+	for _, test := range tests {
+		source := &clock.ManualTime{}
+		c, err := CreateVariantChessboard(test.variant, test.FEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Clock = clock.New(clock.TimeControl{Periods: []clock.Period{{Time: time.Minute}}}, source)
+		c.Clock.Start(c.WhiteToMove)
+
+		source.Advance(time.Minute)
+		if result := c.GetResult(); result != test.result {
+			t.Errorf("%s: GetResult() on time = %s, should be %s", test.FEN, result, test.result)
+		}
+		if err := c.MakeUCIMove(c.GetMoveList()[0].String()); err != clock.ErrFlagFell {
+			t.Errorf("%s: moving after the flag fell = %v", test.FEN, err)
+		}
+	}
+}
+
+func TestGame(t *testing.T) {
+	board := CreateChessboard("")
+	board.PGNTags.Extra = []pgntags.Tag{{Name: "Annotator", Value: "Ann"}}
+	g := NewGame(board)
+	events, unsubscribe := g.Subscribe(1, DROPOLDEST)
+
+	if err := g.Move("e2e4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Move("e5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Move("Ke3"); err == nil {
+		t.Errorf("Ke3 should be illegal")
+	}
+	// the buffer holds one event, the latest
+	if event := <-events; event.Type != MOVEMADE || event.Ply != 2 || event.SAN != "e5" {
+		t.Errorf("subscriber got %+v, should get e5", event)
+	}
+
+	snapshot := g.Snapshot()
+	snapshot.Moves[0] = "a2a3"
+	snapshot.PGNTags.Extra[0].Value = "Bob"
+	if err := g.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if board := g.Snapshot(); board.Moves[0] != "e2e4" || board.GetFEN() != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1" {
+		t.Errorf("after undo moves = %v, FEN = %s", board.Moves, board.GetFEN())
+	}
+	if board := g.Snapshot(); board.PGNTags.Extra[0].Value != "Ann" {
+		t.Errorf("changing a snapshot changed the tags of the game: %v", board.PGNTags.Extra)
+	}
+	unsubscribe()
+	unsubscribe()
+	for range events {
+		// the takeback, then the channel is closed
+	}
+
+	// players and spectators at the same time, run with -race
+	var wg sync.WaitGroup
+	for _, moves := range [][]string{{"d4", "c4", "Nc3"}, {"d5", "c6", "Nf6"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, move := range moves {
+				for g.Move(move) != nil {
+					// wait for the other side
+				}
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				board := g.Snapshot()
+				board.GetFEN()
+				g.Result()
+			}
+		}()
+	}
+	wg.Wait()
+	if moves := g.Snapshot().Moves; len(moves) != 7 {
+		t.Errorf("moves = %v, should be 7 long", moves)
+	}
+}
+
+func TestGameEvents(t *testing.T) {
+	source := &clock.ManualTime{}
+	board := CreateChessboard("")
+	board.Clock = clock.New(clock.TimeControl{Periods: []clock.Period{{Time: time.Minute}}}, source)
+	g := NewGame(board)
+	events, unsubscribe := g.Subscribe(0, BLOCK)
+	defer unsubscribe()
+
+	var got []Event
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			got = append(got, event)
+		}
+		close(done)
+	}()
+
+	steps := []func() error{
+		func() error { return g.Move("f3") },
+		func() error { return g.OfferDraw(WHITE) },
+		func() error { return g.Move("e5") }, // declines
+		func() error { return g.RequestTakeback(WHITE) },
+		func() error { return g.AcceptTakeback(BLACK) },
+		func() error { return g.Move("f2f4") },
+		func() error { return g.Move("e5") },
+		func() error { return g.Move("g4") },
+		func() error { g.Tick(); return nil },
+		func() error { return g.Move("Qh4#") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		source.Advance(time.Second)
+	}
+	if err := g.AcceptDraw(BLACK); err == nil {
+		t.Errorf("accepting a declined draw should fail")
+	}
+	if err := g.Move("a3"); err == nil {
+		t.Errorf("moving after mate should fail")
+	}
+	unsubscribe()
+	<-done
+
+	want := []EventType{
+		MOVEMADE, DRAWOFFER, MOVEMADE, TAKEBACKREQUEST, TAKEBACK,
+		MOVEMADE, MOVEMADE, MOVEMADE, CLOCKTICK, MOVEMADE, CHECK, GAMEOVER,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %+v, should be %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i].Type != want[i] {
+			t.Errorf("event %d is %d, should be %d", i, got[i].Type, want[i])
+		}
+	}
+	if takeback := got[4]; takeback.Ply != 0 || takeback.FEN != initialFEN {
+		t.Errorf("takeback of both moves = %+v", takeback)
+	}
+	if mate := got[9]; mate.SAN != "Qh4#" || mate.UCI != "d8h4" || mate.White || mate.Hash == 0 ||
+		mate.FEN != "rnb1kbnr/pppp1ppp/8/4p3/5PPq/8/PPPPP2P/RNBQKBNR w KQkq - 1 3" {
+		t.Errorf("mate = %+v", mate)
+	}
+	// a second between steps, the takeback gives no time back
+	if tick := got[8]; tick.WhiteTime != 56*time.Second || tick.BlackTime != 56*time.Second {
+		t.Errorf("tick = %+v", tick)
+	}
+	if over := got[11]; over.Result != BLACKWINS {
+		t.Errorf("result = %s", over.Result)
+	}
+
+	// callbacks, a draw by agreement and a full queue
+	g = NewGame(CreateChessboard(""))
+	results := make(chan Result, 1)
+	stop := g.OnEvent(func(event Event) {
+		if event.Type == GAMEOVER {
+			results <- event.Result
+		}
+	}, 8, BLOCK)
+	defer stop()
+	slow, unsubscribe := g.Subscribe(1, DROPNEWEST)
+	defer unsubscribe()
+	g.Move("e4")
+	g.OfferDraw(BLACK)
+	if err := g.AcceptDraw(WHITE); err != nil {
+		t.Fatal(err)
+	}
+	if result := <-results; result != DRAW || g.Result() != DRAW {
+		t.Errorf("agreed draw = %s", result)
+	}
+	if err := g.Undo(); err == nil {
+		t.Errorf("undoing a move after the game is over should fail")
+	}
+	if err := g.RequestTakeback(WHITE); err == nil {
+		t.Errorf("asking for a takeback after the game is over should fail")
+	}
+	if event := <-slow; event.Type != MOVEMADE {
+		t.Errorf("DROPNEWEST should keep the first event, got %+v", event)
+	}
+
+	// taking back one ply gives the turn on the clock back
+	source = &clock.ManualTime{}
+	board = CreateChessboard("")
+	board.Clock = clock.New(clock.TimeControl{Periods: []clock.Period{{Time: time.Minute}}}, source)
+	g = NewGame(board)
+	g.Move("e4")
+	source.Advance(time.Second)
+	if err := g.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Move("d4"); err != nil {
+		t.Fatalf("moving after an undo on a clock: %v", err)
+	}
+	source.Advance(2 * time.Second)
+	g.RequestTakeback(WHITE)
+	if err := g.AcceptTakeback(BLACK); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Move("c4"); err != nil {
+		t.Fatalf("moving after a takeback on a clock: %v", err)
+	}
+	if white, black := board.Clock.Remaining(WHITE), board.Clock.Remaining(BLACK); white != time.Minute || black != 57*time.Second {
+		t.Errorf("after the takebacks white has %v and black %v, should be 1m0s and 57s", white, black)
+	}
+
+	// the clock of a snapshot is a copy
+	g.Snapshot().Clock.Stop()
+	source.Advance(time.Second)
+	if black := board.Clock.Remaining(BLACK); black != 56*time.Second {
+		t.Errorf("stopping the clock of a snapshot stopped the game's, black has %v", black)
+	}
+}
+
+func TestGetPGN(t *testing.T) {
+	c := CreateChessboard("")
+	c.PGNTags.White = "Ann"
+	c.PGNTags.Set("Annotator", "Bob")
+	for _, san := range []string{"f3", "e5", "g4", "Qh4#"} {
+		c.MakeSANMove(san)
+	}
+	want := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "Ann"]
+[Black "?"]
+[Result "0-1"]
+[Annotator "Bob"]
+
+1. f3 e5 2. g4 Qh4# 0-1
+`
+	if got := c.GetPGN(); got != want {
+		t.Errorf("GetPGN():\n%s\nshould be\n%s", got, want)
+	}
+
+	// from a position, black to move, with a clock and a result that isn't on the board
+	c = CreateChessboard("4k3/8/8/8/8/8/4P3/4K3 b - - 0 10")
+	control, _ := clock.ParseTimeControl("300+2")
+	c.Clock = clock.New(control, nil)
+	c.MakeSANMove("Kd7")
+	c.MakeSANMove("e4")
+	c.PGNTags.Result = "1-0"
+	pgn := c.GetPGN()
+	for _, part := range []string{
+		"[Result \"1-0\"]\n[SetUp \"1\"]\n[FEN \"4k3/8/8/8/8/8/4P3/4K3 b - - 0 10\"]\n[TimeControl \"300+2\"]\n",
+		"\n10... Kd7 11. e4 1-0\n",
+	} {
+		if !strings.Contains(pgn, part) {
+			t.Errorf("GetPGN() should have %q:\n%s", part, pgn)
+		}
+	}
+	if len(c.PGNTags.Extra) != 0 {
+		t.Errorf("GetPGN changed the tags: %+v", c.PGNTags.Extra)
+	}
+
+	// movetext lines are 79 characters at most
+	c = CreateChessboard("")
+	for i := 0; i < 20; i++ {
+		for _, san := range []string{"Nf3", "Nf6", "Ng1", "Ng8"} {
+			c.MakeSANMove(san)
+		}
+	}
+	lines := strings.Split(c.GetPGN(), "\n")
+	if len(lines) < 12 || !strings.HasPrefix(lines[8], "1. Nf3 Nf6 2. Ng1 Ng8") {
+		t.Fatalf("GetPGN() of a long game:\n%s", strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		if len(line) > 79 {
+			t.Errorf("line of %d characters: %s", len(line), line)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// every legal move, promotions, castling and drops included, decodes to
+	// itself and every other code fails
+	positions := []Chessboard{
+		CreateChessboard("r3k2r/1P4P1/8/8/8/8/8/R3K2R w KQkq - 0 1"),
+		CreateChessboard("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1"),
+		// the knight is pinned, the king is in check
+		CreateChessboard("4k3/4r3/8/8/8/8/4N3/4K2b w - - 0 1"),
+	}
+	crazyhouse, _ := CreateVariantChessboard(Crazyhouse{}, "4k3/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1")
+	// the capture is compulsory
+	antichess, _ := CreateVariantChessboard(Antichess{}, "8/8/8/3p4/4P3/8/8/8 w - - 0 1")
+	positions = append(positions, crazyhouse, antichess)
+	for _, c := range positions {
+		codes := map[uint16]bool{}
+		for _, m := range c.GetMoveList() {
+			code := m.Encode()
+			if codes[code] {
+				t.Errorf("%s: two moves encode to %d", c.GetFEN(), code)
+			}
+			codes[code] = true
+			if decoded, err := c.DecodeMove(code); err != nil || decoded != m {
+				t.Errorf("%s: %s decodes to %s, %v", c.GetFEN(), m, decoded, err)
+			}
+		}
+		for code := 0; code < 1<<16; code++ {
+			if _, err := c.DecodeMove(uint16(code)); err == nil && !codes[uint16(code)] {
+				t.Errorf("%s: %d decodes to a move that isn't legal", c.GetFEN(), code)
+			}
+		}
+	}
+	if len(crazyhouse.GetMoveList()) <= 256 {
+		t.Fatalf("the crazyhouse position should have more than 256 moves")
+	}
+
+	chess960 := CreateChessboard("rk2r3/pppppppp/8/8/8/8/PPPPPPPP/RK2R3 w EAea - 0 1")
+	tests := []struct {
+		start Chessboard
+		moves []string
+		size  int
+	}{
+		{CreateChessboard(""), []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6", "e1g1"}, 3 + 7},
+		{positions[0], []string{"g7h8q", "e8d7", "b7b8n"}, 4 + len(positions[0].GetFEN()) + 3},
+		{chess960, []string{"b1a1", "b8e8"}, 0},
+		{crazyhouse, []string{"Q@e2", "e8d7", "P@d2", "P@e3"}, 0},
+	}
+	for _, test := range tests {
+		want := test.start
+		for _, uci := range test.moves {
+			if err := want.MakeUCIMove(uci); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := EncodeGame(test.start, test.moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.size != 0 && len(data) != test.size {
+			t.Errorf("%v takes %d bytes, should take %d", test.moves, len(data), test.size)
+		}
+		got, err := DecodeGame(data)
+		if err != nil {
+			t.Errorf("decoding %v: %v", test.moves, err)
+			continue
+		}
+		if got.GetFEN() != want.GetFEN() || strings.Join(got.Moves, " ") != strings.Join(want.Moves, " ") ||
+			got.Chess960 != want.Chess960 || got.Variant != want.Variant {
+			t.Errorf("decoded %s %v, should be %s %v", got.GetFEN(), got.Moves, want.GetFEN(), want.Moves)
+		}
+		if _, err := DecodeGame(data[:len(data)-1]); err == nil {
+			t.Errorf("decoding a truncated game should fail")
+		}
+	}
+
+	if _, err := EncodeGame(CreateChessboard(""), []string{"e2e5"}); err == nil {
+		t.Errorf("encoding an illegal move should fail")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	c := CreateChessboard("")
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6"} {
+		c.MakeSANMove(san)
+	}
+	c.PGNTags.White = "Ann"
+	c.PGNTags.Set("Opening", "Four Knights")
+	c.PGNTags.Set("Annotator", "Cid")
+	control, _ := clock.ParseTimeControl("300+2")
+	c.Clock = clock.New(control, nil)
+	c.Clock.Adjust(290*time.Second, 295*time.Second)
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fen":"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",` +
+		`"moves":[{"uci":"e2e4","san":"e4"},{"uci":"e7e5","san":"e5"},{"uci":"g1f3","san":"Nf3"},{"uci":"b8c6","san":"Nc6"}],` +
+		`"tags":[{"name":"White","value":"Ann"},{"name":"Opening","value":"Four Knights"},{"name":"Annotator","value":"Cid"}],"result":"*","clock":{"timeControl":"300+2","white":290000,"black":295000}}`
+	if string(data) != want {
+		t.Errorf("JSON:\n%s\nshould be\n%s", data, want)
+	}
+	var fromJSON Chessboard
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	check := func(how string, got Chessboard) {
+		if got.GetFEN() != c.GetFEN() || strings.Join(got.Moves, " ") != strings.Join(c.Moves, " ") ||
+			got.PGNTags.White != "Ann" || !slices.Equal(got.PGNTags.Extra, c.PGNTags.Extra) ||
+			got.Clock == nil || got.Clock.Remaining(WHITE) != 290*time.Second || got.Clock.Remaining(BLACK) != 295*time.Second {
+			t.Errorf("%s: %s %v %+v", how, got.GetFEN(), got.Moves, got.PGNTags)
+		}
+	}
+	check("JSON", fromJSON)
+
+	data, err = c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBinary Chessboard
+	if err := fromBinary.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	check("binary", fromBinary)
+	if err := fromBinary.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("a truncated binary board should fail")
+	}
+
+	// a variant that doesn't start from its usual position, the FEN as text
+	koth, _ := CreateVariantChessboard(KingOfTheHill{}, "4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	koth.MakeUCIMove("e1d2")
+	data, _ = json.Marshal(koth)
+	var again Chessboard
+	if err := json.Unmarshal(data, &again); err != nil || again.Variant != koth.Variant || again.GetFEN() != koth.GetFEN() {
+		t.Errorf("%s unmarshals to %s, %v", data, again.GetFEN(), err)
+	}
+	if err := json.Unmarshal([]byte(`{"fen":"8/8/8/8/8/8/8/8 w - - 0 1","moves":[{"uci":"e2e4"}]}`), &again); err == nil {
+		t.Errorf("moves that don't lead to the FEN should fail")
+	}
+	text, _ := again.MarshalText()
+	var fromText Chessboard
+	if err := fromText.UnmarshalText(text); err != nil || fromText.GetFEN() != again.GetFEN() {
+		t.Errorf("text %s unmarshals to %s, %v", text, fromText.GetFEN(), err)
+	}
+
+	// moves are written in UCI
+	m, _ := c.MoveFromSAN("Bb5")
+	if data, _ := json.Marshal([]Move{m}); string(data) != `["f1b5"]` {
+		t.Errorf("moves as JSON: %s", data)
+	}
 }
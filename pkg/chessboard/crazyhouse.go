@@ -0,0 +1,197 @@
+package chessboard
+
+import (
+	"errors"
+	"math/bits"
+	"strings"
+)
+
+// MAXPOCKET is the most pieces of one kind a pocket can hash apart. Bughouse
+// pockets can hold more, they hash like MAXPOCKET
+const MAXPOCKET = 16
+
+// pawns can't be dropped on the first or last row
+const pawnDropMask = ^uint64(0xff | 0xff<<56)
+
+// Crazyhouse: captured pieces go to the capturer's pocket, changing color,
+// and can be dropped back on any empty square instead of moving. Promoted
+// pieces go back to the pocket as pawns.
+// FEN writes pockets after the position, "RNBQKBNR[Qp]", and marks promoted
+// pieces with a "~". A ninth row, "RNBQKBNR/Qp", is read too
+type Crazyhouse struct{ Standard }
+
+func init() {
+	RegisterVariant(Crazyhouse{})
+}
+
+func (Crazyhouse) Name() string { return "Crazyhouse" }
+func (Crazyhouse) StartingFEN() string {
+	return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"
+}
+
+func (Crazyhouse) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	return parsePocketFEN(c, FEN)
+}
+
+func (Crazyhouse) WriteFEN(c *Chessboard, FEN string) string {
+	return writePocketFEN(c, FEN)
+}
+
+func (Crazyhouse) PseudoLegalMoves(c *Chessboard, moves []Move) []Move {
+	return append(moves, c.dropMoves()...)
+}
+
+func (Crazyhouse) AfterMove(before *Chessboard, m Move, c *Chessboard) {
+	captured, promoted := trackPockets(before, m, c)
+	if captured != 0 {
+		if promoted {
+			captured = WPAWN
+		}
+		c.Pockets[pieceColor(uncolored(captured), before.WhiteToMove)]++
+	}
+}
+
+// dropMoves returns the drops of the side to move
+func (c *Chessboard) dropMoves() []Move {
+	var moves []Move
+	empty := ^c.occupied()
+	for piece := WQUEEN; piece <= WPAWN; piece++ {
+		colored := pieceColor(piece, c.WhiteToMove)
+		if c.Pockets[colored] == 0 {
+			continue
+		}
+		squares := empty
+		if piece == WPAWN {
+			squares &= pawnDropMask
+		}
+		for ; squares != 0; squares &= squares - 1 {
+			moves = append(moves, Move{to: intToPair(bits.TrailingZeros64(squares)), drop: colored})
+		}
+	}
+	return moves
+}
+
+// trackPockets takes dropped pieces out of the pocket and keeps c.Promoted up
+// to date after m. It returns the piece m captured, if any, and if it was promoted
+func trackPockets(before *Chessboard, m Move, c *Chessboard) (captured int, promoted bool) {
+	if m.drop != 0 {
+		c.Pockets[m.drop]--
+		return 0, false
+	}
+	captured, square := before.capturedBy(m)
+	if captured != 0 {
+		promoted = before.Promoted&(1<<pairToInt(square)) != 0
+		c.Promoted &^= 1 << pairToInt(square)
+	}
+	if before.Promoted&(1<<pairToInt(m.from)) != 0 || m.promotion != 0 {
+		c.Promoted &^= 1 << pairToInt(m.from)
+		c.Promoted |= 1 << pairToInt(m.to)
+	}
+	return captured, promoted
+}
+
+// capturedBy returns the piece m captures on c and its square, 0 if m isn't a capture
+func (c *Chessboard) capturedBy(m Move) (piece int, square pair) {
+	if m.drop != 0 || m.castling {
+		return 0, pair{}
+	}
+	if piece := c.getPiece(m.to); piece != 0 {
+		return piece, m.to
+	}
+	// en passant
+	fromPiece := c.getPiece(m.from)
+	if (fromPiece == WPAWN || fromPiece == BPAWN) && m.from.col != m.to.col {
+		square := pair{col: m.to.col, row: m.from.row}
+		return c.getPiece(square), square
+	}
+	return 0, pair{}
+}
+
+// uncolored returns the white piece of the same kind
+func uncolored(piece int) int {
+	if piece >= BKING {
+		return piece - BKING + WKING
+	}
+	return piece
+}
+
+func parsePocketFEN(c *Chessboard, FEN string) (string, error) {
+	position, rest, _ := strings.Cut(FEN, " ")
+	pocket := ""
+	switch {
+	case strings.HasSuffix(position, "]"):
+		i := strings.IndexByte(position, '[')
+		if i == -1 {
+			return "", errors.New("invalid pocket in FEN: " + FEN)
+		}
+		position, pocket = position[:i], position[i+1:len(position)-1]
+	case strings.Count(position, "/") == 8:
+		i := strings.LastIndexByte(position, '/')
+		position, pocket = position[:i], position[i+1:]
+	}
+	if pocket == "-" {
+		pocket = ""
+	}
+	for i := 0; i < len(pocket); i++ {
+		piece := charToPiece[pocket[i]]
+		if piece == 0 || piece == WKING || piece == BKING {
+			return "", errors.New("invalid pocket in FEN: " + FEN)
+		}
+		c.Pockets[piece]++
+	}
+
+	// promoted pieces, "Q~"
+	standard := ""
+	row, col := int8(7), int8(0)
+	for i := 0; i < len(position); i++ {
+		ch := position[i]
+		switch {
+		case ch == '~':
+			if col == 0 || col > 8 || row < 0 {
+				return "", errors.New("invalid promoted piece in FEN: " + FEN)
+			}
+			c.Promoted |= 1 << pairToInt(pair{col: col - 1, row: row})
+			continue
+		case ch == '/':
+			row, col = row-1, 0
+		case ch > '0' && ch < '9':
+			col += int8(ch - '0')
+		default:
+			col++
+		}
+		standard += string(ch)
+	}
+
+	return Standard{}.ParseFEN(c, standard+" "+rest)
+}
+
+func writePocketFEN(c *Chessboard, FEN string) string {
+	position, rest, _ := strings.Cut(FEN, " ")
+
+	if c.Promoted != 0 {
+		marked := ""
+		row, col := int8(7), int8(0)
+		for i := 0; i < len(position); i++ {
+			ch := position[i]
+			marked += string(ch)
+			switch {
+			case ch == '/':
+				row, col = row-1, 0
+			case ch > '0' && ch < '9':
+				col += int8(ch - '0')
+			default:
+				if c.Promoted&(1<<pairToInt(pair{col: col, row: row})) != 0 {
+					marked += "~"
+				}
+				col++
+			}
+		}
+		position = marked
+	}
+
+	pocket := ""
+	for _, piece := range []int{WQUEEN, WROOK, WBISHOP, WKNIGHT, WPAWN, BQUEEN, BROOK, BBISHOP, BKNIGHT, BPAWN} {
+		pocket += strings.Repeat(string(pieceToChar[piece]), c.Pockets[piece])
+	}
+	return position + "[" + pocket + "] " + rest
+}
@@ -0,0 +1,201 @@
+package chessboard
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+)
+
+// flags of an encoded move, in its top 4 bits
+const (
+	normalMove    = 0
+	promotionMove = 1 // 1 + the type of the piece, 1 to 5
+	castlingMove  = 6
+	dropMove      = 8 // 8 + the type of the piece, the from square is 0
+)
+
+// pieceType returns 0 for kings, 1 for queens... 5 for pawns, of either color
+func pieceType(piece int) int {
+	return (piece - 1) % 6
+}
+
+func squareIndex(p pair) uint16 {
+	return uint16(p.row)*8 + uint16(p.col)
+}
+
+// Encode packs m in 16 bits: the from square in the low 6 bits, the to square
+// in the next 6 and the flags (promotion piece, castling, drop) in the top 4.
+// Castling is encoded as the king taking its rook. The side to move isn't
+// encoded, DecodeMove gets it from the board
+func (m Move) Encode() uint16 {
+	flags := uint16(normalMove)
+	from := squareIndex(m.from)
+	switch {
+	case m.drop != 0:
+		flags = dropMove + uint16(pieceType(m.drop))
+		from = 0
+	case m.castling:
+		flags = castlingMove
+	case m.promotion != 0:
+		flags = promotionMove + uint16(pieceType(m.promotion))
+	}
+	return from | squareIndex(m.to)<<6 | flags<<12
+}
+
+// DecodeMove returns the legal move of c that was encoded by Move.Encode
+func (c *Chessboard) DecodeMove(code uint16) (Move, error) {
+	m := Move{from: intToPair(int(code & 63)), to: intToPair(int(code >> 6 & 63))}
+	switch flags := int(code >> 12); {
+	case flags >= dropMove && flags < dropMove+6:
+		m.from, m.drop = pair{}, pieceColor(WKING+flags-dropMove, c.WhiteToMove)
+	case flags == castlingMove:
+		m.castling = true
+	case flags >= promotionMove && flags < promotionMove+5:
+		m.promotion = pieceColor(WKING+flags-promotionMove, c.WhiteToMove)
+	case flags != normalMove:
+		return Move{}, errors.New("invalid move encoding")
+	}
+	if m.Encode() != code || !c.CheckMoveLegality(m) {
+		return Move{}, errors.New("no legal move has this encoding")
+	}
+	return m, nil
+}
+
+// sortedMoves returns the legal moves of c sorted by their encoding, the
+// order the game encoding numbers them in
+func (c *Chessboard) sortedMoves() []Move {
+	moves := c.GetMoveList()
+	slices.SortFunc(moves, func(a, b Move) int {
+		return int(a.Encode()) - int(b.Encode())
+	})
+	return moves
+}
+
+// flags of the game encoding header
+const (
+	hasFEN      = 1 << iota // the game doesn't start from the starting position of its variant
+	hasChess960             // the board plays Chess960 castling
+)
+
+// EncodeGame packs a game in about a byte per move: every move is written as
+// its index in the legal moves sorted by Move.Encode, in two bytes when there
+// are more than 256 of them (crazyhouse drops). start is the board before the
+// first move, moves are in UCI like Chessboard.Moves. The clock and the tags
+// aren't kept.
+//
+// The format is a flags byte, the variant name ("" for standard chess) and
+// the FEN if there is one, both prefixed by their length as a uvarint, the
+// number of moves as a uvarint, and the moves
+func EncodeGame(start Chessboard, moves []string) ([]byte, error) {
+	start.Clock = nil
+	start.Moves = nil
+
+	variant := ""
+	startingFEN := initialFEN
+	if start.Variant != nil && start.Variant.Name() != (Standard{}).Name() {
+		variant = start.Variant.Name()
+		startingFEN = start.Variant.StartingFEN()
+	}
+	fen := start.GetFEN()
+	var flags byte
+	if fen != startingFEN {
+		flags |= hasFEN
+	}
+	if start.Chess960 {
+		// the castling rooks can't always be told from KQkq
+		fen = start.GetShredderFEN()
+		flags |= hasFEN | hasChess960
+	}
+
+	data := []byte{flags}
+	data = binary.AppendUvarint(data, uint64(len(variant)))
+	data = append(data, variant...)
+	if flags&hasFEN != 0 {
+		data = binary.AppendUvarint(data, uint64(len(fen)))
+		data = append(data, fen...)
+	}
+	data = binary.AppendUvarint(data, uint64(len(moves)))
+
+	board := start
+	for _, uci := range moves {
+		m, err := board.MoveFromUCI(uci)
+		if err != nil {
+			return nil, err
+		}
+		legal := board.sortedMoves()
+		index := slices.IndexFunc(legal, func(move Move) bool { return move == m })
+		if len(legal) > 256 {
+			data = binary.BigEndian.AppendUint16(data, uint16(index))
+		} else {
+			data = append(data, byte(index))
+		}
+		board.makeMove(m)
+	}
+	return data, nil
+}
+
+// DecodeGame unpacks a game written by EncodeGame and returns the board after
+// its last move, with the moves in Moves
+func DecodeGame(data []byte) (Chessboard, error) {
+	if len(data) == 0 {
+		return Chessboard{}, errors.New("empty encoded game")
+	}
+	flags := data[0]
+	data = data[1:]
+	readString := func() (string, error) {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || uint64(len(data)-size) < n {
+			return "", errors.New("truncated encoded game")
+		}
+		s := string(data[size : size+int(n)])
+		data = data[size+int(n):]
+		return s, nil
+	}
+
+	variantName, err := readString()
+	if err != nil {
+		return Chessboard{}, err
+	}
+	fen := ""
+	if flags&hasFEN != 0 {
+		if fen, err = readString(); err != nil {
+			return Chessboard{}, err
+		}
+	}
+
+	board, err := newBoard(variantName, fen, flags&hasChess960 != 0)
+	if err != nil {
+		return Chessboard{}, err
+	}
+
+	count, size := binary.Uvarint(data)
+	if size <= 0 {
+		return Chessboard{}, errors.New("truncated encoded game")
+	}
+	data = data[size:]
+	// every move takes a byte at least, a corrupt count can't allocate more
+	board.Moves = make([]string, 0, min(count, uint64(len(data))))
+	for i := uint64(0); i < count; i++ {
+		legal := board.sortedMoves()
+		index := 0
+		switch {
+		case len(legal) > 256 && len(data) >= 2:
+			index = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		case len(legal) <= 256 && len(data) >= 1:
+			index = int(data[0])
+			data = data[1:]
+		default:
+			return board, errors.New("truncated encoded game")
+		}
+		if index >= len(legal) {
+			return board, errors.New("invalid move index in encoded game")
+		}
+		board.Moves = append(board.Moves, board.GetUCI(legal[index]))
+		board.makeMove(legal[index])
+	}
+	if len(data) != 0 {
+		return board, errors.New("trailing bytes after the encoded game")
+	}
+	return board, nil
+}
@@ -0,0 +1,419 @@
+package chessboard
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+// Game is a Chessboard that can be shared between goroutines: players,
+// spectators and the clock. Every method locks the game, and what happens
+// to it is published as Events to its subscribers
+type Game struct {
+	mu      sync.Mutex
+	board   Chessboard
+	history []Chessboard // the board before each move, to undo them
+
+	result          Result  // decided outside the board: agreed draw, resignation
+	over            bool    // GAMEOVER was published
+	drawOffer       [2]bool // by white, by black
+	takebackRequest [2]bool
+
+	// deliverMu keeps events in order once mu is released
+	deliverMu      sync.Mutex
+	subscribers    map[int]*subscriber
+	nextSubscriber int
+}
+
+type EventType int
+
+const (
+	MOVEMADE EventType = iota + 1
+	CHECK
+	DRAWOFFER
+	TAKEBACKREQUEST
+	TAKEBACK
+	GAMEOVER
+	CLOCKTICK
+)
+
+// Event is something that happened to a Game. Which fields are set depends on Type
+type Event struct {
+	Type EventType
+	// White is the side that moved, offered a draw or asked for a takeback
+	White bool
+
+	// MOVEMADE, CHECK and TAKEBACK: the position after, Ply is len(Moves)
+	SAN  string
+	UCI  string
+	FEN  string
+	Hash uint64
+	Ply  int
+
+	// GAMEOVER
+	Result Result
+
+	// CLOCKTICK
+	WhiteTime time.Duration
+	BlackTime time.Duration
+}
+
+// Backpressure is what happens when a subscriber doesn't keep up
+type Backpressure int
+
+const (
+	DROPOLDEST Backpressure = iota // drop queued events to make room for new ones
+	DROPNEWEST                     // drop new events while the queue is full
+	BLOCK                          // the game waits for the subscriber
+)
+
+type subscriber struct {
+	ch       chan Event
+	done     chan struct{}
+	policy   Backpressure
+	stopOnce sync.Once
+}
+
+// NewGame starts a game from board. The game owns board from now on
+func NewGame(board Chessboard) *Game {
+	return &Game{board: board, subscribers: map[int]*subscriber{}}
+}
+
+func sideIndex(white bool) int {
+	if white {
+		return 0
+	}
+	return 1
+}
+
+// Move plays a move written in UCI or SAN
+func (g *Game) Move(move string) error {
+	g.mu.Lock()
+	if g.gameResult() != ONGOING {
+		g.mu.Unlock()
+		return errors.New("the game is over")
+	}
+
+	m, err := g.board.MoveFromUCI(move)
+	if err != nil {
+		if m, err = g.board.MoveFromSAN(move); err != nil {
+			g.mu.Unlock()
+			return errors.New("illegal or invalid move: " + move)
+		}
+	}
+	san := g.board.GetSAN(m)
+	white := g.board.WhiteToMove
+	before := g.board
+	if err := g.board.playMove(m); err != nil {
+		// the flag may have fallen
+		g.emitAndUnlock(g.checkOver(nil))
+		return err
+	}
+	g.history = append(g.history, before)
+	// moving declines the other side's offer
+	g.drawOffer[sideIndex(!white)] = false
+	g.takebackRequest = [2]bool{}
+
+	event := g.positionEvent(MOVEMADE)
+	event.White, event.SAN, event.UCI = white, san, g.board.Moves[len(g.board.Moves)-1]
+	events := []Event{event}
+	if g.board.InCheck() {
+		event.Type = CHECK
+		events = append(events, event)
+	}
+	g.emitAndUnlock(g.checkOver(events))
+	return nil
+}
+
+// Undo takes back the last move. The clock is not given the time back
+func (g *Game) Undo() error {
+	g.mu.Lock()
+	if err := g.undo(1); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	g.emitAndUnlock([]Event{g.positionEvent(TAKEBACK)})
+	return nil
+}
+
+func (g *Game) undo(plies int) error {
+	if g.gameResult() != ONGOING {
+		return errors.New("the game is over")
+	}
+	if len(g.history) < plies {
+		return errors.New("no move to undo")
+	}
+	g.board = g.history[len(g.history)-plies]
+	g.history = g.history[:len(g.history)-plies]
+	g.takebackRequest = [2]bool{}
+	if g.board.Clock != nil {
+		// the side to move gets its turn back on the clock
+		g.board.Clock.SetTurn(g.board.WhiteToMove)
+	}
+	return nil
+}
+
+// OfferDraw offers a draw for one side. If the other side offered one
+// already, the game is drawn
+func (g *Game) OfferDraw(white bool) error {
+	g.mu.Lock()
+	if g.gameResult() != ONGOING {
+		g.mu.Unlock()
+		return errors.New("the game is over")
+	}
+	if g.drawOffer[sideIndex(!white)] {
+		g.result = DRAW
+		g.emitAndUnlock(g.checkOver(nil))
+		return nil
+	}
+	g.drawOffer[sideIndex(white)] = true
+	g.emitAndUnlock([]Event{{Type: DRAWOFFER, White: white}})
+	return nil
+}
+
+// AcceptDraw accepts the other side's draw offer
+func (g *Game) AcceptDraw(white bool) error {
+	g.mu.Lock()
+	if !g.drawOffer[sideIndex(!white)] || g.gameResult() != ONGOING {
+		g.mu.Unlock()
+		return errors.New("there is no draw offer to accept")
+	}
+	g.result = DRAW
+	g.emitAndUnlock(g.checkOver(nil))
+	return nil
+}
+
+// RequestTakeback asks the other side to take back this side's last move
+func (g *Game) RequestTakeback(white bool) error {
+	g.mu.Lock()
+	if g.gameResult() != ONGOING {
+		g.mu.Unlock()
+		return errors.New("the game is over")
+	}
+	if len(g.history) == 0 || (len(g.history) == 1 && g.board.WhiteToMove == white) {
+		g.mu.Unlock()
+		return errors.New("no move to take back")
+	}
+	g.takebackRequest[sideIndex(white)] = true
+	g.emitAndUnlock([]Event{{Type: TAKEBACKREQUEST, White: white}})
+	return nil
+}
+
+// AcceptTakeback takes back moves until it is the turn of the side that asked
+func (g *Game) AcceptTakeback(white bool) error {
+	g.mu.Lock()
+	if !g.takebackRequest[sideIndex(!white)] {
+		g.mu.Unlock()
+		return errors.New("there is no takeback request to accept")
+	}
+	plies := 1
+	if g.board.WhiteToMove == !white {
+		// the side that asked already got a reply, take that back too
+		plies = 2
+	}
+	if err := g.undo(plies); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	g.emitAndUnlock([]Event{g.positionEvent(TAKEBACK)})
+	return nil
+}
+
+// Resign ends the game, the other side wins
+func (g *Game) Resign(white bool) error {
+	g.mu.Lock()
+	if g.gameResult() != ONGOING {
+		g.mu.Unlock()
+		return errors.New("the game is over")
+	}
+	g.result = winner(!white)
+	g.emitAndUnlock(g.checkOver(nil))
+	return nil
+}
+
+// Tick publishes the clock times, and the result if a flag fell. It does
+// nothing for games without a clock
+func (g *Game) Tick() {
+	g.mu.Lock()
+	if g.board.Clock == nil {
+		g.mu.Unlock()
+		return
+	}
+	events := []Event{{
+		Type:      CLOCKTICK,
+		WhiteTime: g.board.Clock.Remaining(WHITE),
+		BlackTime: g.board.Clock.Remaining(BLACK),
+	}}
+	g.emitAndUnlock(g.checkOver(events))
+}
+
+// RunClock calls Tick every interval until stop is called
+func (g *Game) RunClock(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				g.Tick()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Snapshot returns a copy of the board that the caller is free to change.
+// Its clock is a copy too, stopping or pressing it doesn't touch the game's
+func (g *Game) Snapshot() Chessboard {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	board := g.board
+	board.Moves = append([]string(nil), g.board.Moves...)
+	board.PGNTags.Extra = append([]pgntags.Tag(nil), g.board.PGNTags.Extra...)
+	board.Clock = g.board.Clock.Clone()
+	return board
+}
+
+// Result returns the result of the game: agreed, by resignation, or see Chessboard.GetResult
+func (g *Game) Result() Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gameResult()
+}
+
+func (g *Game) gameResult() Result {
+	if g.result != ONGOING {
+		return g.result
+	}
+	return g.board.GetResult()
+}
+
+// positionEvent fills in the position fields of an event. g is locked
+func (g *Game) positionEvent(eventType EventType) Event {
+	return Event{
+		Type: eventType,
+		FEN:  g.board.GetFEN(),
+		Hash: g.board.Hash(),
+		Ply:  len(g.board.Moves),
+	}
+}
+
+// checkOver adds GAMEOVER to events the first time the game is found over. g is locked
+func (g *Game) checkOver(events []Event) []Event {
+	if g.over {
+		return events
+	}
+	result := g.gameResult()
+	if result == ONGOING {
+		return events
+	}
+	g.over = true
+	if g.board.Clock != nil {
+		g.board.Clock.Stop()
+	}
+	return append(events, Event{Type: GAMEOVER, Result: result})
+}
+
+// Subscribe returns a channel that gets every event of the game from now
+// on, and a function to stop the subscription. buffer is how many events
+// can wait in the channel, policy what happens when it is full
+func (g *Game) Subscribe(buffer int, policy Backpressure) (<-chan Event, func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.nextSubscriber
+	g.nextSubscriber++
+	s := &subscriber{ch: make(chan Event, max(1, buffer)), done: make(chan struct{}), policy: policy}
+	g.subscribers[id] = s
+
+	unsubscribe := func() {
+		s.stopOnce.Do(func() {
+			// let a blocked delivery go before waiting for it
+			close(s.done)
+			g.mu.Lock()
+			delete(g.subscribers, id)
+			g.deliverMu.Lock()
+			g.mu.Unlock()
+			close(s.ch)
+			g.deliverMu.Unlock()
+		})
+	}
+	return s.ch, unsubscribe
+}
+
+// OnEvent calls f with every event, from a goroutine of its own, until the
+// returned function is called. See Subscribe for buffer and policy
+func (g *Game) OnEvent(f func(Event), buffer int, policy Backpressure) (unsubscribe func()) {
+	events, unsubscribe := g.Subscribe(buffer, policy)
+	go func() {
+		for event := range events {
+			f(event)
+		}
+	}()
+	return unsubscribe
+}
+
+// emitAndUnlock unlocks g and then delivers events to the subscribers. Events
+// of one call are never mixed with another's
+func (g *Game) emitAndUnlock(events []Event) {
+	if len(events) == 0 {
+		g.mu.Unlock()
+		return
+	}
+	subscribers := make([]*subscriber, 0, len(g.subscribers))
+	for _, s := range g.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	g.deliverMu.Lock()
+	g.mu.Unlock()
+	defer g.deliverMu.Unlock()
+
+	for _, event := range events {
+		for _, s := range subscribers {
+			s.send(event)
+		}
+	}
+}
+
+func (s *subscriber) send(event Event) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	switch s.policy {
+	case BLOCK:
+		select {
+		case s.ch <- event:
+		case <-s.done:
+		}
+	case DROPNEWEST:
+		select {
+		case s.ch <- event:
+		default:
+		}
+	default:
+		for {
+			select {
+			case s.ch <- event:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package chessboard
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Horde: white has 36 pawns and no king against a normal black army. Black
+// wins by capturing every white piece, white by checkmating. White pawns on
+// the first row can move two squares, without an en passant square.
+type Horde struct{ Standard }
+
+func init() {
+	RegisterVariant(Horde{})
+}
+
+func (Horde) Name() string { return "Horde" }
+func (Horde) StartingFEN() string {
+	return "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1"
+}
+
+func (Horde) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	if ok, logs := validateFEN(FEN, false); !ok {
+		return "", errors.New(logs)
+	}
+	return FEN, nil
+}
+
+func (Horde) PseudoLegalMoves(c *Chessboard, moves []Move) []Move {
+	if !c.WhiteToMove {
+		return moves
+	}
+	occupied := c.occupied()
+	for bitboard := c.BoardState[WPAWN] & 0xff; bitboard != 0; bitboard &= bitboard - 1 {
+		from := intToPair(bits.TrailingZeros64(bitboard))
+		oneStep, twoStep := pair{col: from.col, row: 1}, pair{col: from.col, row: 2}
+		if occupied&(1<<pairToInt(oneStep)|1<<pairToInt(twoStep)) == 0 {
+			moves = append(moves, Move{from: from, to: twoStep})
+		}
+	}
+	return moves
+}
+
+func (Horde) AfterMove(before *Chessboard, m Move, c *Chessboard) {
+	if m.from.row == 0 && m.to.row == 2 && before.hasPiece(m.from, WPAWN) {
+		c.EnPassantSquare = pair{}
+	}
+}
+
+func (v Horde) Result(c *Chessboard, moves []Move) Result {
+	white := uint64(0)
+	for piece := WKING; piece <= WPAWN; piece++ {
+		white |= c.BoardState[piece]
+	}
+	if white == 0 {
+		return BLACKWINS
+	}
+	return v.Standard.Result(c, moves)
+}
@@ -0,0 +1,39 @@
+package chessboard
+
+// d4, e4, d5 and e5
+const hillSquares = uint64(1<<27 | 1<<28 | 1<<35 | 1<<36)
+
+// King of the Hill: bringing the king to one of the four center squares wins
+type KingOfTheHill struct{ Standard }
+
+func init() {
+	RegisterVariant(KingOfTheHill{})
+}
+
+func (KingOfTheHill) Name() string { return "King of the Hill" }
+
+func (v KingOfTheHill) FilterMoves(c *Chessboard, moves []Move) []Move {
+	if v.decided(c) != ONGOING {
+		return nil
+	}
+	return moves
+}
+
+func (v KingOfTheHill) Result(c *Chessboard, moves []Move) Result {
+	if result := v.decided(c); result != ONGOING {
+		return result
+	}
+	return v.Standard.Result(c, moves)
+}
+
+// decided looks for a king on the hill. The center squares all touch each
+// other, so there can't be two
+func (KingOfTheHill) decided(c *Chessboard) Result {
+	switch {
+	case c.BoardState[WKING]&hillSquares != 0:
+		return WHITEWINS
+	case c.BoardState[BKING]&hillSquares != 0:
+		return BLACKWINS
+	}
+	return ONGOING
+}
@@ -0,0 +1,304 @@
+package chessboard
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kahnaisehC/chessboard/pkg/clock"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+// MarshalText writes the move in UCI, like String. There is no
+// UnmarshalText, a move only makes sense on its board: see MoveFromUCI
+func (m Move) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// MarshalBinary writes Encode in 2 bytes, big endian. See DecodeMove
+func (m Move) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint16(nil, m.Encode()), nil
+}
+
+// MarshalText writes the position as FEN. The moves, tags and clock are not
+// kept, see MarshalJSON and MarshalBinary for that
+func (c Chessboard) MarshalText() ([]byte, error) {
+	return []byte(c.GetFEN()), nil
+}
+
+// UnmarshalText sets up the board from FEN, for the variant of c if it has one
+func (c *Chessboard) UnmarshalText(text []byte) error {
+	if c.Variant != nil {
+		board, err := CreateVariantChessboard(c.Variant, string(text))
+		if err != nil {
+			return err
+		}
+		*c = board
+		return nil
+	}
+	if ok, logs := ValidateFEN(string(text)); !ok {
+		return errors.New("invalid FEN: " + logs)
+	}
+	*c = CreateChessboard(string(text))
+	return nil
+}
+
+type boardJSON struct {
+	FEN      string     `json:"fen"`
+	Variant  string     `json:"variant,omitempty"`
+	Chess960 bool       `json:"chess960,omitempty"`
+	StartFEN string     `json:"startFen,omitempty"` // if the game didn't start from the usual position
+	Moves    []moveJSON `json:"moves"`
+	Tags     []tagJSON  `json:"tags,omitempty"` // in PGN order
+	Result   string     `json:"result"`
+	Clock    *clockJSON `json:"clock,omitempty"`
+}
+
+type moveJSON struct {
+	UCI string `json:"uci"`
+	SAN string `json:"san"`
+}
+
+type tagJSON struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type clockJSON struct {
+	TimeControl string `json:"timeControl"` // like the PGN tag, "300+2"
+	White       int64  `json:"white"`       // milliseconds left
+	Black       int64  `json:"black"`
+}
+
+// MarshalJSON writes the position, the moves in UCI and SAN, the tags, the
+// result and the time left on the clock
+func (c Chessboard) MarshalJSON() ([]byte, error) {
+	start, err := c.StartingBoard()
+	if err != nil {
+		return nil, err
+	}
+	b := boardJSON{
+		FEN:      c.GetFEN(),
+		Chess960: c.Chess960,
+		Moves:    make([]moveJSON, len(c.Moves)),
+		Result:   c.GetResult().String(),
+	}
+	if c.Variant != nil && c.Variant.Name() != (Standard{}).Name() {
+		b.Variant = c.Variant.Name()
+	}
+	if fen := start.GetFEN(); fen != startingFEN(c.Variant) {
+		b.StartFEN = fen
+		if c.Chess960 {
+			b.StartFEN = start.GetShredderFEN()
+		}
+	}
+	for i, uci := range c.Moves {
+		m, err := start.MoveFromUCI(uci)
+		if err != nil {
+			return nil, err
+		}
+		b.Moves[i] = moveJSON{UCI: uci, SAN: start.GetSAN(m)}
+		start.makeMove(m)
+	}
+	for _, tag := range tagList(c.PGNTags) {
+		b.Tags = append(b.Tags, tagJSON{Name: tag.Name, Value: tag.Value})
+	}
+	if c.Clock != nil {
+		b.Clock = &clockJSON{
+			TimeControl: c.Clock.Control().String(),
+			White:       c.Clock.Remaining(WHITE).Milliseconds(),
+			Black:       c.Clock.Remaining(BLACK).Milliseconds(),
+		}
+	}
+	return json.Marshal(b)
+}
+
+// UnmarshalJSON replays the moves of what MarshalJSON wrote. The clock, if
+// there is one, is stopped with the times that were left
+func (c *Chessboard) UnmarshalJSON(data []byte) error {
+	var b boardJSON
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	start := b.StartFEN
+	if start == "" && len(b.Moves) == 0 {
+		start = b.FEN
+	}
+	board, err := newBoard(b.Variant, start, b.Chess960)
+	if err != nil {
+		return err
+	}
+	for _, m := range b.Moves {
+		if err := board.MakeUCIMove(m.UCI); err != nil {
+			return err
+		}
+	}
+	if b.FEN != "" && board.GetFEN() != b.FEN {
+		return errors.New("the moves don't lead to the FEN " + b.FEN)
+	}
+	for _, tag := range b.Tags {
+		if err := board.PGNTags.Set(tag.Name, tag.Value); err != nil {
+			return err
+		}
+	}
+	if b.Clock != nil {
+		control, err := clock.ParseTimeControl(b.Clock.TimeControl)
+		if err != nil {
+			return err
+		}
+		board.Clock = clock.New(control, nil)
+		board.Clock.Adjust(time.Duration(b.Clock.White)*time.Millisecond, time.Duration(b.Clock.Black)*time.Millisecond)
+	}
+	*c = board
+	return nil
+}
+
+// binaryVersion is the first byte of MarshalBinary, for when the format changes
+const binaryVersion = 1
+
+// MarshalBinary writes the game compactly, for caching: a version byte, the
+// game as EncodeGame writes it, the tags and the clock. Strings and the game
+// are prefixed by their length as a uvarint
+func (c Chessboard) MarshalBinary() ([]byte, error) {
+	start, err := c.StartingBoard()
+	if err != nil {
+		return nil, err
+	}
+	game, err := EncodeGame(start, c.Moves)
+	if err != nil {
+		return nil, err
+	}
+	data := []byte{binaryVersion}
+	data = appendBytes(data, game)
+
+	tags := tagList(c.PGNTags)
+	data = binary.AppendUvarint(data, uint64(len(tags)))
+	for _, tag := range tags {
+		data = appendBytes(data, []byte(tag.Name))
+		data = appendBytes(data, []byte(tag.Value))
+	}
+
+	if c.Clock == nil {
+		return appendBytes(data, nil), nil
+	}
+	data = appendBytes(data, []byte(c.Clock.Control().String()))
+	data = binary.AppendUvarint(data, uint64(c.Clock.Remaining(WHITE).Milliseconds()))
+	data = binary.AppendUvarint(data, uint64(c.Clock.Remaining(BLACK).Milliseconds()))
+	return data, nil
+}
+
+// UnmarshalBinary reads what MarshalBinary wrote. The clock, if there is one,
+// is stopped with the times that were left
+func (c *Chessboard) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryVersion {
+		return errors.New("unknown binary board version")
+	}
+	r := byteReader{data: data[1:]}
+	board, err := DecodeGame(r.bytes())
+	if err != nil {
+		return err
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		name, value := string(r.bytes()), string(r.bytes())
+		if r.err == nil {
+			if err := board.PGNTags.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	if control := string(r.bytes()); control != "" {
+		tc, err := clock.ParseTimeControl(control)
+		if err != nil {
+			return err
+		}
+		white, black := r.uvarint(), r.uvarint()
+		board.Clock = clock.New(tc, nil)
+		board.Clock.Adjust(time.Duration(white)*time.Millisecond, time.Duration(black)*time.Millisecond)
+	}
+	if r.err == nil && len(r.data) != 0 {
+		r.err = errors.New("trailing bytes after the binary board")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*c = board
+	return nil
+}
+
+// newBoard sets up a board for a variant name, "" for standard chess. An
+// empty FEN is the starting position of the variant
+func newBoard(variantName, fen string, chess960 bool) (Chessboard, error) {
+	var board Chessboard
+	switch {
+	case variantName != "" && variantName != (Standard{}).Name():
+		variant, ok := VariantByName(variantName)
+		if !ok {
+			return Chessboard{}, errors.New("unknown variant: " + variantName)
+		}
+		var err error
+		if board, err = CreateVariantChessboard(variant, fen); err != nil {
+			return Chessboard{}, err
+		}
+	case fen != "":
+		if ok, logs := ValidateFEN(fen); !ok {
+			return Chessboard{}, errors.New("invalid FEN: " + logs)
+		}
+		board = CreateChessboard(fen)
+	default:
+		board = CreateChessboard("")
+	}
+	board.Chess960 = chess960
+	return board, nil
+}
+
+// tagList returns the tags that are set, unlike PGNTags.Tags that fills in
+// the Seven Tag Roster
+func tagList(tags pgntags.PGNTags) []pgntags.Tag {
+	var list []pgntags.Tag
+	for _, name := range pgntags.SevenTagRoster {
+		if value, ok := tags.Get(name); ok && value != "" {
+			list = append(list, pgntags.Tag{Name: name, Value: value})
+		}
+	}
+	return append(list, tags.Extra...)
+}
+
+func appendBytes(data, b []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(b)))
+	return append(data, b...)
+}
+
+// byteReader reads what appendBytes and binary.AppendUvarint wrote. The
+// first error sticks, reads after it return zero values
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+func (r *byteReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		r.err = errors.New("truncated binary board")
+		return 0
+	}
+	r.data = r.data[size:]
+	return n
+}
+
+func (r *byteReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.data)) < n {
+		r.err = errors.New("truncated binary board")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
@@ -0,0 +1,61 @@
+package chessboard
+
+// the eighth row
+const racingGoal = uint64(0xff) << 56
+
+// Racing Kings: both sides start on the first two rows and race their king
+// to the eighth row. Giving check is not allowed. If white gets there first
+// black has one more move to get there too and draw.
+type RacingKings struct{ Standard }
+
+func init() {
+	RegisterVariant(RacingKings{})
+}
+
+func (RacingKings) Name() string        { return "Racing Kings" }
+func (RacingKings) StartingFEN() string { return "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1" }
+
+// Legal also forbids checking the other king
+func (v RacingKings) Legal(c *Chessboard, m Move, next *Chessboard) bool {
+	return v.Standard.Legal(c, m, next) && !next.InCheck()
+}
+
+func (v RacingKings) FilterMoves(c *Chessboard, moves []Move) []Move {
+	if v.decided(c, moves) != ONGOING {
+		return nil
+	}
+	return moves
+}
+
+// Result is a draw when the side to move can't move, there is no checkmate
+func (v RacingKings) Result(c *Chessboard, moves []Move) Result {
+	if result := v.decided(c, moves); result != ONGOING {
+		return result
+	}
+	if len(moves) == 0 {
+		return DRAW
+	}
+	return ONGOING
+}
+
+func (RacingKings) decided(c *Chessboard, moves []Move) Result {
+	white := c.BoardState[WKING]&racingGoal != 0
+	black := c.BoardState[BKING]&racingGoal != 0
+	switch {
+	case white && black:
+		return DRAW
+	case black:
+		return BLACKWINS
+	case white && !c.WhiteToMove:
+		// black's last chance to draw
+		for _, move := range moves {
+			if c.hasPiece(move.from, BKING) && move.to.row == 7 {
+				return ONGOING
+			}
+		}
+		return WHITEWINS
+	case white:
+		return WHITEWINS
+	}
+	return ONGOING
+}
@@ -0,0 +1,211 @@
+package chessboard
+
+import (
+	"errors"
+	"strings"
+)
+
+// String returns the move in UCI coordinate notation, eg "e2e4" or "e7e8q".
+// Castling from the e file with a corner rook is written as the king's two
+// square move, e1g1, any other castling as the king taking its rook. Drops are
+// written like N@f3
+func (m Move) String() string {
+	if m.drop != 0 {
+		return strings.ToUpper(string(pieceToChar[m.drop])) + "@" + pairToString(m.to)
+	}
+	to := m.to
+	if m.castling && m.from.col == 4 && (m.to.col == 0 || m.to.col == 7) {
+		to.col = 6
+		if m.to.col == 0 {
+			to.col = 2
+		}
+	}
+	s := pairToString(m.from) + pairToString(to)
+	if m.promotion != 0 {
+		s += strings.ToLower(string(pieceToChar[m.promotion]))
+	}
+	return s
+}
+
+// GetUCI writes m in UCI coordinate notation. In Chess960 castling is always
+// written as the king taking its rook, like UCI_Chess960 engines expect
+func (c *Chessboard) GetUCI(m Move) string {
+	if c.Chess960 && m.castling {
+		return pairToString(m.from) + pairToString(m.to)
+	}
+	return m.String()
+}
+
+// MoveFromUCI finds the legal move written in UCI coordinate notation.
+// Castling can also be written as the king taking its rook
+func (c *Chessboard) MoveFromUCI(uci string) (Move, error) {
+	for _, move := range c.GetMoveList() {
+		if move.String() == uci || (move.castling && pairToString(move.from)+pairToString(move.to) == uci) {
+			return move, nil
+		}
+	}
+	return Move{}, errors.New("illegal or invalid UCI move: " + uci)
+}
+
+// GetSAN writes m in Standard Algebraic Notation. m has to be legal
+func (c *Chessboard) GetSAN(m Move) string {
+	fromPiece := c.getPiece(m.from)
+	toPiece := c.getPiece(m.to)
+	san := ""
+
+	switch {
+	case m.drop != 0:
+		san = m.String()
+	case m.castling && m.to.col > m.from.col:
+		san = "O-O"
+	case m.castling:
+		san = "O-O-O"
+	case fromPiece == WPAWN || fromPiece == BPAWN:
+		if m.from.col != m.to.col {
+			san = pairToString(m.from)[:1] + "x"
+		}
+		san += pairToString(m.to)
+		if m.promotion != 0 {
+			san += "=" + strings.ToUpper(string(pieceToChar[m.promotion]))
+		}
+	default:
+		san = strings.ToUpper(string(pieceToChar[fromPiece]))
+
+		// disambiguate between pieces of the same kind that can reach m.to
+		ambiguous, sameCol, sameRow := false, false, false
+		for _, other := range c.GetMoveList() {
+			if other.to != m.to || other.from == m.from || c.getPiece(other.from) != fromPiece {
+				continue
+			}
+			ambiguous = true
+			sameCol = sameCol || other.from.col == m.from.col
+			sameRow = sameRow || other.from.row == m.from.row
+		}
+		if ambiguous {
+			square := pairToString(m.from)
+			switch {
+			case !sameCol:
+				san += square[:1]
+			case !sameRow:
+				san += square[1:]
+			default:
+				san += square
+			}
+		}
+
+		if toPiece != 0 {
+			san += "x"
+		}
+		san += pairToString(m.to)
+	}
+
+	next := *c
+	next.makeMove(m)
+	if next.InCheck() {
+		if len(next.GetMoveList()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	return san
+}
+
+// MoveFromSAN finds the legal move written in Standard Algebraic Notation.
+// It accepts the usual sloppiness: 0-0 for castling, missing "=" on
+// promotions and trailing annotations like "+", "#" or "!?"
+func (c *Chessboard) MoveFromSAN(san string) (Move, error) {
+	s := strings.TrimRight(san, "+#!?")
+	moves := c.GetMoveList()
+
+	// drops, N@f3 or @e4 for a pawn
+	if at := strings.IndexByte(s, '@'); at != -1 {
+		piece := WPAWN
+		if at == 1 && strings.IndexByte("QRBNP", s[0]) != -1 {
+			piece = charToPiece[s[0]]
+		}
+		if at > 1 || !isSquareString(s[at+1:]) {
+			return Move{}, errors.New("invalid SAN move: " + san)
+		}
+		for _, move := range moves {
+			if move.drop == pieceColor(piece, c.WhiteToMove) && move.to == sq(s[at+1:]) {
+				return move, nil
+			}
+		}
+		return Move{}, errors.New("illegal SAN move: " + san)
+	}
+
+	if s == "O-O" || s == "0-0" || s == "O-O-O" || s == "0-0-0" {
+		for _, move := range moves {
+			if move.castling && (len(s) == 3) == (move.to.col > move.from.col) {
+				return move, nil
+			}
+		}
+		return Move{}, errors.New("illegal castling: " + san)
+	}
+
+	piece := WPAWN
+	if len(s) > 0 && strings.IndexByte("KQRBN", s[0]) != -1 {
+		piece = charToPiece[s[0]]
+		s = s[1:]
+	}
+	piece = pieceColor(piece, c.WhiteToMove)
+
+	promotion := 0
+	s = strings.Replace(s, "=", "", 1)
+	// K is for antichess
+	if len(s) > 0 && strings.IndexByte("QRBNK", s[len(s)-1]) != -1 {
+		promotion = pieceColor(charToPiece[s[len(s)-1]], c.WhiteToMove)
+		s = s[:len(s)-1]
+	}
+
+	s = strings.NewReplacer("x", "", "-", "", ":", "").Replace(s)
+	if len(s) < 2 || !isSquareString(s[len(s)-2:]) {
+		return Move{}, errors.New("invalid SAN move: " + san)
+	}
+	to := sq(s[len(s)-2:])
+	disambiguation := s[:len(s)-2]
+
+	found := []Move{}
+	for _, move := range moves {
+		if move.to != to || move.promotion != promotion || c.getPiece(move.from) != piece {
+			continue
+		}
+		from := pairToString(move.from)
+		matches := true
+		for i := 0; i < len(disambiguation); i++ {
+			if disambiguation[i] != from[0] && disambiguation[i] != from[1] {
+				matches = false
+			}
+		}
+		if matches {
+			found = append(found, move)
+		}
+	}
+
+	if len(found) == 0 {
+		return Move{}, errors.New("illegal SAN move: " + san)
+	}
+	if len(found) > 1 {
+		return Move{}, errors.New("ambiguous SAN move: " + san)
+	}
+	return found[0], nil
+}
+
+// MakeSANMove plays a move written in Standard Algebraic Notation
+func (c *Chessboard) MakeSANMove(san string) error {
+	move, err := c.MoveFromSAN(san)
+	if err != nil {
+		return err
+	}
+	return c.playMove(move)
+}
+
+// MakeUCIMove plays a move written in UCI coordinate notation
+func (c *Chessboard) MakeUCIMove(uci string) error {
+	move, err := c.MoveFromUCI(uci)
+	if err != nil {
+		return err
+	}
+	return c.playMove(move)
+}
@@ -0,0 +1,90 @@
+package chessboard
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Three-check: giving the third check wins. FEN ends with the checks given
+// by white and black, "+1+0". The older form with the checks left before the
+// halfmove clock, "KQkq - 2+3 0 1", is read too
+type ThreeCheck struct{ Standard }
+
+func init() {
+	RegisterVariant(ThreeCheck{})
+}
+
+func (ThreeCheck) Name() string        { return "Three-check" }
+func (ThreeCheck) StartingFEN() string { return initialFEN + " +0+0" }
+
+func (ThreeCheck) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	fields := strings.Fields(FEN)
+	switch {
+	case len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "+"):
+		white, black, ok := parseChecks(fields[len(fields)-1][1:])
+		if !ok {
+			return "", errors.New("invalid checks in FEN: " + FEN)
+		}
+		c.WhiteChecksGiven, c.BlackChecksGiven = white, black
+		fields = fields[:len(fields)-1]
+	case len(fields) > 4 && strings.Contains(fields[4], "+"):
+		white, black, ok := parseChecks(fields[4])
+		if !ok {
+			return "", errors.New("invalid checks in FEN: " + FEN)
+		}
+		c.WhiteChecksGiven, c.BlackChecksGiven = 3-white, 3-black
+		fields = append(fields[:4], fields[5:]...)
+	}
+	return Standard{}.ParseFEN(c, strings.Join(fields, " "))
+}
+
+// parseChecks reads "1+2"
+func parseChecks(s string) (white, black int, ok bool) {
+	whiteString, blackString, found := strings.Cut(s, "+")
+	white, whiteErr := strconv.Atoi(whiteString)
+	black, blackErr := strconv.Atoi(blackString)
+	if !found || whiteErr != nil || blackErr != nil || white < 0 || white > 3 || black < 0 || black > 3 {
+		return 0, 0, false
+	}
+	return white, black, true
+}
+
+func (ThreeCheck) WriteFEN(c *Chessboard, FEN string) string {
+	return FEN + " +" + strconv.Itoa(c.WhiteChecksGiven) + "+" + strconv.Itoa(c.BlackChecksGiven)
+}
+
+func (ThreeCheck) AfterMove(before *Chessboard, m Move, c *Chessboard) {
+	if !c.InCheck() {
+		return
+	}
+	if before.WhiteToMove {
+		c.WhiteChecksGiven++
+	} else {
+		c.BlackChecksGiven++
+	}
+}
+
+func (v ThreeCheck) FilterMoves(c *Chessboard, moves []Move) []Move {
+	if v.decided(c) != ONGOING {
+		return nil
+	}
+	return moves
+}
+
+func (v ThreeCheck) Result(c *Chessboard, moves []Move) Result {
+	if result := v.decided(c); result != ONGOING {
+		return result
+	}
+	return v.Standard.Result(c, moves)
+}
+
+func (ThreeCheck) decided(c *Chessboard) Result {
+	switch {
+	case c.WhiteChecksGiven >= 3:
+		return WHITEWINS
+	case c.BlackChecksGiven >= 3:
+		return BLACKWINS
+	}
+	return ONGOING
+}
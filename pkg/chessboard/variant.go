@@ -0,0 +1,142 @@
+package chessboard
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Variant changes the rules a Chessboard plays by. Variants keep no state of
+// their own: whatever a variant tracks (pockets, checks given...) lives on
+// the Chessboard, so copying a board copies all of it.
+// Embed Standard and override the hooks the variant needs.
+type Variant interface {
+	// Name is the name used in the PGN Variant tag, eg "Crazyhouse"
+	Name() string
+	// StartingFEN is the position new games start from
+	StartingFEN() string
+
+	// ParseFEN reads the variant's FEN extensions into c and returns the FEN
+	// without them. It also checks that FEN is valid for the variant
+	ParseFEN(c *Chessboard, FEN string) (string, error)
+	// WriteFEN adds the variant's extensions to the standard FEN of c
+	WriteFEN(c *Chessboard, FEN string) string
+
+	// PseudoLegalMoves gets the standard pseudo legal moves of c and can add
+	// or remove moves, eg drops
+	PseudoLegalMoves(c *Chessboard, moves []Move) []Move
+	// Legal reports if playing m on c may leave the position next
+	Legal(c *Chessboard, m Move, next *Chessboard) bool
+	// FilterMoves gets the legal moves of c, eg to make captures compulsory
+	FilterMoves(c *Chessboard, moves []Move) []Move
+	// AfterMove updates c once the standard part of m was played on it.
+	// before is the board before m
+	AfterMove(before *Chessboard, m Move, c *Chessboard)
+
+	// Result decides the game. moves are the legal moves of the side to move
+	Result(c *Chessboard, moves []Move) Result
+}
+
+// Standard is standard chess. nil Chessboard.Variant plays it too
+type Standard struct{}
+
+func (Standard) Name() string        { return "Standard" }
+func (Standard) StartingFEN() string { return initialFEN }
+
+func (Standard) ParseFEN(c *Chessboard, FEN string) (string, error) {
+	if ok, logs := ValidateFEN(FEN); !ok {
+		return "", errors.New(logs)
+	}
+	return FEN, nil
+}
+
+func (Standard) WriteFEN(c *Chessboard, FEN string) string { return FEN }
+
+func (Standard) PseudoLegalMoves(c *Chessboard, moves []Move) []Move { return moves }
+
+// Legal checks that the side that moved didn't leave its king in check
+func (Standard) Legal(c *Chessboard, m Move, next *Chessboard) bool {
+	return !next.SquareIsThreatened(next.WhiteToMove, next.GetKingPosition(c.WhiteToMove))
+}
+
+func (Standard) FilterMoves(c *Chessboard, moves []Move) []Move { return moves }
+
+func (Standard) AfterMove(before *Chessboard, m Move, c *Chessboard) {}
+
+// Result is checkmate or stalemate
+func (Standard) Result(c *Chessboard, moves []Move) Result {
+	if len(moves) > 0 {
+		return ONGOING
+	}
+	if !c.InCheck() {
+		return DRAW
+	}
+	return winner(!c.WhiteToMove)
+}
+
+func winner(color bool) Result {
+	if color == WHITE {
+		return WHITEWINS
+	}
+	return BLACKWINS
+}
+
+func (c *Chessboard) variant() Variant {
+	if c.Variant == nil {
+		return Standard{}
+	}
+	return c.Variant
+}
+
+// CreateVariantChessboard sets up a board for variant from FEN, or from the
+// variant's starting position if FEN is empty
+func CreateVariantChessboard(variant Variant, FEN string) (Chessboard, error) {
+	if FEN == "" {
+		FEN = variant.StartingFEN()
+	}
+	chessgame := Chessboard{Variant: variant}
+	standardFEN, err := variant.ParseFEN(&chessgame, FEN)
+	if err != nil {
+		return Chessboard{}, err
+	}
+	chessgame.loadFEN(standardFEN)
+	chessgame.start = FEN
+	return chessgame, nil
+}
+
+var (
+	variantsMu sync.RWMutex
+	variants   = map[string]Variant{}
+)
+
+func init() {
+	RegisterVariant(Standard{})
+}
+
+// RegisterVariant makes variant available to VariantByName. A variant
+// registered with the name of another one replaces it
+func RegisterVariant(variant Variant) {
+	variantsMu.Lock()
+	defer variantsMu.Unlock()
+	variants[variant.Name()] = variant
+}
+
+// VariantByName finds a registered variant, eg from a PGN Variant tag
+func VariantByName(name string) (Variant, bool) {
+	variantsMu.RLock()
+	defer variantsMu.RUnlock()
+	variant, ok := variants[name]
+	return variant, ok
+}
+
+// Variants returns the names of the registered variants, sorted
+func Variants() []string {
+	variantsMu.RLock()
+	defer variantsMu.RUnlock()
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,101 @@
+package chessboard
+
+import "math/bits"
+
+// zobrist keys, indexed by piece and by pairToInt(square)
+var (
+	zobristPieces      [13][64]uint64
+	zobristCastling    [4]uint64
+	zobristEnPassant   [8]uint64
+	zobristWhiteToMove uint64
+
+	// crazyhouse: pockets indexed by piece and count, promoted pieces by square
+	zobristPockets  [13][MAXPOCKET + 1]uint64
+	zobristPromoted [64]uint64
+
+	// three-check: checks given by white and black
+	zobristChecks [2][4]uint64
+)
+
+func init() {
+	// splitmix64 with a fixed seed so hashes stay the same between runs
+	state := uint64(0x2545f4914f6cdd1d)
+	next := func() uint64 {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+
+	for piece := WKING; piece <= BPAWN; piece++ {
+		for square := 0; square < 64; square++ {
+			zobristPieces[piece][square] = next()
+		}
+	}
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	zobristWhiteToMove = next()
+	// generated last so standard hashes don't change
+	for piece := WKING; piece <= BPAWN; piece++ {
+		for count := 1; count <= MAXPOCKET; count++ {
+			zobristPockets[piece][count] = next()
+		}
+	}
+	for square := range zobristPromoted {
+		zobristPromoted[square] = next()
+	}
+	for count := 1; count < 4; count++ {
+		zobristChecks[0][count] = next()
+		zobristChecks[1][count] = next()
+	}
+}
+
+// Hash returns the Zobrist hash of the current position. Two boards with the
+// same pieces, side to move, castling rights and en passant square hash the same.
+// Crazyhouse pockets and promoted pieces and three-check counters are hashed too.
+func (c *Chessboard) Hash() uint64 {
+	var hash uint64
+	for piece := WKING; piece <= BPAWN; piece++ {
+		for bitboard := c.BoardState[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			hash ^= zobristPieces[piece][bits.TrailingZeros64(bitboard)]
+		}
+	}
+
+	if c.WhiteToMove {
+		hash ^= zobristWhiteToMove
+	}
+
+	if c.WhiteKingCastle {
+		hash ^= zobristCastling[0]
+	}
+	if c.WhiteQueenCastle {
+		hash ^= zobristCastling[1]
+	}
+	if c.BlackKingCastle {
+		hash ^= zobristCastling[2]
+	}
+	if c.BlackQueenCastle {
+		hash ^= zobristCastling[3]
+	}
+
+	// the zero pair means there is no en passant square
+	if c.EnPassantSquare != (pair{}) {
+		hash ^= zobristEnPassant[c.EnPassantSquare.col]
+	}
+
+	for piece, count := range c.Pockets {
+		hash ^= zobristPockets[piece][min(count, MAXPOCKET)]
+	}
+	for bitboard := c.Promoted; bitboard != 0; bitboard &= bitboard - 1 {
+		hash ^= zobristPromoted[bits.TrailingZeros64(bitboard)]
+	}
+	hash ^= zobristChecks[0][min(c.WhiteChecksGiven, 3)]
+	hash ^= zobristChecks[1][min(c.BlackChecksGiven, 3)]
+
+	return hash
+}
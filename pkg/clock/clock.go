@@ -0,0 +1,255 @@
+package clock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFlagFell   = errors.New("flag fell")
+	ErrNotRunning = errors.New("the clock is stopped")
+	ErrWrongSide  = errors.New("it is not that side's turn on the clock")
+)
+
+// TimeSource tells the time. Use a *ManualTime in tests
+type TimeSource interface {
+	Now() time.Time
+}
+
+type systemTime struct{}
+
+func (systemTime) Now() time.Time { return time.Now() }
+
+// ManualTime is a TimeSource that only moves when told to
+type ManualTime struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (m *ManualTime) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *ManualTime) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+// side indexes, white first
+const (
+	white = 0
+	black = 1
+)
+
+// Clock is a chess clock for both sides. It is safe for concurrent use
+type Clock struct {
+	mu      sync.Mutex
+	control TimeControl
+	source  TimeSource
+
+	remaining [2]time.Duration
+	period    [2]int // index in control.Periods
+	moves     [2]int // moves made in the current period
+
+	started   bool // by Start or the first Press, a stopped clock stays started
+	running   bool
+	turn      int
+	turnStart time.Time
+	flagged   bool
+}
+
+// New sets both sides to the first period of control. source nil uses the system time
+func New(control TimeControl, source TimeSource) *Clock {
+	if source == nil {
+		source = systemTime{}
+	}
+	c := &Clock{control: control, source: source}
+	if len(control.Periods) > 0 {
+		c.remaining[white] = control.Periods[0].Time
+		c.remaining[black] = control.Periods[0].Time
+	}
+	return c
+}
+
+func sideIndex(whiteSide bool) int {
+	if whiteSide {
+		return white
+	}
+	return black
+}
+
+// Clone returns a clock in the same state that runs on its own from now on.
+// The clone of nil is nil
+func (c *Clock) Clone() *Clock {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &Clock{
+		control:   c.control,
+		source:    c.source,
+		remaining: c.remaining,
+		period:    c.period,
+		moves:     c.moves,
+		started:   c.started,
+		running:   c.running,
+		turn:      c.turn,
+		turnStart: c.turnStart,
+		flagged:   c.flagged,
+	}
+}
+
+// Control returns the time control the clock was set up with
+func (c *Clock) Control() TimeControl {
+	return c.control
+}
+
+// Start runs the clock of one side
+func (c *Clock) Start(whiteSide bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started, c.running, c.turn, c.turnStart = true, true, sideIndex(whiteSide), c.source.Now()
+}
+
+// Stop freezes both clocks, eg when the game is over
+func (c *Clock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		c.remaining = c.live()
+		c.running = false
+	}
+}
+
+// SetTurn runs the clock of one side from now on, eg after a takeback. The
+// time the other side used is not given back. A stopped clock stays stopped
+func (c *Clock) SetTurn(whiteSide bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		c.remaining = c.live()
+		c.turnStart = c.source.Now()
+	}
+	c.turn = sideIndex(whiteSide)
+}
+
+// Press ends the turn of the side that moved and runs the other side's
+// clock. The first press starts the clock if it wasn't started. It returns
+// ErrFlagFell, and stops the clock, if the side that moved ran out of time
+func (c *Clock) Press(whiteSide bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	side := sideIndex(whiteSide)
+	now := c.source.Now()
+
+	switch {
+	case c.flagged:
+		return ErrFlagFell
+	case !c.started:
+		c.started, c.running, c.turn, c.turnStart = true, true, 1-side, now
+		return nil
+	case !c.running:
+		return ErrNotRunning
+	case side != c.turn:
+		return ErrWrongSide
+	}
+
+	remaining := c.live()
+	if remaining[side] <= 0 {
+		c.flagged, c.running = true, false
+		c.remaining = remaining
+		c.remaining[side] = 0
+		return ErrFlagFell
+	}
+	c.remaining = remaining
+
+	used := now.Sub(c.turnStart)
+	period := c.currentPeriod(side)
+	switch c.control.Mode {
+	case FISCHER:
+		c.remaining[side] += period.Increment
+	case BRONSTEIN:
+		c.remaining[side] += min(used, period.Increment)
+	}
+
+	// next period
+	c.moves[side]++
+	if period.Moves > 0 && c.moves[side] == period.Moves {
+		c.moves[side] = 0
+		if c.period[side] < len(c.control.Periods)-1 {
+			c.period[side]++
+		}
+		c.remaining[side] += c.currentPeriod(side).Time
+	}
+
+	c.turn, c.turnStart = 1-side, now
+	return nil
+}
+
+func (c *Clock) currentPeriod(side int) Period {
+	if len(c.control.Periods) == 0 {
+		return Period{}
+	}
+	return c.control.Periods[c.period[side]]
+}
+
+// live returns the remaining times counting the running turn
+func (c *Clock) live() [2]time.Duration {
+	remaining := c.remaining
+	if !c.running {
+		return remaining
+	}
+	used := c.source.Now().Sub(c.turnStart)
+	switch c.control.Mode {
+	case DELAY:
+		remaining[c.turn] -= max(0, used-c.currentPeriod(c.turn).Increment)
+	case HOURGLASS:
+		remaining[c.turn] -= used
+		remaining[1-c.turn] += used
+	default:
+		remaining[c.turn] -= used
+	}
+	return remaining
+}
+
+// Adjust sets the time left of both sides, like an arbiter does, or when a
+// game is restored. A running turn starts again from now
+func (c *Clock) Adjust(white, black time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remaining = [2]time.Duration{white, black}
+	if c.running {
+		c.turnStart = c.source.Now()
+	}
+	c.flagged = false
+}
+
+// Remaining returns the time left for one side, never below 0
+func (c *Clock) Remaining(whiteSide bool) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(0, c.live()[sideIndex(whiteSide)])
+}
+
+// Flagged reports if the side whose clock is running is out of time.
+// whiteSide is that side
+func (c *Clock) Flagged() (whiteSide bool, flagged bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.flagged && c.running && c.live()[c.turn] <= 0 {
+		c.flagged = true
+	}
+	return c.turn == white, c.flagged
+}
+
+// Running reports if the clock is running, and for which side
+func (c *Clock) Running() (whiteSide bool, running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.turn == white, c.running
+}
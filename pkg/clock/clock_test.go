@@ -0,0 +1,115 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	for _, tag := range []string{"300+2", "40/5400+30:1800+30", "*60", "600"} {
+		control, err := ParseTimeControl(tag)
+		if err != nil || control.String() != tag {
+			t.Errorf("ParseTimeControl(%s).String() = %s, %v", tag, control.String(), err)
+		}
+	}
+	for _, tag := range []string{"", "40/", "abc", "300+x", "*"} {
+		if _, err := ParseTimeControl(tag); err == nil {
+			t.Errorf("ParseTimeControl(%q) should fail", tag)
+		}
+	}
+}
+
+// play presses the clock after each duration, white first
+func play(t *testing.T, clock *Clock, source *ManualTime, durations ...time.Duration) {
+	t.Helper()
+	white := true
+	for _, d := range durations {
+		source.Advance(d)
+		if err := clock.Press(white); err != nil {
+			t.Fatal(err)
+		}
+		white = !white
+	}
+}
+
+func TestModes(t *testing.T) {
+	second := time.Second
+	tests := []struct {
+		mode         Mode
+		durations    []time.Duration
+		white, black time.Duration
+	}{
+		// 60 seconds with 5 seconds of increment or delay
+		{FISCHER, []time.Duration{3 * second, 8 * second}, 62 * second, 57 * second},
+		{BRONSTEIN, []time.Duration{3 * second, 8 * second}, 60 * second, 57 * second},
+		{DELAY, []time.Duration{3 * second, 8 * second}, 60 * second, 57 * second},
+		{HOURGLASS, []time.Duration{10 * second, 4 * second}, 54 * second, 66 * second},
+	}
+	for _, test := range tests {
+		source := &ManualTime{}
+		control := TimeControl{Mode: test.mode, Periods: []Period{{Time: 60 * second, Increment: 5 * second}}}
+		clock := New(control, source)
+		clock.Start(true)
+		play(t, clock, source, test.durations...)
+		if white, black := clock.Remaining(true), clock.Remaining(false); white != test.white || black != test.black {
+			t.Errorf("mode %d: remaining %v and %v, should be %v and %v", test.mode, white, black, test.white, test.black)
+		}
+	}
+}
+
+func TestPeriodsAndFlag(t *testing.T) {
+	source := &ManualTime{}
+	control, _ := ParseTimeControl("2/60:30")
+	clock := New(control, source)
+	clock.Start(true)
+
+	play(t, clock, source, 10*time.Second, 10*time.Second, 10*time.Second)
+	// white made its 2 moves and got the 30 seconds of the next period
+	if remaining := clock.Remaining(true); remaining != 70*time.Second {
+		t.Errorf("white has %v, should have 70s", remaining)
+	}
+
+	source.Advance(51 * time.Second)
+	if white, flagged := clock.Flagged(); !flagged || white {
+		t.Errorf("Flagged() = %v, %v, black should be out of time", white, flagged)
+	}
+	if err := clock.Press(false); err != ErrFlagFell {
+		t.Errorf("Press after the flag fell = %v, should be ErrFlagFell", err)
+	}
+	if err := clock.Press(true); err != ErrFlagFell {
+		t.Errorf("Press after the flag fell = %v, should be ErrFlagFell", err)
+	}
+
+	// the arbiter gives black more time
+	clock.Adjust(70*time.Second, 5*time.Second)
+	clock.Start(false)
+	source.Advance(time.Second)
+	// and the second move of black adds the 30 seconds of the next period
+	if err := clock.Press(false); err != nil || clock.Remaining(false) != 34*time.Second {
+		t.Errorf("after Adjust, Press = %v and black has %v, should have 34s", err, clock.Remaining(false))
+	}
+}
+
+func TestStopBeforeAMove(t *testing.T) {
+	// the time never moves, so the clock was started at the zero time
+	source := &ManualTime{}
+	control, _ := ParseTimeControl("60")
+	clock := New(control, source)
+	clock.Start(true)
+	clock.Stop()
+	if err := clock.Press(true); err != ErrNotRunning {
+		t.Errorf("Press on a stopped clock = %v, should be ErrNotRunning", err)
+	}
+	if _, running := clock.Running(); running {
+		t.Errorf("a stopped clock was started again by Press")
+	}
+
+	// a clock that was never started starts on the first press
+	clock = New(control, source)
+	if err := clock.Press(true); err != nil {
+		t.Fatal(err)
+	}
+	if white, running := clock.Running(); white || !running {
+		t.Errorf("after the first press, Running() = %v, %v, black should be running", white, running)
+	}
+}
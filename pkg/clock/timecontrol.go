@@ -0,0 +1,98 @@
+package clock
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode is how a clock gives time back after a move
+type Mode int
+
+const (
+	FISCHER   Mode = iota // Increment is added after every move
+	BRONSTEIN             // the time used is given back, up to Increment
+	DELAY                 // simple (US) delay: the clock waits Increment before counting down
+	HOURGLASS             // the time one side uses is added to the other side
+)
+
+// Period of a time control. Moves is how many moves each side has to make
+// in Time, 0 for the rest of the game. Increment is the increment or the
+// delay, depending on the Mode
+type Period struct {
+	Moves     int
+	Time      time.Duration
+	Increment time.Duration
+}
+
+// TimeControl is one or more periods. Time of the next period is added once
+// the moves of a period are made. The last period repeats if it has Moves
+type TimeControl struct {
+	Mode    Mode
+	Periods []Period
+}
+
+// ParseTimeControl reads a PGN TimeControl tag, eg "300+2", "40/5400+30:1800+30"
+// or "*60" for an hourglass. Times are in seconds
+func ParseTimeControl(tag string) (TimeControl, error) {
+	control := TimeControl{Mode: FISCHER}
+	if strings.HasPrefix(tag, "*") {
+		seconds, err := strconv.Atoi(tag[1:])
+		if err != nil || seconds <= 0 {
+			return TimeControl{}, errors.New("invalid time control: " + tag)
+		}
+		control.Mode = HOURGLASS
+		control.Periods = []Period{{Time: time.Duration(seconds) * time.Second}}
+		return control, nil
+	}
+
+	for _, field := range strings.Split(tag, ":") {
+		var period Period
+		if moves, rest, found := strings.Cut(field, "/"); found {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return TimeControl{}, errors.New("invalid time control: " + tag)
+			}
+			period.Moves, field = n, rest
+		}
+		seconds, increment, found := strings.Cut(field, "+")
+		n, err := strconv.Atoi(seconds)
+		if err != nil || n <= 0 {
+			return TimeControl{}, errors.New("invalid time control: " + tag)
+		}
+		period.Time = time.Duration(n) * time.Second
+		if found {
+			n, err := strconv.Atoi(increment)
+			if err != nil || n < 0 {
+				return TimeControl{}, errors.New("invalid time control: " + tag)
+			}
+			period.Increment = time.Duration(n) * time.Second
+		}
+		control.Periods = append(control.Periods, period)
+	}
+	return control, nil
+}
+
+// String writes the time control like a PGN TimeControl tag. Bronstein and
+// delay can't be told apart from Fischer there
+func (tc TimeControl) String() string {
+	if len(tc.Periods) == 0 {
+		return "-"
+	}
+	if tc.Mode == HOURGLASS {
+		return "*" + strconv.Itoa(int(tc.Periods[0].Time/time.Second))
+	}
+	fields := make([]string, len(tc.Periods))
+	for i, period := range tc.Periods {
+		field := strconv.Itoa(int(period.Time / time.Second))
+		if period.Moves > 0 {
+			field = strconv.Itoa(period.Moves) + "/" + field
+		}
+		if period.Increment > 0 {
+			field += "+" + strconv.Itoa(int(period.Increment/time.Second))
+		}
+		fields[i] = field
+	}
+	return strings.Join(fields, ":")
+}
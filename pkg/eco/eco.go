@@ -0,0 +1,151 @@
+package eco
+
+// ECO classification of openings. The database is the lichess opening table
+// (github.com/lichess-org/chess-openings, public domain), every ECO code from
+// A00 to E99: a line per opening, "ECO<tab>Name: Variation<tab>moves"
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+//go:embed eco.tsv
+var database string
+
+const initialFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Opening is an entry of the database
+type Opening struct {
+	ECO       string // eg "B90"
+	Name      string // eg "Sicilian Defense"
+	Variation string // eg "Najdorf Variation", "" for the main opening
+	Moves     []string
+}
+
+// FullName returns "Name: Variation", or Name when there is no variation
+func (o Opening) FullName() string {
+	if o.Variation == "" {
+		return o.Name
+	}
+	return o.Name + ": " + o.Variation
+}
+
+var (
+	loadOnce sync.Once
+	openings []Opening
+	byMoves  map[string]int // moves in UCI, separated by spaces
+	byKey    map[string]int // position after the moves, see positionKey
+	maxPly   int            // the longest line
+)
+
+// load reads the database the first time it is needed. The lines are checked
+// by the tests, a bad one is a bug
+func load() {
+	byMoves = map[string]int{}
+	byKey = map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(database), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			panic("eco: bad line: " + line)
+		}
+		o := Opening{ECO: fields[0], Name: fields[1]}
+		if name, variation, found := strings.Cut(fields[1], ": "); found {
+			o.Name, o.Variation = name, variation
+		}
+
+		board := chessboard.CreateChessboard("")
+		for _, san := range strings.Fields(fields[2]) {
+			if strings.HasSuffix(san, ".") {
+				continue
+			}
+			if err := board.MakeSANMove(san); err != nil {
+				panic("eco: " + o.ECO + " " + fields[1] + ": " + err.Error())
+			}
+			o.Moves = append(o.Moves, san)
+		}
+
+		i := len(openings)
+		openings = append(openings, o)
+		byMoves[strings.Join(board.Moves, " ")] = i
+		// the first line that gets to a position names it
+		if _, ok := byKey[positionKey(&board)]; !ok {
+			byKey[positionKey(&board)] = i
+		}
+		maxPly = max(maxPly, len(board.Moves))
+	}
+}
+
+// positionKey is the FEN without en passant and the move counters. The pgn
+// package writes ECO tags, so polyglot keys, which need pgn, can't be used
+func positionKey(c *chessboard.Chessboard) string {
+	return strings.Join(strings.Fields(c.GetFEN())[:3], " ")
+}
+
+// Openings returns every opening of the database
+func Openings() []Opening {
+	loadOnce.Do(load)
+	return append([]Opening(nil), openings...)
+}
+
+// Lookup returns the opening of a position, whatever the moves that led to it
+func Lookup(c *chessboard.Chessboard) (Opening, bool) {
+	loadOnce.Do(load)
+	if c.Variant != nil && c.Variant.Name() != (chessboard.Standard{}).Name() {
+		return Opening{}, false
+	}
+	i, ok := byKey[positionKey(c)]
+	if !ok {
+		return Opening{}, false
+	}
+	return openings[i], true
+}
+
+// Classify returns the opening of the game on c: the last opening its moves
+// went through. A line of the database is matched by its moves, and when the
+// game got there in another order, by its position
+func Classify(c *chessboard.Chessboard) (Opening, bool) {
+	loadOnce.Do(load)
+	board, err := c.StartingBoard()
+	if err != nil || (board.Variant != nil && board.Variant.Name() != (chessboard.Standard{}).Name()) {
+		return Opening{}, false
+	}
+	fromStart := board.GetFEN() == initialFEN
+
+	found := -1
+	for ply, uci := range c.Moves {
+		if ply >= maxPly {
+			break
+		}
+		if err := board.MakeUCIMove(uci); err != nil {
+			break
+		}
+		if i, ok := byMoves[strings.Join(board.Moves, " ")]; ok && fromStart {
+			found = i
+		} else if i, ok := byKey[positionKey(&board)]; ok {
+			found = i
+		}
+	}
+	if found == -1 {
+		return Opening{}, false
+	}
+	return openings[found], true
+}
+
+// SetTags classifies the game on c and sets the ECO, Opening and Variation
+// tags that aren't set yet. It returns false if the opening is unknown
+func SetTags(tags *pgntags.PGNTags, c *chessboard.Chessboard) bool {
+	o, ok := Classify(c)
+	if !ok {
+		return false
+	}
+	for _, tag := range []pgntags.Tag{{Name: "ECO", Value: o.ECO}, {Name: "Opening", Value: o.Name}, {Name: "Variation", Value: o.Variation}} {
+		if _, set := tags.Get(tag.Name); !set && tag.Value != "" {
+			tags.Set(tag.Name, tag.Value)
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package eco
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+func TestClassify(t *testing.T) {
+	// every line of the database is legal, load panics if not
+	openings := Openings()
+	codes := map[string]bool{}
+	for _, o := range openings {
+		if len(o.ECO) != 3 || o.ECO[0] < 'A' || o.ECO[0] > 'E' || len(o.Moves) == 0 {
+			t.Errorf("bad opening %+v", o)
+		}
+		codes[o.ECO] = true
+	}
+	// all of A00 to E99 but a few codes that are no longer used
+	if len(codes) < 490 {
+		t.Errorf("the database has %d ECO codes, should have nearly 500", len(codes))
+	}
+
+	tests := []struct {
+		moves string
+		eco   string
+		name  string
+	}{
+		{"e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5c6", "C68", "Ruy Lopez: Exchange Variation"},
+		{"e2e4 e7e6 d2d4 d7d5 b1c3 f8b4 e4e5 c7c5 a2a3", "C17", "French Defense: Winawer Variation, Advance Variation"},
+		// transpositions: the West Indian from the English, D02 with d4 played last
+		{"c2c4 g7g6 d2d4 g8f6", "E60", "Indian Defense: West Indian Defense"},
+		{"g1f3 d7d5 d2d4", "D02", "Queen's Pawn Game: Zukertort Variation"},
+		// out of the database after Be3, the last opening counts
+		{"e2e4 c7c5 g1f3 d7d6 d2d4 c5d4 f3d4 g8f6 b1c3 a7a6 c1e3 e7e5 d4b3", "B90", "Sicilian Defense: Najdorf Variation, English Attack"},
+	}
+	for _, test := range tests {
+		board := chessboard.CreateChessboard("")
+		for _, uci := range strings.Fields(test.moves) {
+			if err := board.MakeUCIMove(uci); err != nil {
+				t.Fatal(err)
+			}
+		}
+		o, ok := Classify(&board)
+		if !ok || o.ECO != test.eco || o.FullName() != test.name {
+			t.Errorf("%s: %+v, should be %s %s", test.moves, o, test.eco, test.name)
+		}
+	}
+
+	board := chessboard.CreateChessboard("")
+	if _, ok := Classify(&board); ok {
+		t.Errorf("the starting position has no opening")
+	}
+	board = chessboard.CreateChessboard("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	board.MakeUCIMove("e2e4")
+	if _, ok := Classify(&board); ok {
+		t.Errorf("an endgame has no opening")
+	}
+
+	// a position from FEN, by its key
+	board = chessboard.CreateChessboard("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2")
+	if o, ok := Lookup(&board); !ok || o.ECO != "B20" || o.Variation != "" {
+		t.Errorf("Lookup(1. e4 c5) = %+v", o)
+	}
+
+	// tags that are already set are kept
+	board = chessboard.CreateChessboard("")
+	for _, uci := range strings.Fields("d2d4 d7d5 c2c4 c7c6") {
+		board.MakeUCIMove(uci)
+	}
+	tags := pgntags.PGNTags{}
+	tags.Set("Opening", "my favourite")
+	if !SetTags(&tags, &board) {
+		t.Fatal("the Slav should be classified")
+	}
+	eco, _ := tags.Get("ECO")
+	opening, _ := tags.Get("Opening")
+	if _, ok := tags.Get("Variation"); eco != "D10" || opening != "my favourite" || ok {
+		t.Errorf("tags: %+v", tags)
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"math/bits"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// piece values in centipawns, from the king down to the pawn
+var pieceValues = [6]int{0, 900, 500, 330, 320, 100}
+
+// Board is the Position of a Chessboard, to search real games
+type Board struct {
+	c chessboard.Chessboard
+}
+
+// NewBoard searches from the position on c. The moves and the clock of c
+// are left alone
+func NewBoard(c chessboard.Chessboard) *Board {
+	c.Moves, c.Clock = nil, nil
+	return &Board{c: c}
+}
+
+func (b *Board) Hash() uint64 { return b.c.Hash() }
+
+func (b *Board) Moves() []string {
+	var moves []string
+	for _, m := range b.c.GetMoveList() {
+		moves = append(moves, m.String())
+	}
+	return moves
+}
+
+func (b *Board) Play(move string) Position {
+	next := b.c
+	next.Moves = nil
+	next.MakeUCIMove(move)
+	return &Board{c: next}
+}
+
+func (b *Board) InCheck() bool { return b.c.InCheck() }
+
+// Evaluate counts the material
+func (b *Board) Evaluate() int {
+	score := 0
+	for i, value := range pieceValues {
+		score += value * (bits.OnesCount64(b.c.BoardState[chessboard.WKING+i]) - bits.OnesCount64(b.c.BoardState[chessboard.BKING+i]))
+	}
+	if !b.c.WhiteToMove {
+		score = -score
+	}
+	return score
+}
+
+// pieces counts the pieces on the board, kings included
+func (b *Board) pieces() int {
+	count := 0
+	for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+		count += bits.OnesCount64(b.c.BoardState[piece])
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kahnaisehC/chessboard/pkg/syzygy"
+)
+
+// Engine keeps what lasts from one search to the next: the transposition
+// table, the endgame tables and the UCI options
+type Engine struct {
+	TT      *TranspositionTable
+	hash    int // MB
+	threads int
+	tb      *syzygy.Tablebase // nil without a SyzygyPath
+}
+
+func New() *Engine {
+	return &Engine{TT: NewTranspositionTable(HASHDEFAULT), hash: HASHDEFAULT, threads: THREADSDEFAULT}
+}
+
+// Options returns the UCI options of the engine, as sent after "uci"
+func (e *Engine) Options() []string {
+	return []string{
+		"option name Hash type spin default " + strconv.Itoa(HASHDEFAULT) + " min " + strconv.Itoa(HASHMIN) + " max " + strconv.Itoa(HASHMAX),
+		"option name Threads type spin default " + strconv.Itoa(THREADSDEFAULT) + " min " + strconv.Itoa(THREADSMIN) + " max " + strconv.Itoa(THREADSMAX),
+		"option name SyzygyPath type string default <empty>",
+	}
+}
+
+// SetOption sets a UCI option, eg "setoption name Hash value 64" is
+// SetOption("Hash", "64"). Names are case insensitive like in UCI. Setting
+// Hash clears the table. SetOption must not be called during a search
+func (e *Engine) SetOption(name, value string) error {
+	switch strings.ToLower(name) {
+	case "hash":
+		megabytes, err := spin(value, HASHMIN, HASHMAX)
+		if err != nil {
+			return errors.New("option Hash: " + err.Error())
+		}
+		if megabytes != e.hash {
+			e.TT.Resize(megabytes)
+			e.hash = megabytes
+		}
+		return nil
+	case "threads":
+		threads, err := spin(value, THREADSMIN, THREADSMAX)
+		if err != nil {
+			return errors.New("option Threads: " + err.Error())
+		}
+		e.threads = threads
+		return nil
+	case "syzygypath":
+		var tb *syzygy.Tablebase
+		if value != "" && value != "<empty>" {
+			var err error
+			if tb, err = syzygy.New(value); err != nil {
+				return errors.New("option SyzygyPath: " + err.Error())
+			}
+		}
+		if e.tb != nil {
+			e.tb.Close()
+		}
+		e.tb = tb
+		return nil
+	}
+	return errors.New("no such option: " + name)
+}
+
+// spin reads the value of a spin option
+func spin(value string, lowest, highest int) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, errors.New("not a number: " + value)
+	}
+	if n < lowest || n > highest {
+		return 0, errors.New(value + " is out of range " + strconv.Itoa(lowest) + " to " + strconv.Itoa(highest))
+	}
+	return n, nil
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kahnaisehC/chessboard/pkg/syzygy"
+)
+
+// Limits of the UCI "Threads" option, see Engine.SetOption
+const (
+	THREADSDEFAULT = 1
+	THREADSMIN     = 1
+	THREADSMAX     = 256
+)
+
+// MAXDEPTH is the deepest iteration a search goes to
+const MAXDEPTH = 64
+
+// TBWIN - ply is the score of a position the endgame tables win, below the mate scores
+const TBWIN = MATEBOUND - MAXDEPTH - 1
+
+// Position is what the search needs to know about a game. Moves are in
+// coordinate notation, like the moves the table stores
+type Position interface {
+	Hash() uint64
+	// Moves returns the legal moves, none when the game is over
+	Moves() []string
+	// Play returns the position after move, without changing this one
+	Play(move string) Position
+	InCheck() bool
+	// Evaluate scores the position from the side to move's point of view
+	Evaluate() int
+}
+
+// Result is the best move found and its score, from the side to move's point of view
+type Result struct {
+	Move  string
+	Score int
+	Depth int    // of the last iteration the main thread completed
+	Nodes uint64 // searched by all the threads
+}
+
+// Search looks for the best move of p with Threads goroutines sharing the
+// transposition table (Lazy SMP). The main goroutine deepens one ply at a
+// time up to depth, the helpers search the same position, half of them a ply
+// deeper, and fill the table with results the main one picks up. depth <= 0
+// searches until ctx is done. Depth 1 is always completed, the result is
+// the last iteration the main goroutine completed.
+//
+// Boards with few enough pieces are looked up in the endgame tables of
+// SyzygyPath: at the root only the moves that keep the result are searched,
+// deeper the tables' result ends the search
+func (e *Engine) Search(ctx context.Context, p Position, depth int) (Result, error) {
+	if len(p.Moves()) == 0 {
+		return Result{}, errors.New("no legal moves")
+	}
+	if depth <= 0 || depth > MAXDEPTH {
+		depth = MAXDEPTH
+	}
+	e.TT.NewSearch()
+
+	var stop atomic.Bool
+	// AfterFunc calls the function in a goroutine of its own, even when ctx is done already
+	stop.Store(ctx.Err() != nil)
+	cancel := context.AfterFunc(ctx, func() { stop.Store(true) })
+	defer cancel()
+
+	var rootMoves []string
+	if b, ok := p.(*Board); ok && e.tb != nil && b.pieces() <= e.tb.MaxPieces() {
+		if moves, _, err := e.tb.ProbeRoot(&b.c); err == nil {
+			rootMoves = moves
+		}
+	}
+
+	workers := make([]*worker, e.threads)
+	for i := range workers {
+		workers[i] = &worker{id: i, tt: e.TT, tb: e.tb, stop: &stop, rootMoves: rootMoves}
+	}
+	var wg sync.WaitGroup
+	for _, w := range workers[1:] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.iterate(p, min(depth+w.id%2, MAXDEPTH))
+		}()
+	}
+	result := workers[0].iterate(p, depth)
+	// the helpers are done once the main goroutine is
+	stop.Store(true)
+	wg.Wait()
+
+	for _, w := range workers {
+		result.Nodes += w.nodes
+	}
+	return result, nil
+}
+
+// worker is one goroutine of a search. Only the table is shared
+type worker struct {
+	id      int
+	tt      *TranspositionTable
+	tb      *syzygy.Tablebase
+	stop    *atomic.Bool
+	history [4096]int // by from and to square, raised by moves that cut off
+	nodes   uint64
+
+	rootMoves []string // nil for every legal move
+	rootDepth int
+	rootMove  string
+	aborted   bool
+}
+
+// iterate deepens the search up to depth and returns the last completed iteration
+func (w *worker) iterate(p Position, depth int) Result {
+	var result Result
+	for w.rootDepth = 1; w.rootDepth <= depth; w.rootDepth++ {
+		score := w.negamax(p, w.rootDepth, 0, -MATE, MATE)
+		if w.aborted {
+			break
+		}
+		result = Result{Move: w.rootMove, Score: score, Depth: w.rootDepth}
+		if w.stop.Load() {
+			break
+		}
+	}
+	return result
+}
+
+// negamax is alpha-beta from the side to move's point of view. Mates are
+// scored MATE - ply. The main goroutine never aborts its first iteration
+func (w *worker) negamax(p Position, depth, ply, alpha, beta int) int {
+	w.nodes++
+	if w.stop.Load() && (w.id != 0 || w.rootDepth > 1) {
+		w.aborted = true
+		return 0
+	}
+
+	hash := p.Hash()
+	ttMove := ""
+	if entry, ok := w.tt.Probe(hash, ply); ok {
+		ttMove = entry.Move
+		if ply > 0 && entry.Depth >= depth &&
+			(entry.Bound == EXACT ||
+				entry.Bound == LOWERBOUND && entry.Score >= beta ||
+				entry.Bound == UPPERBOUND && entry.Score <= alpha) {
+			return entry.Score
+		}
+	}
+
+	if ply > 0 && w.tb != nil {
+		if b, ok := p.(*Board); ok && b.pieces() <= w.tb.MaxPieces() {
+			if wdl, err := w.tb.ProbeWDL(&b.c); err == nil {
+				return tbScore(wdl, ply)
+			}
+		}
+	}
+
+	var moves []string
+	if ply == 0 && w.rootMoves != nil {
+		// sorted in place by order
+		moves = slices.Clone(w.rootMoves)
+	} else {
+		moves = p.Moves()
+	}
+	if len(moves) == 0 {
+		if p.InCheck() {
+			return -MATE + ply
+		}
+		return 0
+	}
+	if depth <= 0 {
+		return p.Evaluate()
+	}
+
+	w.order(moves, ttMove)
+	best, bestMove, bound := -MATE, "", UPPERBOUND
+	for _, move := range moves {
+		score := -w.negamax(p.Play(move), depth-1, ply+1, -beta, -alpha)
+		if w.aborted {
+			return 0
+		}
+		if score > best {
+			best, bestMove = score, move
+		}
+		if score > alpha {
+			alpha, bound = score, EXACT
+		}
+		if alpha >= beta {
+			w.history[packMove(move)&0xfff] += depth * depth
+			bound = LOWERBOUND
+			break
+		}
+	}
+	if ply == 0 {
+		w.rootMove = bestMove
+	}
+	w.tt.Store(hash, ply, TTEntry{Move: bestMove, Score: best, Depth: depth, Bound: bound})
+	return best
+}
+
+// tbScore scores a result of the endgame tables. Cursed wins and blessed
+// losses are draws by the 50 move rule
+func tbScore(wdl, ply int) int {
+	switch wdl {
+	case syzygy.WIN:
+		return TBWIN - ply
+	case syzygy.LOSS:
+		return -TBWIN + ply
+	}
+	return 0
+}
+
+// order puts the table's move first, then the moves that cut off most often
+func (w *worker) order(moves []string, ttMove string) {
+	slices.SortStableFunc(moves, func(a, b string) int {
+		switch {
+		case a == ttMove:
+			return -1
+		case b == ttMove:
+			return 1
+		}
+		return w.history[packMove(b)&0xfff] - w.history[packMove(a)&0xfff]
+	})
+}
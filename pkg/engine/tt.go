@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"sync/atomic"
+)
+
+// Bound types of a transposition table entry
+const (
+	NOBOUND = iota
+	EXACT
+	LOWERBOUND
+	UPPERBOUND
+)
+
+// Scores at or beyond MATEBOUND are mate scores, MATE - ply for the side that mates.
+const (
+	MATE      = 32000
+	MATEBOUND = MATE - 1000
+)
+
+// Limits of the UCI "Hash" option, in MB, see Engine.SetOption
+const (
+	HASHDEFAULT = 16
+	HASHMIN     = 1
+	HASHMAX     = 65536
+)
+
+const bucketSize = 4
+
+// each slot takes two words: the key xored with the data, and the data
+const slotBytes = 16
+
+type TTEntry struct {
+	Move  string // coordinate notation, eg "e2e4" or "e7e8q". Empty if unknown
+	Score int
+	Depth int
+	Bound int
+	Age   uint8
+}
+
+// ReplaceScheme gives a value to an entry already in the table. When a bucket
+// is full, the entry with the lowest value is overwritten.
+type ReplaceScheme func(e TTEntry, age uint8) int
+
+// DEPTHPREFERRED keeps deep entries from the current search over everything else
+var DEPTHPREFERRED ReplaceScheme = func(e TTEntry, age uint8) int {
+	return e.Depth - 8*int(age-e.Age)
+}
+
+// ALWAYSREPLACE keeps the entries from the current search and overwrites the oldest
+var ALWAYSREPLACE ReplaceScheme = func(e TTEntry, age uint8) int {
+	return -int(age - e.Age)
+}
+
+type slot struct {
+	key  atomic.Uint64 // hash ^ data
+	data atomic.Uint64
+}
+
+// TranspositionTable is a fixed size hash table of search results.
+// Probe and Store may be called from several goroutines at the same time
+// without locking: a slot that was torn by a concurrent write fails the key
+// check and reads as a miss. Resize and Clear must not run during a search.
+type TranspositionTable struct {
+	slots  []slot
+	mask   uint64
+	age    uint8
+	Scheme ReplaceScheme
+}
+
+func NewTranspositionTable(megabytes int) *TranspositionTable {
+	tt := &TranspositionTable{Scheme: DEPTHPREFERRED}
+	tt.Resize(megabytes)
+	return tt
+}
+
+// Resize throws away the table and allocates a new one of the given size in MB.
+// The number of buckets is rounded down to a power of two.
+func (tt *TranspositionTable) Resize(megabytes int) {
+	megabytes = max(HASHMIN, min(megabytes, HASHMAX))
+	buckets := uint64(megabytes) * 1024 * 1024 / (slotBytes * bucketSize)
+	for buckets&(buckets-1) != 0 {
+		buckets &= buckets - 1
+	}
+	tt.slots = make([]slot, buckets*bucketSize)
+	tt.mask = buckets - 1
+	tt.age = 0
+}
+
+func (tt *TranspositionTable) Clear() {
+	for i := range tt.slots {
+		tt.slots[i].key.Store(0)
+		tt.slots[i].data.Store(0)
+	}
+	tt.age = 0
+}
+
+// NewSearch ages the table so entries of previous searches get replaced first
+func (tt *TranspositionTable) NewSearch() {
+	tt.age++
+}
+
+func (tt *TranspositionTable) bucket(hash uint64) []slot {
+	start := (hash & tt.mask) * bucketSize
+	return tt.slots[start : start+bucketSize]
+}
+
+// Probe looks up hash. ply is the distance from the root, needed to turn
+// stored mate scores back into mate scores relative to the root.
+func (tt *TranspositionTable) Probe(hash uint64, ply int) (TTEntry, bool) {
+	bucket := tt.bucket(hash)
+	for i := range bucket {
+		data := bucket[i].data.Load()
+		if data == 0 || bucket[i].key.Load()^data != hash {
+			continue
+		}
+		entry := unpackEntry(data)
+		entry.Score = scoreFromTT(entry.Score, ply)
+		return entry, true
+	}
+	return TTEntry{}, false
+}
+
+func (tt *TranspositionTable) Store(hash uint64, ply int, entry TTEntry) {
+	entry.Age = tt.age
+	entry.Score = scoreToTT(entry.Score, ply)
+
+	bucket := tt.bucket(hash)
+	victim := -1
+	for i := range bucket {
+		data := bucket[i].data.Load()
+		if data != 0 && bucket[i].key.Load()^data == hash {
+			// same position: keep the old best move if we did not find one
+			if entry.Move == "" {
+				entry.Move = unpackEntry(data).Move
+			}
+			victim = i
+			break
+		}
+		if data == 0 && victim == -1 {
+			victim = i
+		}
+	}
+
+	if victim == -1 {
+		lowest := 0
+		for i := range bucket {
+			value := tt.Scheme(unpackEntry(bucket[i].data.Load()), tt.age)
+			if victim == -1 || value < lowest {
+				victim, lowest = i, value
+			}
+		}
+	}
+
+	data := packEntry(entry)
+	bucket[victim].data.Store(data)
+	bucket[victim].key.Store(hash ^ data)
+}
+
+// Hashfull returns how full the table is in permill, counting only entries of the current search
+func (tt *TranspositionTable) Hashfull() int {
+	sample := min(1000, len(tt.slots))
+	used := 0
+	for i := 0; i < sample; i++ {
+		data := tt.slots[i].data.Load()
+		if data != 0 && unpackEntry(data).Age == tt.age {
+			used++
+		}
+	}
+	return used * 1000 / sample
+}
+
+// mate scores are stored relative to the position instead of the root
+func scoreToTT(score, ply int) int {
+	if score >= MATEBOUND {
+		return score + ply
+	}
+	if score <= -MATEBOUND {
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	if score >= MATEBOUND {
+		return score - ply
+	}
+	if score <= -MATEBOUND {
+		return score + ply
+	}
+	return score
+}
+
+/*
+data layout:
+bits  0-15 move
+bits 16-31 score (int16)
+bits 32-39 depth (int8)
+bits 40-41 bound
+bits 48-55 age
+bit  63    always set, so a used slot is never zero
+*/
+func packEntry(e TTEntry) uint64 {
+	data := uint64(packMove(e.Move))
+	data |= uint64(uint16(int16(e.Score))) << 16
+	data |= uint64(uint8(int8(e.Depth))) << 32
+	data |= uint64(e.Bound&3) << 40
+	data |= uint64(e.Age) << 48
+	data |= 1 << 63
+	return data
+}
+
+func unpackEntry(data uint64) TTEntry {
+	return TTEntry{
+		Move:  unpackMove(uint16(data)),
+		Score: int(int16(uint16(data >> 16))),
+		Depth: int(int8(uint8(data >> 32))),
+		Bound: int(data>>40) & 3,
+		Age:   uint8(data >> 48),
+	}
+}
+
+const promotionChars = "_nbrq"
+
+// packMove fits a coordinate notation move in 16 bits:
+// 6 bits from square, 6 bits to square, 3 bits promotion. 0 is no move
+func packMove(move string) uint16 {
+	if len(move) < 4 {
+		return 0
+	}
+	from := uint16(move[1]-'1')*8 + uint16(move[0]-'a')
+	to := uint16(move[3]-'1')*8 + uint16(move[2]-'a')
+	promotion := uint16(0)
+	if len(move) > 4 {
+		for i := 1; i < len(promotionChars); i++ {
+			if move[4] == promotionChars[i] {
+				promotion = uint16(i)
+			}
+		}
+	}
+	return from | to<<6 | promotion<<12
+}
+
+func unpackMove(m uint16) string {
+	if m == 0 {
+		return ""
+	}
+	from, to, promotion := m&63, (m>>6)&63, (m>>12)&7
+	move := []byte{
+		byte('a' + from%8), byte('1' + from/8),
+		byte('a' + to%8), byte('1' + to/8),
+	}
+	if promotion != 0 {
+		move = append(move, promotionChars[promotion])
+	}
+	return string(move)
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+func TestTTStoreProbe(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	entry := TTEntry{Move: "e7e8q", Score: -123, Depth: 7, Bound: LOWERBOUND}
+	tt.Store(0xdeadbeef, 0, entry)
+
+	got, ok := tt.Probe(0xdeadbeef, 0)
+	if !ok {
+		t.Fatalf("Probe(0xdeadbeef) missed a stored entry")
+	}
+	if got != entry {
+		t.Errorf("Probe(0xdeadbeef) = %v, should equal %v", got, entry)
+	}
+
+	if _, ok := tt.Probe(0xdeadbeee, 0); ok {
+		t.Errorf("Probe(0xdeadbeee) hit an entry that was never stored")
+	}
+}
+
+func TestTTMateScores(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	// mate in 3 plies seen from 5 plies deep is mate in 8 from the root
+	tt.Store(42, 5, TTEntry{Score: MATE - 8, Depth: 3, Bound: EXACT})
+
+	got, _ := tt.Probe(42, 1)
+	if got.Score != MATE-4 {
+		t.Errorf("mate score probed at ply 1 = %d, should equal %d", got.Score, MATE-4)
+	}
+}
+
+func TestTTReplacement(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	buckets := tt.mask + 1
+
+	// fill one bucket, then store one more position into it
+	for i := uint64(0); i < bucketSize; i++ {
+		tt.Store(i*buckets, 0, TTEntry{Depth: int(10 + i)})
+	}
+	tt.Store(bucketSize*buckets, 0, TTEntry{Depth: 1})
+
+	// the shallowest entry is the one that gets overwritten
+	if _, ok := tt.Probe(0, 0); ok {
+		t.Errorf("shallowest entry should have been replaced")
+	}
+	if _, ok := tt.Probe(bucketSize*buckets, 0); !ok {
+		t.Errorf("new entry was not stored")
+	}
+}
+
+func TestPackMove(t *testing.T) {
+	for _, move := range []string{"e2e4", "a7a8n", "h2h1q", "e1g1"} {
+		if got := unpackMove(packMove(move)); got != move {
+			t.Errorf("unpackMove(packMove(%q)) = %q", move, got)
+		}
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	e := New()
+	if len(e.TT.slots) != HASHDEFAULT*1024*1024/slotBytes {
+		t.Errorf("a new engine has %d slots, should have %d MB of them", len(e.TT.slots), HASHDEFAULT)
+	}
+	if err := e.SetOption("hash", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.TT.slots) != 1024*1024/slotBytes {
+		t.Errorf("after setting Hash to 1 the table has %d slots", len(e.TT.slots))
+	}
+	for _, test := range []struct{ name, value string }{
+		{"Hash", "0"},
+		{"Hash", "65537"},
+		{"Hash", "big"},
+		{"Threads", "0"},
+		{"Ponder", "true"},
+	} {
+		if err := e.SetOption(test.name, test.value); err == nil {
+			t.Errorf("SetOption(%s, %s) should fail", test.name, test.value)
+		}
+	}
+	if len(e.TT.slots) != 1024*1024/slotBytes {
+		t.Errorf("a failed SetOption resized the table")
+	}
+	if err := e.SetOption("Threads", "4"); err != nil || e.threads != 4 {
+		t.Errorf("SetOption(Threads, 4) = %v, threads = %d", err, e.threads)
+	}
+}
+
+// treePosition is a made up game: up to three moves per position, some
+// positions mated or stalemated, all of them over after depth plies. With
+// depth 0 the game never ends
+type treePosition struct {
+	hash  uint64
+	ply   int
+	depth int
+}
+
+func (p treePosition) Hash() uint64 { return p.hash }
+
+func (p treePosition) Moves() []string {
+	if p.depth != 0 && (p.ply == p.depth || p.ply > 0 && p.hash%7 == 0) {
+		return nil
+	}
+	return []string{"a1a2", "a1a3", "a1a4"}[:1+p.hash%3]
+}
+
+func (p treePosition) Play(move string) Position {
+	// splitmix64 of the position and the move
+	h := p.hash + uint64(move[3]-'1')*0x9e3779b97f4a7c15
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return treePosition{hash: h ^ h>>31, ply: p.ply + 1, depth: p.depth}
+}
+
+func (p treePosition) InCheck() bool { return p.hash%2 == 0 }
+
+func (p treePosition) Evaluate() int { return int(p.hash>>8%201) - 100 }
+
+// minimax is the score Search should find, without pruning or a table
+func minimax(p Position, depth, ply int) int {
+	moves := p.Moves()
+	if len(moves) == 0 {
+		if p.InCheck() {
+			return -MATE + ply
+		}
+		return 0
+	}
+	if depth == 0 {
+		return p.Evaluate()
+	}
+	best := -MATE
+	for _, move := range moves {
+		best = max(best, -minimax(p.Play(move), depth-1, ply+1))
+	}
+	return best
+}
+
+func TestSearch(t *testing.T) {
+	for _, root := range []uint64{1, 2, 3, 5, 8} {
+		// one thread searches exactly depth plies, with more threads the
+		// helpers search deeper so the game has to end before depth
+		for _, test := range []struct{ threads, depth, gameDepth int }{
+			{1, 4, 7},
+			{1, 7, 7},
+			{4, 8, 6},
+		} {
+			p := treePosition{hash: root, depth: test.gameDepth}
+			e := New()
+			e.SetOption("Threads", strconv.Itoa(test.threads))
+			result, err := e.Search(context.Background(), p, test.depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := minimax(p, test.depth, 0)
+			if result.Score != want || result.Depth != test.depth || result.Nodes == 0 {
+				t.Errorf("%d threads, root %d: depth %d score %d, should be depth %d score %d", test.threads, root, result.Depth, result.Score, test.depth, want)
+			}
+			if got := -minimax(p.Play(result.Move), test.depth-1, 1); got != want {
+				t.Errorf("%d threads, root %d: %s scores %d, the best move scores %d", test.threads, root, result.Move, got, want)
+			}
+		}
+	}
+
+	// a game without moves
+	p := treePosition{hash: 1, depth: 1, ply: 1}
+	if _, err := New().Search(context.Background(), p, 1); err == nil {
+		t.Errorf("searching a game that is over should fail")
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	e := New()
+	e.SetOption("Threads", "4")
+	// a game without end, searched until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := e.Search(ctx, treePosition{hash: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the search stopped %v after it was cancelled", elapsed)
+	}
+	if result.Move == "" || result.Depth < 1 {
+		t.Errorf("a cancelled search returned %+v", result)
+	}
+
+	// depth 1 is searched even when ctx is done already
+	cancel()
+	if result, err := e.Search(ctx, treePosition{hash: 3}, 0); err != nil || result.Depth != 1 || result.Move == "" {
+		t.Errorf("a search cancelled before it started = %+v, %v", result, err)
+	}
+}
+
+func TestBoard(t *testing.T) {
+	e := New()
+	e.SetOption("Threads", "2")
+	result, err := e.Search(context.Background(), NewBoard(chessboard.CreateChessboard("7k/8/6K1/8/8/8/Q7/8 w - - 0 1")), 3)
+	if err != nil || result.Move != "a2a8" || result.Score != MATE-1 {
+		t.Errorf("mate in one: %+v, %v", result, err)
+	}
+	// material, from the side to move's point of view
+	if score := NewBoard(chessboard.CreateChessboard("4k3/8/8/8/8/8/8/RN2K3 b - - 0 1")).Evaluate(); score != -820 {
+		t.Errorf("Evaluate() = %d, should be -820", score)
+	}
+
+	if err := e.SetOption("SyzygyPath", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("a SyzygyPath that doesn't exist should fail")
+	}
+	// the tables, if there are some, give the result at once
+	path := os.Getenv("SYZYGY_PATH")
+	if path == "" {
+		return
+	}
+	if err := e.SetOption("SyzygyPath", path); err != nil {
+		t.Fatal(err)
+	}
+	result, err = e.Search(context.Background(), NewBoard(chessboard.CreateChessboard("8/8/8/4k3/8/8/8/R3K3 w - - 0 1")), 2)
+	if err != nil || result.Score < TBWIN-MAXDEPTH {
+		t.Errorf("KRvK with SyzygyPath: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,267 @@
+package epd
+
+// Extended Position Description, the format of test suites like WAC or STS:
+// the first four fields of a FEN followed by opcodes, eg
+//
+//	r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - bm Nb5; id "WAC.004";
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// Position is an EPD record. The opcodes that have a field here are not
+// repeated in Ops
+type Position struct {
+	Board chessboard.Chessboard
+
+	BestMoves  []string // bm, in SAN
+	AvoidMoves []string // am, in SAN
+	ID         string   // id
+	Comment    string   // c0
+	Mate       int      // dm, full moves to mate, 0 if not given
+	Depth      int      // acd, the depth of the analysis, 0 if not given
+	Eval       *int     // ce, centipawns for the side to move, nil if not given
+	Ops        []Op     // every other opcode, in the order they were read
+}
+
+// Op is an opcode and its operands
+type Op struct {
+	Name     string
+	Operands []string
+}
+
+// Parse reads a record. The halfmove clock and move number come from the
+// hmvc and fmvn opcodes, 0 and 1 if they are missing
+func Parse(line string) (Position, error) {
+	var p Position
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return p, errors.New("EPD should start with 4 FEN fields")
+	}
+	fen := strings.Join(fields[:4], " ")
+
+	// skip the FEN fields, the opcodes are what is left
+	rest := line
+	for i := 0; i < 4; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		rest = rest[strings.IndexAny(rest+" ", " \t"):]
+	}
+	ops, err := parseOps(rest)
+	if err != nil {
+		return p, err
+	}
+
+	halfmove, fullmove := "0", "1"
+	for _, op := range ops {
+		switch op.Name {
+		case "hmvc":
+			halfmove, err = number(op)
+		case "fmvn":
+			fullmove, err = number(op)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+	fen += " " + halfmove + " " + fullmove
+	if ok, logs := chessboard.ValidateFEN(fen); !ok {
+		return p, errors.New("invalid position: " + logs)
+	}
+	p.Board = chessboard.CreateChessboard(fen)
+
+	for _, op := range ops {
+		switch op.Name {
+		case "hmvc", "fmvn":
+		case "bm", "am":
+			moves := make([]string, len(op.Operands))
+			for i, move := range op.Operands {
+				m, err := p.Board.MoveFromSAN(move)
+				if err != nil {
+					return p, errors.New(op.Name + ": illegal move " + move)
+				}
+				moves[i] = p.Board.GetSAN(m)
+			}
+			if op.Name == "bm" {
+				p.BestMoves = moves
+			} else {
+				p.AvoidMoves = moves
+			}
+		case "id", "c0":
+			if len(op.Operands) != 1 {
+				return p, errors.New(op.Name + " should have one operand")
+			}
+			if op.Name == "id" {
+				p.ID = op.Operands[0]
+			} else {
+				p.Comment = op.Operands[0]
+			}
+		case "dm", "acd", "ce":
+			s, err := number(op)
+			if err != nil {
+				return p, err
+			}
+			n, _ := strconv.Atoi(s)
+			switch op.Name {
+			case "dm":
+				p.Mate = n
+			case "acd":
+				p.Depth = n
+			case "ce":
+				p.Eval = &n
+			}
+		default:
+			p.Ops = append(p.Ops, op)
+		}
+	}
+	return p, nil
+}
+
+// parseOps reads opcodes, each ended by ";". Operands are separated by
+// spaces, a string operand is in double quotes
+func parseOps(s string) ([]Op, error) {
+	var ops []Op
+	var op *Op
+	for i := 0; i < len(s); {
+		switch ch := s[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == ';':
+			if op == nil {
+				return nil, errors.New("\";\" without an opcode")
+			}
+			ops = append(ops, *op)
+			op = nil
+			i++
+		case ch == '"':
+			if op == nil {
+				return nil, errors.New("a string where an opcode should be")
+			}
+			end := strings.IndexByte(s[i+1:], '"')
+			if end == -1 {
+				return nil, errors.New("unterminated string in " + op.Name)
+			}
+			op.Operands = append(op.Operands, s[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := i
+			for end < len(s) && strings.IndexByte(" \t;\"", s[end]) == -1 {
+				end++
+			}
+			if op == nil {
+				op = &Op{Name: s[i:end]}
+			} else {
+				op.Operands = append(op.Operands, s[i:end])
+			}
+			i = end
+		}
+	}
+	if op != nil {
+		return nil, errors.New("opcode " + op.Name + " is missing its \";\"")
+	}
+	return ops, nil
+}
+
+// number returns the single integer operand of op
+func number(op Op) (string, error) {
+	if len(op.Operands) != 1 {
+		return "", errors.New(op.Name + " should have one operand")
+	}
+	if _, err := strconv.Atoi(op.Operands[0]); err != nil {
+		return "", errors.New(op.Name + " should be a number: " + op.Operands[0])
+	}
+	return op.Operands[0], nil
+}
+
+// String writes the record. hmvc and fmvn are only written when they aren't
+// 0 and 1
+func (p Position) String() string {
+	fen := strings.Fields(p.Board.GetFEN())
+	var b strings.Builder
+	b.WriteString(strings.Join(fen[:4], " "))
+	write := func(name string, operands ...string) {
+		b.WriteString(" " + name)
+		for _, operand := range operands {
+			b.WriteString(" " + quote(operand))
+		}
+		b.WriteString(";")
+	}
+	if len(p.BestMoves) > 0 {
+		write("bm", p.BestMoves...)
+	}
+	if len(p.AvoidMoves) > 0 {
+		write("am", p.AvoidMoves...)
+	}
+	if p.Mate != 0 {
+		write("dm", strconv.Itoa(p.Mate))
+	}
+	if p.Depth != 0 {
+		write("acd", strconv.Itoa(p.Depth))
+	}
+	if p.Eval != nil {
+		write("ce", strconv.Itoa(*p.Eval))
+	}
+	if p.Board.HalfmoveClock != 0 {
+		write("hmvc", fen[4])
+	}
+	if p.Board.FullmoveCounter != 1 {
+		write("fmvn", fen[5])
+	}
+	if p.ID != "" {
+		b.WriteString(` id "` + p.ID + `";`)
+	}
+	if p.Comment != "" {
+		b.WriteString(` c0 "` + p.Comment + `";`)
+	}
+	for _, op := range p.Ops {
+		write(op.Name, op.Operands...)
+	}
+	return b.String()
+}
+
+// quote puts an operand in double quotes if it has to be
+func quote(operand string) string {
+	if operand == "" || strings.ContainsAny(operand, " \t;") {
+		return `"` + operand + `"`
+	}
+	return operand
+}
+
+// Solved tells if move, in SAN or UCI, is one of the best moves and none of
+// the moves to avoid
+func (p Position) Solved(move string) bool {
+	m, err := p.Board.MoveFromUCI(move)
+	if err != nil {
+		if m, err = p.Board.MoveFromSAN(move); err != nil {
+			return false
+		}
+	}
+	san := p.Board.GetSAN(m)
+	if len(p.BestMoves) > 0 && !slices.Contains(p.BestMoves, san) {
+		return false
+	}
+	return !slices.Contains(p.AvoidMoves, san)
+}
+
+// Read reads every record of a suite, one per line. Blank lines are skipped
+func Read(r io.Reader) ([]Position, error) {
+	var positions []Position
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		p, err := Parse(scanner.Text())
+		if err != nil {
+			return positions, errors.New("line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+		positions = append(positions, p)
+	}
+	return positions, scanner.Err()
+}
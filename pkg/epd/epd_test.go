@@ -0,0 +1,69 @@
+package epd
+
+import (
+	"strings"
+	"testing"
+)
+
+const suite = `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";
+r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - bm Nb5; am Nxc6; id "WAC.004"; c0 "a comment; with a semicolon";
+
+6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - bm Ra8#; dm 1; acd 12; ce 32766; hmvc 3; fmvn 40; pv Ra8; id "mate";
+`
+
+func TestEPD(t *testing.T) {
+	positions, err := Read(strings.NewReader(suite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 3 {
+		t.Fatalf("read %d positions, should read 3", len(positions))
+	}
+
+	wac1, wac4, mate := positions[0], positions[1], positions[2]
+	if wac1.ID != "WAC.001" || len(wac1.BestMoves) != 1 || wac1.BestMoves[0] != "Qg6" {
+		t.Errorf("WAC.001: %+v", wac1)
+	}
+	if !wac1.Solved("g3g6") || !wac1.Solved("Qg6") || wac1.Solved("Qh4") || wac1.Solved("Kh1") {
+		t.Errorf("WAC.001 should only be solved by Qg6")
+	}
+	if wac4.Comment != "a comment; with a semicolon" || wac4.AvoidMoves[0] != "Nxc6" || wac4.Solved("d4c6") {
+		t.Errorf("WAC.004: %+v", wac4)
+	}
+	if mate.Mate != 1 || mate.Depth != 12 || mate.Eval == nil || *mate.Eval != 32766 ||
+		mate.Board.HalfmoveClock != 3 || mate.Board.FullmoveCounter != 40 || mate.BestMoves[0] != "Ra8#" {
+		t.Errorf("mate: %+v", mate)
+	}
+	if len(mate.Ops) != 1 || mate.Ops[0].Name != "pv" || mate.Ops[0].Operands[0] != "Ra8" {
+		t.Errorf("pv should be kept in Ops: %+v", mate.Ops)
+	}
+
+	// written back in a fixed order, hmvc and fmvn only when they are set
+	lines := strings.Split(suite, "\n")
+	want := []string{
+		lines[0],
+		lines[1],
+		`6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - bm Ra8#; dm 1; acd 12; ce 32766; hmvc 3; fmvn 40; id "mate"; pv Ra8;`,
+	}
+	for i, p := range positions {
+		if p.String() != want[i] {
+			t.Errorf("String() = %s, should be %s", p.String(), want[i])
+		}
+		again, err := Parse(p.String())
+		if err != nil || again.String() != p.String() {
+			t.Errorf("parsing %s again: %s, %v", p.String(), again.String(), err)
+		}
+	}
+
+	for _, bad := range []string{
+		"8/8/8 w - -",
+		"2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6",
+		"2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qd7;",
+		`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - id "WAC.001;`,
+		"2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - acd deep;",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package explorer
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/kahnaisehC/chessboard/pkg/pgn"
+	"github.com/kahnaisehC/chessboard/pkg/polyglot"
+)
+
+// examplesPerMove is how many games are kept for every move, the best rated
+const examplesPerMove = 3
+
+// Builder collects the moves of a PGN collection, position by position, and
+// writes them as an index, see Open. Everything is kept in memory until Save
+type Builder struct {
+	// MaxPly is how many plies of each game are indexed. 0 means all of them
+	MaxPly int
+
+	stats map[statsKey]*moveStats
+	games []Game
+}
+
+type statsKey struct {
+	key  uint64 // polyglot key of the position
+	move uint16 // chessboard.Move.Encode
+}
+
+type moveStats struct {
+	games, white, draws, black int
+	ratingSum, rated           int
+	examples                   []example
+}
+
+type example struct {
+	game   int // index in Builder.games
+	rating int
+}
+
+func NewBuilder(maxPly int) *Builder {
+	return &Builder{MaxPly: maxPly, stats: map[statsKey]*moveStats{}}
+}
+
+// AddGame indexes the main line of a game. Games of variants are skipped, the
+// others are added up to the first move that can't be played
+func (b *Builder) AddGame(game *pgn.Game) {
+	if variant, ok := game.Tags.Get("Variant"); ok && variant != "Standard" && variant != "From Position" {
+		return
+	}
+	board, err := game.StartingBoard()
+	if err != nil {
+		return
+	}
+
+	g := Game{
+		ID:     len(b.games),
+		Event:  game.Tags.Event,
+		Site:   game.Tags.Site,
+		Date:   game.Tags.Date,
+		White:  game.Tags.White,
+		Black:  game.Tags.Black,
+		Result: game.Result,
+	}
+	g.FEN, _ = game.Tags.Get("FEN")
+	g.WhiteElo = elo(game, "WhiteElo")
+	g.BlackElo = elo(game, "BlackElo")
+	rating := 0
+	switch {
+	case g.WhiteElo > 0 && g.BlackElo > 0:
+		rating = (g.WhiteElo + g.BlackElo) / 2
+	case g.WhiteElo > 0:
+		rating = g.WhiteElo
+	case g.BlackElo > 0:
+		rating = g.BlackElo
+	}
+
+	for ply, san := range game.Moves {
+		m, err := board.MoveFromSAN(san)
+		if err != nil {
+			break
+		}
+		// the moves after MaxPly aren't indexed, but the example games have them
+		if b.MaxPly == 0 || ply < b.MaxPly {
+			k := statsKey{key: polyglot.Key(&board), move: m.Encode()}
+			stats := b.stats[k]
+			if stats == nil {
+				stats = &moveStats{}
+				b.stats[k] = stats
+			}
+			stats.add(g.ID, game.Result, rating)
+		}
+		board.MakeSANMove(san)
+	}
+	g.Moves = board.Moves
+	b.games = append(b.games, g)
+}
+
+func elo(game *pgn.Game, tag string) int {
+	value, _ := game.Tags.Get(tag)
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func (s *moveStats) add(game int, result string, rating int) {
+	s.games++
+	switch result {
+	case "1-0":
+		s.white++
+	case "0-1":
+		s.black++
+	case "1/2-1/2":
+		s.draws++
+	}
+	if rating > 0 {
+		s.ratingSum += rating
+		s.rated++
+	}
+
+	// the best rated games, the first ones on ties
+	i := len(s.examples)
+	for i > 0 && s.examples[i-1].rating < rating {
+		i--
+	}
+	if i < examplesPerMove {
+		s.examples = slices.Insert(s.examples, i, example{game: game, rating: rating})
+		if len(s.examples) > examplesPerMove {
+			s.examples = s.examples[:examplesPerMove]
+		}
+	}
+}
+
+// AddPGN adds every game of a PGN collection
+func (b *Builder) AddPGN(r io.Reader) error {
+	reader := pgn.NewReader(r)
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.AddGame(game)
+	}
+}
+
+// The index file, all numbers big endian:
+//
+//	magic, number of records, number of games (uint64)
+//	records sorted by key then move, recordSize bytes each
+//	the offset of every game in the file (uint64)
+//	the games, each a uvarint length and JSON
+//
+// Only the games that are examples of some move are written, numbered again
+const magic = "EXPLORE1"
+
+const headerSize = len(magic) + 16
+
+// key, move, games, white wins, draws, black wins, rating sum, rated games and the examples
+const recordSize = 8 + 2 + 4*4 + 8 + 4 + 4*examplesPerMove
+
+const noGame = 0xffffffff
+
+// Write writes the index
+func (b *Builder) Write(w io.Writer) error {
+	keys := make([]statsKey, 0, len(b.stats))
+	for k := range b.stats {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(x, y statsKey) int {
+		if c := cmp.Compare(x.key, y.key); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.move, y.move)
+	})
+
+	// number the example games in the order they come
+	renumbered := map[int]int{}
+	var games []Game
+	for _, k := range keys {
+		for _, e := range b.stats[k].examples {
+			if _, ok := renumbered[e.game]; !ok {
+				renumbered[e.game] = len(games)
+				g := b.games[e.game]
+				g.ID = len(games)
+				games = append(games, g)
+			}
+		}
+	}
+
+	writer := bufio.NewWriter(w)
+	header := append([]byte(magic), make([]byte, 16)...)
+	binary.BigEndian.PutUint64(header[8:], uint64(len(keys)))
+	binary.BigEndian.PutUint64(header[16:], uint64(len(games)))
+	writer.Write(header)
+
+	var buf [recordSize]byte
+	for _, k := range keys {
+		s := b.stats[k]
+		binary.BigEndian.PutUint64(buf[0:], k.key)
+		binary.BigEndian.PutUint16(buf[8:], k.move)
+		binary.BigEndian.PutUint32(buf[10:], uint32(s.games))
+		binary.BigEndian.PutUint32(buf[14:], uint32(s.white))
+		binary.BigEndian.PutUint32(buf[18:], uint32(s.draws))
+		binary.BigEndian.PutUint32(buf[22:], uint32(s.black))
+		binary.BigEndian.PutUint64(buf[26:], uint64(s.ratingSum))
+		binary.BigEndian.PutUint32(buf[34:], uint32(s.rated))
+		for i := 0; i < examplesPerMove; i++ {
+			id := uint32(noGame)
+			if i < len(s.examples) {
+				id = uint32(renumbered[s.examples[i].game])
+			}
+			binary.BigEndian.PutUint32(buf[38+4*i:], id)
+		}
+		writer.Write(buf[:])
+	}
+
+	encoded := make([][]byte, len(games))
+	offset := uint64(headerSize + len(keys)*recordSize + 8*len(games))
+	for i, g := range games {
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		encoded[i] = binary.AppendUvarint(nil, uint64(len(data)))
+		encoded[i] = append(encoded[i], data...)
+		writer.Write(binary.BigEndian.AppendUint64(nil, offset))
+		offset += uint64(len(encoded[i]))
+	}
+	for _, data := range encoded {
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func (b *Builder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,92 @@
+package explorer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+const club = `[White "Ann"] [Black "Bob"] [WhiteElo "1800"] [BlackElo "1600"] [Result "1-0"]
+1. e4 e5 2. Nf3 Nc6 3. Bb5 1-0
+
+[White "Cid"] [Black "Ann"] [WhiteElo "2000"] [BlackElo "1800"] [Result "0-1"]
+1. e4 c5 2. Nf3 d6 0-1
+
+[White "Bob"] [Black "Cid"] [Result "1/2-1/2"]
+1. e4 e5 2. Nf3 Nf6 1/2-1/2
+
+[White "Dan"] [Black "Eve"] [WhiteElo "2400"] [BlackElo "2200"] [Result "*"]
+1. Nf3 e5 2. e4 *
+
+[Variant "Atomic"] [Result "1-0"]
+1. e4 e5 1-0
+`
+
+func TestExplorer(t *testing.T) {
+	builder := NewBuilder(3)
+	if err := builder.AddPGN(strings.NewReader(club)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := builder.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	board := chessboard.CreateChessboard("")
+	moves, err := index.Moves(&board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 || moves[0].SAN != "e4" || moves[0].Games != 3 || moves[1].UCI != "g1f3" {
+		t.Fatalf("moves from the initial position: %+v", moves)
+	}
+	e4 := moves[0]
+	if e4.WhiteWins != 1 || e4.Draws != 1 || e4.BlackWins != 1 || e4.Score() != 0.5 || e4.AverageRating != (1700+1900)/2 {
+		t.Errorf("1. e4: %+v", e4)
+	}
+	// the best rated first, then the order they were added
+	first, _ := index.Game(e4.Examples[0])
+	second, _ := index.Game(e4.Examples[1])
+	if len(e4.Examples) != 3 || first.White != "Cid" || second.White != "Ann" {
+		t.Errorf("examples of 1. e4: %+v %+v", first, second)
+	}
+	if strings.Join(first.Moves, " ") != "e2e4 c7c5 g1f3 d7d6" || first.WhiteElo != 2000 || first.Result != "0-1" {
+		t.Errorf("example game: %+v", first)
+	}
+
+	// 1. Nf3 e5 2. e4 gets to 1. e4 e5 2. Nf3, the third ply is indexed but not the fourth
+	board.MakeSANMove("e4")
+	board.MakeSANMove("e5")
+	board.MakeSANMove("Nf3")
+	moves, _ = index.Moves(&board)
+	if len(moves) != 0 {
+		t.Errorf("the fourth ply shouldn't be indexed: %+v", moves)
+	}
+	board = chessboard.CreateChessboard("")
+	board.MakeSANMove("e4")
+	board.MakeSANMove("e5")
+	moves, _ = index.Moves(&board)
+	if len(moves) != 1 || moves[0].SAN != "Nf3" || moves[0].Games != 2 {
+		t.Errorf("after 1. e4 e5: %+v", moves)
+	}
+
+	// the atomic game is left out
+	board = chessboard.CreateChessboard("")
+	moves, _ = index.Moves(&board)
+	if moves[0].Games+moves[1].Games != 4 {
+		t.Errorf("%d games indexed, should be 4", moves[0].Games+moves[1].Games)
+	}
+
+	if _, err := index.Game(100); err == nil {
+		t.Errorf("a game that isn't there should fail")
+	}
+	if _, err := NewIndex(bytes.NewReader([]byte("not an index at all"))); err == nil {
+		t.Errorf("reading something else should fail")
+	}
+}
@@ -0,0 +1,186 @@
+package explorer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/polyglot"
+)
+
+// Game is an example game of the index
+type Game struct {
+	ID       int      `json:"id"`
+	Event    string   `json:"event,omitempty"`
+	Site     string   `json:"site,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	White    string   `json:"white,omitempty"`
+	Black    string   `json:"black,omitempty"`
+	WhiteElo int      `json:"whiteElo,omitempty"`
+	BlackElo int      `json:"blackElo,omitempty"`
+	Result   string   `json:"result"`
+	FEN      string   `json:"fen,omitempty"` // if the game didn't start from the initial position
+	Moves    []string `json:"moves"`         // UCI, up to the first move that couldn't be played
+}
+
+// MoveStats is what the index knows of a move played in a position
+type MoveStats struct {
+	UCI       string
+	SAN       string
+	Games     int
+	WhiteWins int
+	Draws     int
+	BlackWins int
+	// AverageRating is the average of the games with ratings, 0 if none has
+	AverageRating int
+	// Examples are the ids of the best rated games, see Index.Game
+	Examples []int
+}
+
+// Score returns the points white got in the finished games, 0.5 if none
+func (m MoveStats) Score() float64 {
+	finished := m.WhiteWins + m.Draws + m.BlackWins
+	if finished == 0 {
+		return 0.5
+	}
+	return (float64(m.WhiteWins) + float64(m.Draws)/2) / float64(finished)
+}
+
+// Index is an index written by Builder. It is read from disk as it is
+// queried, only its header is loaded
+type Index struct {
+	r       io.ReaderAt
+	closer  io.Closer
+	records int64
+	games   int64
+}
+
+// Open opens an index file
+func Open(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	index, err := NewIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	index.closer = f
+	return index, nil
+}
+
+// NewIndex reads an index from r
+func NewIndex(r io.ReaderAt) (*Index, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil || string(header[:len(magic)]) != magic {
+		return nil, errors.New("not an explorer index")
+	}
+	return &Index{
+		r:       r,
+		records: int64(binary.BigEndian.Uint64(header[8:])),
+		games:   int64(binary.BigEndian.Uint64(header[16:])),
+	}, nil
+}
+
+func (ix *Index) Close() error {
+	if ix.closer == nil {
+		return nil
+	}
+	return ix.closer.Close()
+}
+
+func (ix *Index) record(i int64, buf []byte) error {
+	_, err := ix.r.ReadAt(buf, int64(headerSize)+i*recordSize)
+	if err == io.EOF {
+		return errors.New("truncated explorer index")
+	}
+	return err
+}
+
+// Moves returns the moves played in the position on c, the most played first.
+// The position is found whatever the moves that led to it
+func (ix *Index) Moves(c *chessboard.Chessboard) ([]MoveStats, error) {
+	key := polyglot.Key(c)
+	buf := make([]byte, recordSize)
+	var err error
+	first := sort.Search(int(ix.records), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		err = ix.record(int64(i), buf)
+		return binary.BigEndian.Uint64(buf) >= key
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var moves []MoveStats
+	for i := int64(first); i < ix.records; i++ {
+		if err := ix.record(i, buf); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint64(buf) != key {
+			break
+		}
+		m, err := c.DecodeMove(binary.BigEndian.Uint16(buf[8:]))
+		if err != nil {
+			// another position with the same key
+			continue
+		}
+		stats := MoveStats{
+			UCI:       c.GetUCI(m),
+			SAN:       c.GetSAN(m),
+			Games:     int(binary.BigEndian.Uint32(buf[10:])),
+			WhiteWins: int(binary.BigEndian.Uint32(buf[14:])),
+			Draws:     int(binary.BigEndian.Uint32(buf[18:])),
+			BlackWins: int(binary.BigEndian.Uint32(buf[22:])),
+		}
+		if rated := binary.BigEndian.Uint32(buf[34:]); rated > 0 {
+			stats.AverageRating = int(binary.BigEndian.Uint64(buf[26:]) / uint64(rated))
+		}
+		for j := 0; j < examplesPerMove; j++ {
+			if id := binary.BigEndian.Uint32(buf[38+4*j:]); id != noGame {
+				stats.Examples = append(stats.Examples, int(id))
+			}
+		}
+		moves = append(moves, stats)
+	}
+	slices.SortStableFunc(moves, func(a, b MoveStats) int { return b.Games - a.Games })
+	return moves, nil
+}
+
+// Game returns an example game by its id
+func (ix *Index) Game(id int) (Game, error) {
+	if id < 0 || int64(id) >= ix.games {
+		return Game{}, errors.New("no game " + strconv.Itoa(id) + " in the index")
+	}
+	buf := make([]byte, 8)
+	if _, err := ix.r.ReadAt(buf, int64(headerSize)+ix.records*recordSize+int64(id)*8); err != nil {
+		return Game{}, errors.New("truncated explorer index")
+	}
+	offset := int64(binary.BigEndian.Uint64(buf))
+
+	// a uvarint takes 10 bytes at most
+	buf = make([]byte, binary.MaxVarintLen64)
+	n, _ := ix.r.ReadAt(buf, offset)
+	length, size := binary.Uvarint(buf[:n])
+	if size <= 0 {
+		return Game{}, errors.New("truncated explorer index")
+	}
+	data := make([]byte, length)
+	if _, err := ix.r.ReadAt(data, offset+int64(size)); err != nil {
+		return Game{}, errors.New("truncated explorer index")
+	}
+	var g Game
+	if err := json.Unmarshal(data, &g); err != nil {
+		return Game{}, err
+	}
+	return g, nil
+}
@@ -0,0 +1,205 @@
+package pgn
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Eval is an engine evaluation for white: pawns, or moves to mate
+type Eval struct {
+	Centipawns int
+	Mate       int // moves to mate, negative if black mates, 0 if no mate is seen
+	Depth      int // 0 if not given
+}
+
+// Arrow is an arrow drawn on the board, from the %cal command
+type Arrow struct {
+	From  string
+	To    string
+	Color byte // 'R', 'G', 'Y' or 'B'
+}
+
+// Highlight is a colored square, from the %csl command
+type Highlight struct {
+	Square string
+	Color  byte
+}
+
+// commandPattern matches embedded commands, "[%clk 0:03:12]"
+var commandPattern = regexp.MustCompile(`\[%(\w+)\s+([^\]]*)\]`)
+
+// setComment takes the commands out of a comment after the move, the rest
+// of the text is added to Comment. Commands that can't be read stay in the text
+func (n *Node) setComment(text string) {
+	rest := commandPattern.ReplaceAllStringFunc(text, func(command string) string {
+		match := commandPattern.FindStringSubmatch(command)
+		if n.setCommand(match[1], strings.TrimSpace(match[2])) {
+			return ""
+		}
+		return command
+	})
+	if rest = strings.Join(strings.Fields(rest), " "); rest != "" {
+		n.Comment = joinComments(n.Comment, rest)
+	}
+}
+
+func (n *Node) setCommand(name, args string) bool {
+	switch name {
+	case "clk", "emt":
+		d, ok := parseClock(args)
+		if !ok {
+			return false
+		}
+		if name == "clk" {
+			n.Clock = &d
+		} else {
+			n.Elapsed = &d
+		}
+	case "eval":
+		eval, ok := parseEval(args)
+		if !ok {
+			return false
+		}
+		n.Eval = &eval
+	case "cal":
+		// nothing is kept unless the whole list can be read
+		var arrows []Arrow
+		for _, arrow := range strings.Split(args, ",") {
+			if len(arrow) != 5 || !isColor(arrow[0]) || !isSquare(arrow[1:3]) || !isSquare(arrow[3:5]) {
+				return false
+			}
+			arrows = append(arrows, Arrow{From: arrow[1:3], To: arrow[3:5], Color: arrow[0]})
+		}
+		n.Arrows = append(n.Arrows, arrows...)
+	case "csl":
+		var highlights []Highlight
+		for _, square := range strings.Split(args, ",") {
+			if len(square) != 3 || !isColor(square[0]) || !isSquare(square[1:]) {
+				return false
+			}
+			highlights = append(highlights, Highlight{Square: square[1:], Color: square[0]})
+		}
+		n.Highlights = append(n.Highlights, highlights...)
+	default:
+		return false
+	}
+	return true
+}
+
+func isColor(ch byte) bool {
+	return ch == 'R' || ch == 'G' || ch == 'Y' || ch == 'B'
+}
+
+func isSquare(s string) bool {
+	return len(s) == 2 && s[0] >= 'a' && s[0] <= 'h' && s[1] >= '1' && s[1] <= '8'
+}
+
+// parseClock reads "h:mm:ss", seconds may have a fraction
+func parseClock(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hours < 0 || minutes < 0 || minutes > 59 || seconds < 0 || seconds >= 60 {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), true
+}
+
+// formatClock writes "h:mm:ss", with the fraction of a second down to the
+// millisecond when there is one. It truncates, a clock never shows more time
+// than is left
+func formatClock(d time.Duration) string {
+	d = d.Truncate(time.Millisecond)
+	s := strconv.Itoa(int(d/time.Hour)) + ":" + twoDigits(int(d/time.Minute%60)) + ":" + twoDigits(int(d/time.Second%60))
+	if ms := int(d / time.Millisecond % 1000); ms != 0 {
+		s += "." + strings.TrimRight(strconv.Itoa(1000 + ms)[1:], "0")
+	}
+	return s
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// parseEval reads "+0.34", "-1.2", "#-3" or "#5", optionally followed by ",depth"
+func parseEval(s string) (Eval, bool) {
+	var eval Eval
+	value, depth, found := strings.Cut(s, ",")
+	if found {
+		d, err := strconv.Atoi(depth)
+		if err != nil || d < 0 {
+			return Eval{}, false
+		}
+		eval.Depth = d
+	}
+	if mate, ok := strings.CutPrefix(value, "#"); ok {
+		n, err := strconv.Atoi(mate)
+		if err != nil || n == 0 {
+			return Eval{}, false
+		}
+		eval.Mate = n
+		return eval, true
+	}
+	pawns, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return Eval{}, false
+	}
+	if pawns < 0 {
+		eval.Centipawns = int(pawns*100 - 0.5)
+	} else {
+		eval.Centipawns = int(pawns*100 + 0.5)
+	}
+	return eval, true
+}
+
+func (e Eval) String() string {
+	s := ""
+	if e.Mate != 0 {
+		s = "#" + strconv.Itoa(e.Mate)
+	} else {
+		s = strconv.FormatFloat(float64(e.Centipawns)/100, 'f', 2, 64)
+	}
+	if e.Depth > 0 {
+		s += "," + strconv.Itoa(e.Depth)
+	}
+	return s
+}
+
+// commands writes the commands of n, to go before its comment
+func (n *Node) commands() string {
+	var commands []string
+	if n.Eval != nil {
+		commands = append(commands, "[%eval "+n.Eval.String()+"]")
+	}
+	if n.Clock != nil {
+		commands = append(commands, "[%clk "+formatClock(*n.Clock)+"]")
+	}
+	if n.Elapsed != nil {
+		commands = append(commands, "[%emt "+formatClock(*n.Elapsed)+"]")
+	}
+	if len(n.Highlights) > 0 {
+		squares := make([]string, len(n.Highlights))
+		for i, h := range n.Highlights {
+			squares[i] = string(h.Color) + h.Square
+		}
+		commands = append(commands, "[%csl "+strings.Join(squares, ",")+"]")
+	}
+	if len(n.Arrows) > 0 {
+		arrows := make([]string, len(n.Arrows))
+		for i, a := range n.Arrows {
+			arrows[i] = string(a.Color) + a.From + a.To
+		}
+		commands = append(commands, "[%cal "+strings.Join(arrows, ",")+"]")
+	}
+	return strings.Join(commands, " ")
+}
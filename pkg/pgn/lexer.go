@@ -0,0 +1,141 @@
+package pgn
+
+import "strings"
+
+type tokenKind int
+
+const (
+	moveToken tokenKind = iota
+	nagToken
+	commentToken
+	openToken  // "(", a variation starts
+	closeToken // ")"
+	resultToken
+)
+
+type token struct {
+	kind tokenKind
+	text string // SAN without glyphs, NAG number, comment text or result
+}
+
+// glyphs are the move suffixes that stand for a NAG
+var glyphs = map[string]string{"!": "1", "?": "2", "!!": "3", "??": "4", "!?": "5", "?!": "6"}
+
+// lexer splits movetext into tokens a line at a time. Comments can span lines
+type lexer struct {
+	tokens    []token
+	inComment bool
+	comment   strings.Builder
+	depth     int  // of variations
+	ended     bool // a result token was read outside of variations
+}
+
+// line reads a line of movetext, without its line break
+func (l *lexer) line(s string) {
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case l.inComment:
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				l.comment.WriteString(s[i:])
+				l.comment.WriteByte(' ')
+				return
+			}
+			l.comment.WriteString(s[i : i+end])
+			l.emit(commentToken, strings.Join(strings.Fields(l.comment.String()), " "))
+			l.comment.Reset()
+			l.inComment = false
+			i += end + 1
+		case ch == '{':
+			l.inComment = true
+			i++
+		case ch == ';':
+			l.emit(commentToken, strings.TrimSpace(s[i+1:]))
+			return
+		case ch == '(':
+			l.depth++
+			l.emit(openToken, "(")
+			i++
+		case ch == ')':
+			// a stray ")" is dropped
+			if l.depth > 0 {
+				l.depth--
+				l.emit(closeToken, ")")
+			}
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		default:
+			end := i
+			for end < len(s) && strings.IndexByte(" \t\r{};()", s[end]) == -1 {
+				end++
+			}
+			l.word(s[i:end])
+			i = end
+		}
+	}
+}
+
+// word reads a token that is not a comment or a parenthesis
+func (l *lexer) word(w string) {
+	switch w {
+	case "1-0", "0-1", "1/2-1/2", "*":
+		l.emit(resultToken, w)
+		if l.depth == 0 {
+			l.ended = true
+		}
+		return
+	case "½-½":
+		l.emit(resultToken, "1/2-1/2")
+		if l.depth == 0 {
+			l.ended = true
+		}
+		return
+	}
+	if w[0] == '$' {
+		l.emit(nagToken, w[1:])
+		return
+	}
+	if nag, ok := glyphs[w]; ok {
+		l.emit(nagToken, nag)
+		return
+	}
+
+	// move numbers, "12." "12..." or stuck to the move, "12.e4"
+	i := 0
+	for i < len(w) && w[i] >= '0' && w[i] <= '9' {
+		i++
+	}
+	if i < len(w) && w[i] == '.' {
+		for i < len(w) && w[i] == '.' {
+			i++
+		}
+		w = w[i:]
+	} else if i == len(w) {
+		// a move number without its dot
+		return
+	}
+	if w == "" {
+		return
+	}
+
+	move := strings.TrimRight(w, "!?")
+	glyph := w[len(move):]
+	switch move {
+	case "0-0", "0-0+", "0-0#":
+		move = "O-O" + move[3:]
+	case "0-0-0", "0-0-0+", "0-0-0#":
+		move = "O-O-O" + move[5:]
+	}
+	if move != "" {
+		l.emit(moveToken, move)
+	}
+	if nag, ok := glyphs[glyph]; ok {
+		l.emit(nagToken, nag)
+	}
+}
+
+func (l *lexer) emit(kind tokenKind, text string) {
+	l.tokens = append(l.tokens, token{kind: kind, text: text})
+}
@@ -0,0 +1,202 @@
+package pgn
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+// Game is a game read from PGN. Its board is only built when asked for,
+// see Board and Replay
+type Game struct {
+	Tags pgntags.PGNTags
+	// Moves is the main line in SAN, without glyphs, "0-0" written "O-O"
+	Moves []string
+	// Result is the result token, the Result tag if the token is missing, or "*"
+	Result string
+
+	tokens []token
+}
+
+// Reader reads the games of a PGN collection one at a time, keeping only
+// the game being read in memory. It puts up with what PGN files out there
+// have: a byte order mark, "%" escape lines, missing results, "0-0"
+// castling, tags that can't be parsed (they are skipped)
+type Reader struct {
+	r    *bufio.Reader
+	line int // lines read
+
+	pending    string // the first line of the next game
+	hasPending bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Line returns how many lines were read, for error messages
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// readLine returns the next line without its line break, io.EOF after the last one
+func (r *Reader) readLine() (string, error) {
+	if r.hasPending {
+		r.hasPending = false
+		return r.pending, nil
+	}
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	r.line++
+	if r.line == 1 {
+		line = strings.TrimPrefix(line, "\ufeff")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Next reads the next game. It returns io.EOF when there are no more games
+func (r *Reader) Next() (*Game, error) {
+	game := &Game{}
+	var lex lexer
+	started, inMoves := false, false
+
+	for !lex.ended {
+		line, err := r.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !lex.inComment && strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if !lex.inComment && lex.depth == 0 && strings.HasPrefix(trimmed, "[") {
+			if inMoves {
+				// the result was missing, this is the next game
+				r.pending, r.hasPending = line, true
+				break
+			}
+			started = true
+			if tags, err := pgntags.Parse(trimmed); err == nil {
+				for _, name := range pgntags.SevenTagRoster {
+					if value, ok := tags.Get(name); ok {
+						game.Tags.Set(name, value)
+					}
+				}
+				for _, tag := range tags.Extra {
+					game.Tags.Set(tag.Name, tag.Value)
+				}
+			}
+			continue
+		}
+		if trimmed == "" && !lex.inComment {
+			continue
+		}
+		started, inMoves = true, true
+		lex.line(line)
+	}
+	if !started {
+		return nil, io.EOF
+	}
+
+	game.tokens = lex.tokens
+	depth := 0
+	for _, t := range lex.tokens {
+		switch {
+		case t.kind == openToken:
+			depth++
+		case t.kind == closeToken:
+			depth--
+		case depth > 0:
+		case t.kind == moveToken:
+			game.Moves = append(game.Moves, t.text)
+		case t.kind == resultToken:
+			game.Result = t.text
+		}
+	}
+	switch {
+	case game.Result == "" && pgntags.ValidResult(game.Tags.Result):
+		game.Result = game.Tags.Result
+	case game.Result == "":
+		game.Result = "*"
+	case game.Tags.Result == "":
+		game.Tags.Result = game.Result
+	}
+	return game, nil
+}
+
+// StartingBoard sets up the board of the game before its first move, from
+// its Variant and FEN tags
+func (g *Game) StartingBoard() (chessboard.Chessboard, error) {
+	return startingBoard(&g.Tags)
+}
+
+func startingBoard(tags *pgntags.PGNTags) (chessboard.Chessboard, error) {
+	fen, _ := tags.Get("FEN")
+	variant, _ := tags.Get("Variant")
+	switch strings.ToLower(variant) {
+	case "", "standard", "from position":
+		if fen == "" {
+			return chessboard.CreateChessboard(""), nil
+		}
+		if ok, logs := chessboard.ValidateFEN(fen); !ok {
+			return chessboard.Chessboard{}, errors.New("invalid FEN tag: " + logs)
+		}
+		return chessboard.CreateChessboard(fen), nil
+	case "chess960", "fischerandom":
+		if fen == "" {
+			return chessboard.CreateChess960(518)
+		}
+		if ok, logs := chessboard.ValidateFEN(fen); !ok {
+			return chessboard.Chessboard{}, errors.New("invalid FEN tag: " + logs)
+		}
+		board := chessboard.CreateChessboard(fen)
+		board.Chess960 = true
+		return board, nil
+	}
+	v, ok := chessboard.VariantByName(variant)
+	if !ok {
+		return chessboard.Chessboard{}, errors.New("unknown variant: " + variant)
+	}
+	return chessboard.CreateVariantChessboard(v, fen)
+}
+
+// Board plays the main line. If a move can't be played, the board before
+// it is returned with the error
+func (g *Game) Board() (chessboard.Chessboard, error) {
+	return g.Replay(nil)
+}
+
+// Replay plays the main line, calling visit with the board before the first
+// move, ply 0, and after every move. Replay stops when visit returns false
+func (g *Game) Replay(visit func(ply int, board *chessboard.Chessboard) bool) (chessboard.Chessboard, error) {
+	board, err := g.StartingBoard()
+	if err != nil {
+		return board, err
+	}
+	if visit != nil && !visit(0, &board) {
+		return board, nil
+	}
+	for i, san := range g.Moves {
+		if err := board.MakeSANMove(san); err != nil {
+			return board, errors.New("ply " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		if visit != nil && !visit(i+1, &board) {
+			break
+		}
+	}
+	return board, nil
+}
@@ -0,0 +1,253 @@
+package pgn
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// quirks found in real databases: a BOM, escape lines, games without a
+// result, castling with zeros, comments across lines
+const collection = "\ufeff" + `% exported by some tool
+[Event "first"]
+[White "Ann"] [Black "Bob"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 {a comment
+over two lines [Event "not a tag"]} Nc6 3. Bb5 (3. Bc4 Bc5 (3... Nf6) 4. 0-0) a6 4.Ba4 Nf6 5. 0-0 $1 Be7!? ; a line comment (
+6. Re1 1-0
+
+[Event "second"]
+[Result "0-1"]
+% an escape line in the middle
+1. f3 e5 2. g4?? Qh4#
+[Event "third, no result anywhere"]
+
+1.d4 d5 2.c4
+[Event "from a position"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"]
+[Result "1/2-1/2"]
+
+1. e4 Kd7 *
+`
+
+func TestReader(t *testing.T) {
+	reader := NewReader(strings.NewReader(collection))
+	var games []*Game
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		games = append(games, game)
+	}
+	if len(games) != 4 {
+		t.Fatalf("read %d games, should read 4", len(games))
+	}
+
+	tests := []struct {
+		event  string
+		moves  string
+		result string
+		FEN    string
+	}{
+		{"first", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7 Re1", "1-0", "r1bqk2r/1pppbppp/p1n2n2/4p3/B3P3/5N2/PPPP1PPP/RNBQR1K1 b kq - 5 6"},
+		{"second", "f3 e5 g4 Qh4#", "0-1", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"},
+		{"third, no result anywhere", "d4 d5 c4", "*", "rnbqkbnr/ppp1pppp/8/3p4/2PP4/8/PP2PPPP/RNBQKBNR b KQkq c3 0 2"},
+		{"from a position", "e4 Kd7", "*", "8/3k4/8/8/4P3/8/8/4K3 w - - 1 2"},
+	}
+	for i, test := range tests {
+		game := games[i]
+		if game.Tags.Event != test.event || strings.Join(game.Moves, " ") != test.moves || game.Result != test.result {
+			t.Errorf("game %d: %q %v %s, should be %q %s %s", i, game.Tags.Event, game.Moves, game.Result, test.event, test.moves, test.result)
+			continue
+		}
+		board, err := game.Board()
+		if err != nil {
+			t.Errorf("game %d: %v", i, err)
+		} else if board.GetFEN() != test.FEN {
+			t.Errorf("game %d: FEN %s, should be %s", i, board.GetFEN(), test.FEN)
+		}
+	}
+	if games[0].Tags.White != "Ann" || games[0].Tags.Black != "Bob" {
+		t.Errorf("tags sharing a line: %+v", games[0].Tags)
+	}
+	// a Result tag that disagrees with the token is kept as it is
+	if games[3].Tags.Result != "1/2-1/2" {
+		t.Errorf("Result tag = %s", games[3].Tags.Result)
+	}
+
+	// Replay stops when asked to
+	plies := 0
+	board, err := games[1].Replay(func(ply int, board *chessboard.Chessboard) bool {
+		plies++
+		return ply < 2
+	})
+	if err != nil || plies != 3 || len(board.Moves) != 2 {
+		t.Errorf("Replay visited %d boards and stopped after %v, %v", plies, board.Moves, err)
+	}
+
+	// a move that can't be played
+	game := &Game{Moves: []string{"e4", "e5", "Ke3"}}
+	if board, err := game.Board(); err == nil || len(board.Moves) != 2 {
+		t.Errorf("Board() of an illegal game = %v, %v", board.Moves, err)
+	}
+}
+
+func TestTree(t *testing.T) {
+	annotated := `[Event "lesson"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "1-0"]
+
+{The Ruy Lopez} 1. e4 e5 2. Nf3 Nc6 3. Bb5 $1 {the main move} (3. Bc4 Bc5 (3...
+Nf6 {two knights}) 4. c3) (3. d4) 3... a6 4. Ba4 $5 $14 Nf6 5. O-O 1-0
+`
+	game, err := NewReader(strings.NewReader(annotated)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := game.Tree()
+	// the opening tags are added on the way out
+	want := strings.Replace(annotated, "[Result \"1-0\"]\n", "[Result \"1-0\"]\n[ECO \"C78\"]\n[Opening \"Ruy Lopez\"]\n[Variation \"Morphy Defense\"]\n", 1)
+	if got := tree.String(); got != want {
+		t.Errorf("round trip:\n%s\nshould be\n%s", got, want)
+	}
+
+	// navigate to 3. Bc4 Nf6
+	bb5 := tree.Root.Next().Next().Next().Next().Next()
+	bc4 := bb5.Prev().Variation(1)
+	nf6 := bc4.Next().Prev().Variation(1)
+	if bb5.Move != "Bb5" || bb5.Comment != "the main move" || len(bb5.NAGs) != 1 || bc4.Move != "Bc4" ||
+		nf6.Move != "Nf6" || nf6.Comment != "two knights" || tree.Root.Next().CommentBefore != "The Ruy Lopez" {
+		t.Fatalf("navigation: %+v %+v %+v", bb5, bc4, nf6)
+	}
+	board, err := tree.Board(nf6)
+	if err != nil || board.GetFEN() != "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4" {
+		t.Errorf("board after 3. Bc4 Nf6 = %s, %v", board.GetFEN(), err)
+	}
+
+	// 4. Ng5 after 3... Nf6, the Two Knights becomes the main line, 3. d4 goes
+	if _, err := tree.AddMove(nf6, "f3g5"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.AddMove(nf6, "Ke3"); err == nil {
+		t.Errorf("adding an illegal move should fail")
+	}
+	if again, _ := tree.AddMove(bb5.Prev(), "Bc4"); again != bc4 {
+		t.Errorf("adding a move that is there should return its node")
+	}
+	nf6.MakeMainLine()
+	bb5.Prev().Variation(2).Delete()
+	if err := tree.Root.Next().Promote(); err == nil {
+		t.Errorf("promoting the main line should fail")
+	}
+	tree.Result = "*"
+	want = `[Event "lesson"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[ECO "C57"]
+[Opening "Italian Game"]
+[Variation "Two Knights Defense, Knight Attack"]
+
+{The Ruy Lopez} 1. e4 e5 2. Nf3 Nc6 3. Bc4 (3. Bb5 $1 {the main move} 3... a6
+4. Ba4 $5 $14 Nf6 5. O-O) 3... Nf6 {two knights} (3... Bc5 4. c3) 4. Ng5 *
+`
+	if got := tree.String(); got != want {
+		t.Errorf("after the changes:\n%s\nshould be\n%s", got, want)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	annotated := `[Event "blitz"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[ECO "C20"]
+[Opening "King's Pawn Game"]
+[Variation "Wayward Queen Attack"]
+
+1. e4 {[%eval 0.34,20] [%clk 0:03:00] [%emt 0:00:01.5] best by test} 1... e5
+{[%eval -1.20] [%clk 1:02:03]} 2. Qh5 {[%eval #-3] [%csl Rd5,Ge4] [%cal
+Ge2e4,Rd1d8]} 2... Nc6 {[%foo bar] [%clk broken]} *
+`
+	game, err := NewReader(strings.NewReader(annotated)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := game.Tree()
+	line := tree.MainLine()
+	e4, e5, qh5, nc6 := line[0], line[1], line[2], line[3]
+
+	if e4.Eval == nil || *e4.Eval != (Eval{Centipawns: 34, Depth: 20}) || e4.Clock == nil || *e4.Clock != 3*time.Minute ||
+		e4.Elapsed == nil || *e4.Elapsed != 1500*time.Millisecond || e4.Comment != "best by test" {
+		t.Errorf("1. e4: %+v", e4)
+	}
+	if e5.Eval == nil || e5.Eval.Centipawns != -120 || *e5.Clock != time.Hour+2*time.Minute+3*time.Second || e5.Comment != "" {
+		t.Errorf("1... e5: %+v", e5)
+	}
+	if qh5.Eval == nil || qh5.Eval.Mate != -3 || len(qh5.Highlights) != 2 || qh5.Highlights[0] != (Highlight{"d5", 'R'}) ||
+		len(qh5.Arrows) != 2 || qh5.Arrows[1] != (Arrow{"d1", "d8", 'R'}) {
+		t.Errorf("2. Qh5: %+v", qh5)
+	}
+	// commands that can't be read are left in the comment
+	if nc6.Clock != nil || nc6.Comment != "[%foo bar] [%clk broken]" {
+		t.Errorf("2... Nc6: %+v", nc6)
+	}
+
+	if got := tree.String(); got != annotated {
+		t.Errorf("round trip:\n%s\nshould be\n%s", got, annotated)
+	}
+
+	// set by hand, written as precisely as it was set and never rounded up
+	for _, test := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{59*time.Second + 250*time.Millisecond, "0:00:59.25"},
+		{59*time.Second + 950*time.Millisecond, "0:00:59.95"},
+		{59*time.Second + 999*time.Millisecond + 999*time.Microsecond, "0:00:59.999"},
+	} {
+		d := test.d
+		nc6.Clock = &d
+		nc6.Comment = ""
+		if got := tree.String(); !strings.Contains(got, "2... Nc6 {[%clk "+test.want+"]} *") {
+			t.Errorf("a clock set by hand to %v:\n%s", test.d, got)
+		}
+	}
+
+	// a list with one bad element is kept whole in the comment, and nothing is added twice
+	pgn := "1. e4 {[%cal Ge2e4,Xd1d8] [%csl Rd5,Ge9]} *"
+	for i := 0; i < 2; i++ {
+		game, err := NewReader(strings.NewReader(pgn)).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := game.Tree()
+		if e4 := tree.MainLine()[0]; len(e4.Arrows) != 0 || len(e4.Highlights) != 0 {
+			t.Errorf("commands with a bad element: %+v", e4)
+		}
+		got := tree.String()
+		if !strings.HasSuffix(got, "\n1. e4 {[%cal Ge2e4,Xd1d8] [%csl Rd5,Ge9]} *\n") {
+			t.Errorf("round trip %d of bad commands:\n%s", i+1, got)
+		}
+		pgn = got
+	}
+}
@@ -0,0 +1,317 @@
+package pgn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/eco"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+// Tree is a game with its variations. Root stands for the starting position,
+// every other node for a move
+type Tree struct {
+	Tags   pgntags.PGNTags
+	Root   *Node
+	Result string
+}
+
+// Node is a move and what was written about it. Children are the moves that
+// follow, the first one continues the line and the others are variations
+type Node struct {
+	Move          string // SAN, "" for the root
+	CommentBefore string // written before the move, at the start of a line
+	Comment       string // written after the move
+	NAGs          []int  // numeric annotation glyphs, 1 for "!", 2 for "?"...
+	Parent        *Node
+	Children      []*Node
+
+	// embedded commands of the comment after the move, nil or empty when missing
+	Clock      *time.Duration // [%clk 0:03:12], the time left after the move
+	Elapsed    *time.Duration // [%emt 0:00:05], the time the move took
+	Eval       *Eval          // [%eval +0.34] or [%eval #-3]
+	Arrows     []Arrow        // [%cal Ge2e4,Rd1d8]
+	Highlights []Highlight    // [%csl Rd5]
+}
+
+// NewTree returns a game without moves. A game that doesn't start from the
+// initial position has its FEN tag set
+func NewTree(tags pgntags.PGNTags) *Tree {
+	return &Tree{Tags: tags, Root: &Node{}, Result: "*"}
+}
+
+// Tree returns the game with its variations, comments and NAGs. Moves are
+// not checked until a board is asked for, see Tree.Board
+func (g *Game) Tree() *Tree {
+	tree := NewTree(g.Tags)
+	tree.Result = g.Result
+
+	current := tree.Root
+	var stack []*Node
+	lineStart := true // comments at the start of a line go before its first move
+	comment := ""
+
+	for _, t := range g.tokens {
+		switch t.kind {
+		case moveToken:
+			node := &Node{Move: t.text, Parent: current}
+			if lineStart {
+				node.CommentBefore, comment = comment, ""
+			}
+			current.Children = append(current.Children, node)
+			current, lineStart = node, false
+		case nagToken:
+			if nag, err := strconv.Atoi(t.text); err == nil && !lineStart {
+				current.NAGs = append(current.NAGs, nag)
+			}
+		case commentToken:
+			if lineStart {
+				comment = joinComments(comment, t.text)
+			} else {
+				current.setComment(t.text)
+			}
+		case openToken:
+			// a variation replaces the last move
+			stack = append(stack, current)
+			if current.Parent != nil {
+				current = current.Parent
+			}
+			lineStart, comment = true, ""
+		case closeToken:
+			if len(stack) > 0 {
+				current, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+			lineStart = false
+		}
+	}
+	if comment != "" {
+		// a comment and no moves
+		tree.Root.Comment = comment
+	}
+	return tree
+}
+
+func joinComments(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " " + b
+}
+
+// Next returns the move that continues the line, nil at its end
+func (n *Node) Next() *Node {
+	return n.Variation(0)
+}
+
+// Prev returns the move before, nil for the root
+func (n *Node) Prev() *Node {
+	return n.Parent
+}
+
+// Variation returns the i-th move that can follow n, 0 being the main one.
+// It returns nil if there are fewer moves
+func (n *Node) Variation(i int) *Node {
+	if i < 0 || i >= len(n.Children) {
+		return nil
+	}
+	return n.Children[i]
+}
+
+// index returns the place of n among the moves of its parent
+func (n *Node) index() int {
+	for i, child := range n.Parent.Children {
+		if child == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// Promote moves a variation one place up, the first variation becomes the
+// main line
+func (n *Node) Promote() error {
+	if n.Parent == nil {
+		return errors.New("the root can't be promoted")
+	}
+	i := n.index()
+	if i == 0 {
+		return errors.New("the move " + n.Move + " is already the main line")
+	}
+	siblings := n.Parent.Children
+	siblings[i-1], siblings[i] = siblings[i], siblings[i-1]
+	return nil
+}
+
+// MakeMainLine makes the line that leads to n the main line of the game
+func (n *Node) MakeMainLine() {
+	for node := n; node.Parent != nil; node = node.Parent {
+		i := node.index()
+		siblings := node.Parent.Children
+		copy(siblings[1:i+1], siblings[:i])
+		siblings[0] = node
+	}
+}
+
+// Delete removes the move and everything that follows it from the game
+func (n *Node) Delete() error {
+	if n.Parent == nil {
+		return errors.New("the root can't be deleted")
+	}
+	i := n.index()
+	n.Parent.Children = append(n.Parent.Children[:i:i], n.Parent.Children[i+1:]...)
+	n.Parent = nil
+	return nil
+}
+
+// MainLine returns the moves of the main line
+func (t *Tree) MainLine() []*Node {
+	var line []*Node
+	for n := t.Root.Next(); n != nil; n = n.Next() {
+		line = append(line, n)
+	}
+	return line
+}
+
+// Board returns the position after n, playing the moves that lead to it
+func (t *Tree) Board(n *Node) (chessboard.Chessboard, error) {
+	var path []*Node
+	for node := n; node.Parent != nil; node = node.Parent {
+		path = append(path, node)
+	}
+	board, err := startingBoard(&t.Tags)
+	if err != nil {
+		return board, err
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if err := board.MakeSANMove(path[i].Move); err != nil {
+			return board, err
+		}
+	}
+	return board, nil
+}
+
+// AddMove adds a move, in SAN or UCI, after n and returns its node. If n
+// already has that move, its node is returned. The move becomes a variation
+// if n has moves, the main line if not
+func (t *Tree) AddMove(n *Node, move string) (*Node, error) {
+	board, err := t.Board(n)
+	if err != nil {
+		return nil, err
+	}
+	m, err := board.MoveFromUCI(move)
+	if err != nil {
+		if m, err = board.MoveFromSAN(move); err != nil {
+			return nil, errors.New("illegal or invalid move: " + move)
+		}
+	}
+	san := board.GetSAN(m)
+	for _, child := range n.Children {
+		if child.Move == san {
+			return child, nil
+		}
+	}
+	node := &Node{Move: san, Parent: n}
+	n.Children = append(n.Children, node)
+	return node, nil
+}
+
+// String writes the game as PGN, variations in parentheses, with Result
+// as its Result tag. The ECO, Opening and Variation tags of the main line are
+// added when they aren't set, see eco.SetTags
+func (t *Tree) String() string {
+	var w movetextWriter
+	ply := 0
+	// the tag and the token have to agree
+	tags := t.Tags
+	tags.Result = t.Result
+	// SetTags shouldn't change the tags of t
+	tags.Extra = append([]pgntags.Tag(nil), tags.Extra...)
+	if board, err := startingBoard(&t.Tags); err == nil {
+		ply = 2 * (board.FullmoveCounter - 1)
+		if !board.WhiteToMove {
+			ply++
+		}
+		for _, n := range t.MainLine() {
+			if board.MakeSANMove(n.Move) != nil {
+				break
+			}
+		}
+		eco.SetTags(&tags, &board)
+	}
+	if t.Root.Comment != "" {
+		w.comment(t.Root.Comment)
+	}
+	w.line(t.Root, ply, true)
+	w.word(t.Result)
+	return tags.String() + "\n" + chessboard.WrapPGN(w.words) + "\n"
+}
+
+// movetextWriter collects the words of movetext. Parentheses stick to the
+// words they open and close
+type movetextWriter struct {
+	words []string
+	open  bool // the next word opens a variation
+}
+
+func (w *movetextWriter) word(s string) {
+	if w.open {
+		s, w.open = "("+s, false
+	}
+	w.words = append(w.words, s)
+}
+
+func (w *movetextWriter) comment(text string) {
+	// a "}" would end the comment early
+	words := strings.Fields(strings.ReplaceAll(text, "}", ")"))
+	if len(words) == 0 {
+		w.word("{}")
+		return
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	for _, word := range words {
+		w.word(word)
+	}
+}
+
+// move writes a move with its number, comments and NAGs
+func (w *movetextWriter) move(n *Node, ply int, forceNumber bool) {
+	if n.CommentBefore != "" {
+		w.comment(n.CommentBefore)
+		forceNumber = true
+	}
+	switch {
+	case ply%2 == 0:
+		w.word(strconv.Itoa(ply/2+1) + ".")
+	case forceNumber:
+		w.word(strconv.Itoa(ply/2+1) + "...")
+	}
+	w.word(n.Move)
+	for _, nag := range n.NAGs {
+		w.word("$" + strconv.Itoa(nag))
+	}
+	if comment := joinComments(n.commands(), n.Comment); comment != "" {
+		w.comment(comment)
+	}
+}
+
+// line writes the moves that follow n. ply counts the plies from white's
+// first move. Black moves need their number at the start of the game, after
+// a comment or after a variation
+func (w *movetextWriter) line(n *Node, ply int, number bool) {
+	for len(n.Children) > 0 {
+		main := n.Children[0]
+		w.move(main, ply, number)
+		for _, variation := range n.Children[1:] {
+			w.open = true
+			w.move(variation, ply, true)
+			w.line(variation, ply+1, variation.Comment != "" || variation.commands() != "")
+			w.words[len(w.words)-1] += ")"
+		}
+		number = main.Comment != "" || main.commands() != "" || len(n.Children) > 1
+		n, ply = main, ply+1
+	}
+}
@@ -1,5 +1,10 @@
 package pgntags
 
+import (
+	"errors"
+	"strings"
+)
+
 /*
 	[Event "F/S Return Match"]
 	[Site "Belgrade, Serbia JUG"]
@@ -10,12 +15,283 @@ package pgntags
 	[Result "1/2-1/2"]
 */
 
+// SevenTagRoster are the tags every PGN game has, in the order they are written
+var SevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// Tag is a tag pair, the value unescaped
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// PGNTags are the tags of a game. The Seven Tag Roster has fields of its
+// own, the other tags (TimeControl, ECO, WhiteElo, FEN, ...) are kept in
+// Extra in the order they were added. Round is a string, "29.1", "?" and
+// "-" are valid rounds
 type PGNTags struct {
 	Event  string
 	Site   string
 	Date   string
-	Round  int
+	Round  string
 	White  string
 	Black  string
 	Result string
+
+	Extra []Tag
+}
+
+// roster returns the field of a Seven Tag Roster tag, nil for other tags
+func (t *PGNTags) roster(name string) *string {
+	switch name {
+	case "Event":
+		return &t.Event
+	case "Site":
+		return &t.Site
+	case "Date":
+		return &t.Date
+	case "Round":
+		return &t.Round
+	case "White":
+		return &t.White
+	case "Black":
+		return &t.Black
+	case "Result":
+		return &t.Result
+	}
+	return nil
+}
+
+// Get returns the value of any tag. Empty Seven Tag Roster fields are missing
+func (t *PGNTags) Get(name string) (string, bool) {
+	if field := t.roster(name); field != nil {
+		return *field, *field != ""
+	}
+	for _, tag := range t.Extra {
+		if tag.Name == name {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of any tag. A tag that isn't there yet is added after
+// the others
+func (t *PGNTags) Set(name, value string) error {
+	if !ValidName(name) {
+		return errors.New("invalid tag name: " + name)
+	}
+	if field := t.roster(name); field != nil {
+		*field = value
+		return nil
+	}
+	for i := range t.Extra {
+		if t.Extra[i].Name == name {
+			t.Extra[i].Value = value
+			return nil
+		}
+	}
+	t.Extra = append(t.Extra, Tag{Name: name, Value: value})
+	return nil
+}
+
+// Delete removes a tag. Seven Tag Roster fields are emptied
+func (t *PGNTags) Delete(name string) {
+	if field := t.roster(name); field != nil {
+		*field = ""
+		return
+	}
+	for i := range t.Extra {
+		if t.Extra[i].Name == name {
+			t.Extra = append(t.Extra[:i:i], t.Extra[i+1:]...)
+			return
+		}
+	}
+}
+
+// Tags returns every tag in PGN order: the Seven Tag Roster, with "?", "????.??.??"
+// or "*" for the empty ones, then the rest in the order they were added
+func (t *PGNTags) Tags() []Tag {
+	tags := make([]Tag, 0, len(SevenTagRoster)+len(t.Extra))
+	for _, name := range SevenTagRoster {
+		value := *t.roster(name)
+		if value == "" {
+			switch name {
+			case "Date":
+				value = "????.??.??"
+			case "Result":
+				value = "*"
+			default:
+				value = "?"
+			}
+		}
+		tags = append(tags, Tag{Name: name, Value: value})
+	}
+	return append(tags, t.Extra...)
+}
+
+// String writes the tag pair section of a game, a tag per line
+func (t PGNTags) String() string {
+	var b strings.Builder
+	for _, tag := range t.Tags() {
+		b.WriteString(tag.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// String writes the tag pair, `[Name "Value"]`
+func (tag Tag) String() string {
+	return "[" + tag.Name + " \"" + Escape(tag.Value) + "\"]"
+}
+
+// Escape escapes the quotes and backslashes of a tag value
+func Escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+// ValidName reports if name can be a tag name: letters, digits and
+// underscores, starting with a letter
+func ValidName(name string) bool {
+	if name == "" || !isLetter(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isLetter(name[i]) && !isDigit(name[i]) && name[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(ch byte) bool { return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' }
+func isDigit(ch byte) bool  { return ch >= '0' && ch <= '9' }
+
+// Parse reads a tag pair section. Tags can share a line. A tag that is
+// repeated keeps its last value and its first place
+func Parse(section string) (PGNTags, error) {
+	var t PGNTags
+	rest := strings.TrimSpace(section)
+	for rest != "" {
+		tag, n, err := parseTag(rest)
+		if err != nil {
+			return PGNTags{}, err
+		}
+		if err := t.Set(tag.Name, tag.Value); err != nil {
+			return PGNTags{}, err
+		}
+		rest = strings.TrimSpace(rest[n:])
+	}
+	return t, nil
+}
+
+// ParseTag reads a single tag pair, `[Name "Value"]`
+func ParseTag(s string) (Tag, error) {
+	s = strings.TrimSpace(s)
+	tag, n, err := parseTag(s)
+	if err != nil {
+		return Tag{}, err
+	}
+	if n != len(s) {
+		return Tag{}, errors.New("invalid tag pair, text after it: " + s)
+	}
+	return tag, nil
+}
+
+// parseTag reads the tag pair s starts with and returns how long it was
+func parseTag(s string) (tag Tag, n int, err error) {
+	invalid := errors.New("invalid tag pair: " + firstLine(s))
+	if s == "" || s[0] != '[' {
+		return Tag{}, 0, invalid
+	}
+	i := skipSpace(s, 1)
+	start := i
+	for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '"' {
+		i++
+	}
+	tag.Name = s[start:i]
+	if !ValidName(tag.Name) {
+		return Tag{}, 0, errors.New("invalid tag name: " + firstLine(s))
+	}
+
+	i = skipSpace(s, i)
+	if i == len(s) || s[i] != '"' {
+		return Tag{}, 0, invalid
+	}
+	var value strings.Builder
+	for i++; ; i++ {
+		if i == len(s) {
+			return Tag{}, 0, invalid
+		}
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		} else if s[i] == '"' {
+			break
+		}
+		value.WriteByte(s[i])
+	}
+	tag.Value = value.String()
+
+	i = skipSpace(s, i+1)
+	if i == len(s) || s[i] != ']' {
+		return Tag{}, 0, invalid
+	}
+	return tag, i + 1, nil
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// Validate checks the tags the PGN standard gives a format: Date and Result.
+// Empty tags are valid, they are written as unknown
+func (t *PGNTags) Validate() error {
+	if t.Date != "" && !ValidDate(t.Date) {
+		return errors.New("invalid Date tag: " + t.Date + ", should be like 1992.11.04 or ????.??.??")
+	}
+	if t.Result != "" && !ValidResult(t.Result) {
+		return errors.New("invalid Result tag: " + t.Result + ", should be 1-0, 0-1, 1/2-1/2 or *")
+	}
+	return nil
+}
+
+// ValidDate reports if date is a PGN date, "1992.11.04". Unknown parts are
+// question marks: "1992.??.??"
+func ValidDate(date string) bool {
+	parts := strings.Split(date, ".")
+	if len(parts) != 3 || len(parts[0]) != 4 || len(parts[1]) != 2 || len(parts[2]) != 2 {
+		return false
+	}
+	for i, part := range parts {
+		if part == strings.Repeat("?", len(part)) {
+			continue
+		}
+		n := 0
+		for j := 0; j < len(part); j++ {
+			if !isDigit(part[j]) {
+				return false
+			}
+			n = 10*n + int(part[j]-'0')
+		}
+		if (i == 1 && (n < 1 || n > 12)) || (i == 2 && (n < 1 || n > 31)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidResult reports if result is a PGN result
+func ValidResult(result string) bool {
+	switch result {
+	case "1-0", "0-1", "1/2-1/2", "*":
+		return true
+	}
+	return false
 }
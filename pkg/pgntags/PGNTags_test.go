@@ -0,0 +1,78 @@
+package pgntags
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	section := `[Event "F/S Return Match"]
+[Site "Belgrade, Serbia JUG"]
+[Date "1992.11.04"]
+[Round "29.1"]
+[White "Fischer, Robert J."] [Black "Spassky, Boris V."]
+[Result "1/2-1/2"]
+[TimeControl "40/7200:3600"]
+[Annotator "The \"Chess\" Club \\ Coaches"]
+[ECO "C95"]`
+	tags, err := Parse(section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Round != "29.1" || tags.Black != "Spassky, Boris V." || tags.Result != "1/2-1/2" {
+		t.Errorf("Seven Tag Roster = %+v", tags)
+	}
+	if annotator, _ := tags.Get("Annotator"); annotator != `The "Chess" Club \ Coaches` {
+		t.Errorf("Annotator = %s", annotator)
+	}
+	if err := tags.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// extra tags keep their order, the Seven Tag Roster goes first
+	tags.Set("WhiteElo", "2785")
+	tags.Set("TimeControl", "-")
+	tags.Delete("ECO")
+	want := `[Event "F/S Return Match"]
+[Site "Belgrade, Serbia JUG"]
+[Date "1992.11.04"]
+[Round "29.1"]
+[White "Fischer, Robert J."]
+[Black "Spassky, Boris V."]
+[Result "1/2-1/2"]
+[TimeControl "-"]
+[Annotator "The \"Chess\" Club \\ Coaches"]
+[WhiteElo "2785"]
+`
+	if got := tags.String(); got != want {
+		t.Errorf("String() =\n%s\nshould be\n%s", got, want)
+	}
+	if again, err := Parse(want); err != nil || again.String() != want {
+		t.Errorf("parsing String() again = %v\n%s", err, again.String())
+	}
+
+	if empty := (PGNTags{}).String(); empty != "[Event \"?\"]\n[Site \"?\"]\n[Date \"????.??.??\"]\n[Round \"?\"]\n[White \"?\"]\n[Black \"?\"]\n[Result \"*\"]\n" {
+		t.Errorf("empty tags =\n%s", empty)
+	}
+
+	for _, invalid := range []string{`[Event "x"`, `[Event x]`, `[1Event "x"]`, `Event "x"`, `[Event "x"] junk`} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Parse(%s) should fail", invalid)
+		}
+	}
+	if err := tags.Set("Bad Name", "x"); err == nil {
+		t.Errorf("Set should reject names with spaces")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for date, valid := range map[string]bool{
+		"1992.11.04": true, "????.??.??": true, "1992.??.??": true, "1992.11.??": true,
+		"1992.13.04": false, "1992.11.32": false, "92.11.04": false, "1992-11-04": false, "1992.1?.04": false,
+	} {
+		if ValidDate(date) != valid {
+			t.Errorf("ValidDate(%s) should be %v", date, valid)
+		}
+	}
+	tags := PGNTags{Result: "2-0"}
+	if tags.Validate() == nil {
+		t.Errorf("Result 2-0 should be invalid")
+	}
+}
@@ -0,0 +1,240 @@
+package polyglot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// Entry is one 16 byte record of a .bin book. All fields are big endian on disk
+type Entry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+const entrySize = 16
+
+// Book is a Polyglot opening book, entries sorted by key
+type Book struct {
+	Entries []Entry
+}
+
+// BookMove is a book move that is legal on the board it was looked up for
+type BookMove struct {
+	Move   string // UCI coordinate notation, castling as the king's two square move
+	Weight int
+}
+
+func ReadBook(r io.Reader) (*Book, error) {
+	book := &Book{}
+	reader := bufio.NewReader(r)
+	var buf [entrySize]byte
+	for {
+		_, err := io.ReadFull(reader, buf[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("truncated polyglot book")
+		}
+		book.Entries = append(book.Entries, Entry{
+			Key:    binary.BigEndian.Uint64(buf[0:8]),
+			Move:   binary.BigEndian.Uint16(buf[8:10]),
+			Weight: binary.BigEndian.Uint16(buf[10:12]),
+			Learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+	// books are supposed to be sorted already, but don't trust them
+	if !sort.SliceIsSorted(book.Entries, func(i, j int) bool { return book.Entries[i].Key < book.Entries[j].Key }) {
+		book.sort()
+	}
+	return book, nil
+}
+
+func OpenBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+func (b *Book) sort() {
+	sort.SliceStable(b.Entries, func(i, j int) bool {
+		if b.Entries[i].Key != b.Entries[j].Key {
+			return b.Entries[i].Key < b.Entries[j].Key
+		}
+		return b.Entries[i].Weight > b.Entries[j].Weight
+	})
+}
+
+// Write writes the book in .bin format
+func (b *Book) Write(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	var buf [entrySize]byte
+	for _, entry := range b.Entries {
+		binary.BigEndian.PutUint64(buf[0:8], entry.Key)
+		binary.BigEndian.PutUint16(buf[8:10], entry.Move)
+		binary.BigEndian.PutUint16(buf[10:12], entry.Weight)
+		binary.BigEndian.PutUint32(buf[12:16], entry.Learn)
+		if _, err := writer.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func (b *Book) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Lookup returns the entries stored for key
+func (b *Book) Lookup(key uint64) []Entry {
+	i := sort.Search(len(b.Entries), func(i int) bool { return b.Entries[i].Key >= key })
+	j := i
+	for j < len(b.Entries) && b.Entries[j].Key == key {
+		j++
+	}
+	return b.Entries[i:j]
+}
+
+// Moves returns the legal book moves for the position on c
+func (b *Book) Moves(c *chessboard.Chessboard) []BookMove {
+	var moves []BookMove
+	for _, entry := range b.Lookup(Key(c)) {
+		move := decodeMove(c, entry.Move)
+		if _, err := c.MoveFromUCI(move); err != nil {
+			continue
+		}
+		moves = append(moves, BookMove{Move: move, Weight: int(entry.Weight)})
+	}
+	return moves
+}
+
+// BestMove returns the book move with the highest weight
+func (b *Book) BestMove(c *chessboard.Chessboard) (BookMove, bool) {
+	var best BookMove
+	found := false
+	for _, move := range b.Moves(c) {
+		if !found || move.Weight > best.Weight {
+			best, found = move, true
+		}
+	}
+	return best, found
+}
+
+// RandomMove picks a book move with probability proportional to its weight.
+// Moves with weight 0 are only picked if every move has weight 0
+func (b *Book) RandomMove(c *chessboard.Chessboard, r *rand.Rand) (BookMove, bool) {
+	moves := b.Moves(c)
+	if len(moves) == 0 {
+		return BookMove{}, false
+	}
+
+	total := 0
+	for _, move := range moves {
+		total += move.Weight
+	}
+	if total == 0 {
+		return moves[r.Intn(len(moves))], true
+	}
+
+	pick := r.Intn(total)
+	for _, move := range moves {
+		pick -= move.Weight
+		if pick < 0 {
+			return move, true
+		}
+	}
+	return moves[len(moves)-1], true
+}
+
+/*
+	move layout:
+	bits  0-2  to file
+	bits  3-5  to row
+	bits  6-8  from file
+	bits  9-11 from row
+	bits 12-14 promotion: 0 none, 1 knight, 2 bishop, 3 rook, 4 queen
+	castling is written as the king taking its own rook, eg e1h1
+*/
+
+const promotionChars = " nbrq"
+
+func encodeMove(c *chessboard.Chessboard, uci string) uint16 {
+	from := int(uci[0]-'a') | int(uci[1]-'1')<<3
+	to := int(uci[2]-'a') | int(uci[3]-'1')<<3
+	if isCastling(c, uci) {
+		// the rook sits on the corner the king moves towards
+		if uci[2] == 'g' {
+			to = 7 | (to & 0x38)
+		} else {
+			to = 0 | (to & 0x38)
+		}
+	}
+	promotion := 0
+	if len(uci) > 4 {
+		for i := 1; i < len(promotionChars); i++ {
+			if uci[4] == promotionChars[i] {
+				promotion = i
+			}
+		}
+	}
+	return uint16(to | from<<6 | promotion<<12)
+}
+
+func decodeMove(c *chessboard.Chessboard, move uint16) string {
+	to := []byte{'a' + byte(move&7), '1' + byte(move>>3&7)}
+	from := []byte{'a' + byte(move>>6&7), '1' + byte(move>>9&7)}
+	uci := string(from) + string(to)
+	if promotion := move >> 12 & 7; promotion != 0 && int(promotion) < len(promotionChars) {
+		uci += string(promotionChars[promotion])
+	}
+
+	switch uci {
+	case "e1h1", "e1a1", "e8h8", "e8a8":
+		// king takes own rook means castling
+		king, rook := chessboard.WKING, chessboard.WROOK
+		if uci[1] == '8' {
+			king, rook = chessboard.BKING, chessboard.BROOK
+		}
+		if hasPiece(c, king, uci[0:2]) && hasPiece(c, rook, uci[2:4]) {
+			if uci[2] == 'h' {
+				return uci[0:2] + "g" + uci[3:4]
+			}
+			return uci[0:2] + "c" + uci[3:4]
+		}
+	}
+	return uci
+}
+
+func isCastling(c *chessboard.Chessboard, uci string) bool {
+	switch uci {
+	case "e1g1", "e1c1":
+		return hasPiece(c, chessboard.WKING, "e1")
+	case "e8g8", "e8c8":
+		return hasPiece(c, chessboard.BKING, "e8")
+	}
+	return false
+}
+
+func hasPiece(c *chessboard.Chessboard, piece int, square string) bool {
+	return c.BoardState[piece]&(1<<(8*int(square[1]-'1')+int(square[0]-'a'))) != 0
+}
@@ -0,0 +1,136 @@
+package polyglot
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+const games = `[Event "a"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 {a comment
+over two lines} Nc6 3. Bb5 (3. Bc4 Bc5) a6 4. Ba4 Nf6 5. O-O 1-0
+
+[Event "b"]
+[Result "0-1"]
+
+1. e4 c5 2. Nf3 $1 d6 0-1
+
+[Event "c"]
+[Result "1/2-1/2"]
+
+1.e4 e5 2.Nf3 Nf6 ; Petroff
+3.Nxe5 1/2-1/2
+`
+
+func TestBuilder(t *testing.T) {
+	builder := NewBuilder(6, 2)
+	if err := builder.AddPGN(strings.NewReader(games)); err != nil {
+		t.Fatal(err)
+	}
+	book := builder.Book()
+
+	c := chessboard.CreateChessboard("")
+	moves := book.Moves(&c)
+	// e4 was played in all three games: 2 points for the win and 1 for the draw
+	if len(moves) != 1 || moves[0] != (BookMove{Move: "e2e4", Weight: 3}) {
+		t.Errorf("book.Moves(initial position) = %v, should equal [{e2e4 3}]", moves)
+	}
+
+	// 1...c5 was only played once, below MinFrequency
+	c.MakeSANMove("e4")
+	if best, _ := book.BestMove(&c); best.Move != "e7e5" {
+		t.Errorf("book.BestMove after 1. e4 = %v, should be e7e5", best)
+	}
+
+	// 3. Bb5 is only in one game and ply 7 is past MaxPly
+	c.MakeSANMove("e5")
+	c.MakeSANMove("Nf3")
+	c.MakeSANMove("Nc6")
+	if moves := book.Moves(&c); len(moves) != 0 {
+		t.Errorf("book.Moves after 2... Nc6 = %v, should be empty", moves)
+	}
+}
+
+func TestBookReadWrite(t *testing.T) {
+	c := chessboard.CreateChessboard("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	book := &Book{Entries: []Entry{
+		{Key: Key(&c), Move: encodeMove(&c, "e1g1"), Weight: 10},
+		{Key: Key(&c), Move: encodeMove(&c, "e1c1"), Weight: 30},
+	}}
+	book.sort()
+
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 2*entrySize {
+		t.Fatalf("written book is %d bytes, should be %d", buf.Len(), 2*entrySize)
+	}
+	read, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// castling is stored as king takes rook
+	if read.Entries[0].Move != 0x0100 {
+		t.Errorf("e1c1 encoded as %#x, should be 0x0100 (e1a1)", read.Entries[0].Move)
+	}
+	moves := read.Moves(&c)
+	if len(moves) != 2 || moves[0].Move != "e1c1" || moves[1].Move != "e1g1" {
+		t.Errorf("read.Moves() = %v, should be e1c1 and e1g1", moves)
+	}
+
+	counts := map[string]int{}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		move, _ := read.RandomMove(&c, r)
+		counts[move.Move]++
+	}
+	if counts["e1c1"] < 2700 || counts["e1c1"] > 3300 {
+		t.Errorf("e1c1 picked %d times out of 4000, should be about 3000", counts["e1c1"])
+	}
+}
+
+// the keys listed in the Polyglot book format
+func TestKey(t *testing.T) {
+	c := chessboard.CreateChessboard("")
+	if key := Key(&c); key != 0x463b96181691fc9c {
+		t.Errorf("key of the starting position is %016x, should be 463b96181691fc9c", key)
+	}
+	for _, test := range []struct {
+		move string
+		key  uint64
+	}{
+		{"e2e4", 0x823c9b50fd114196},
+		{"d7d5", 0x0756b94461c50fb0},
+		{"e4e5", 0x662fafb965db29d4},
+		{"f7f5", 0x22a48b5a8e47ff78}, // e5 can take en passant
+		{"e1e2", 0x652a607ca3f242c1},
+		{"e8f7", 0x00fdd303c946bdd9},
+	} {
+		if err := c.MakeUCIMove(test.move); err != nil {
+			t.Fatal(err)
+		}
+		if key := Key(&c); key != test.key {
+			t.Errorf("key after %s is %016x, should be %016x", test.move, key, test.key)
+		}
+	}
+
+	c = chessboard.CreateChessboard("")
+	for _, move := range []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"} {
+		c.MakeUCIMove(move)
+	}
+	if key := Key(&c); key != 0x3c8123ea7b067637 {
+		t.Errorf("key after c4 is %016x, should be 3c8123ea7b067637", key)
+	}
+	c.MakeUCIMove("b4c3")
+	c.MakeUCIMove("a1a3")
+	if key := Key(&c); key != 0x5c3f9b829b279560 {
+		t.Errorf("key after Ra3 is %016x, should be 5c3f9b829b279560", key)
+	}
+}
@@ -0,0 +1,113 @@
+package polyglot
+
+import (
+	"io"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgn"
+)
+
+// Builder collects the moves of a set of games and turns them into a Book.
+// Every move is scored for the side that played it like Polyglot does:
+// 2 points for a win, 1 for a draw, 0 for a loss.
+type Builder struct {
+	// MaxPly is how many plies of each game go into the book. 0 means all of them
+	MaxPly int
+	// MinFrequency leaves out moves played in fewer games than this
+	MinFrequency int
+
+	stats map[bookKey]*moveStats
+}
+
+type bookKey struct {
+	key  uint64
+	move uint16
+}
+
+type moveStats struct {
+	games int
+	score int
+}
+
+func NewBuilder(maxPly, minFrequency int) *Builder {
+	return &Builder{
+		MaxPly:       maxPly,
+		MinFrequency: minFrequency,
+		stats:        map[bookKey]*moveStats{},
+	}
+}
+
+// AddGame adds a game played from the initial position. moves are in SAN and
+// result is "1-0", "0-1", "1/2-1/2" or "*". The game is added up to the first
+// move that can't be played
+func (b *Builder) AddGame(moves []string, result string) {
+	c := chessboard.CreateChessboard("")
+	for ply, san := range moves {
+		if b.MaxPly > 0 && ply >= b.MaxPly {
+			return
+		}
+		move, err := c.MoveFromSAN(san)
+		if err != nil {
+			return
+		}
+
+		k := bookKey{key: Key(&c), move: encodeMove(&c, move.String())}
+		stats := b.stats[k]
+		if stats == nil {
+			stats = &moveStats{}
+			b.stats[k] = stats
+		}
+		stats.games++
+		switch {
+		case result == "1/2-1/2":
+			stats.score++
+		case result == "1-0" && c.WhiteToMove, result == "0-1" && !c.WhiteToMove:
+			stats.score += 2
+		}
+
+		if err := c.MakeSANMove(san); err != nil {
+			return
+		}
+	}
+}
+
+// AddPGN adds every game of a PGN collection. Comments, variations and
+// numeric annotation glyphs are skipped. Games that don't start from the
+// initial position (the ones with a FEN tag) are skipped
+func (b *Builder) AddPGN(r io.Reader) error {
+	reader := pgn.NewReader(r)
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, setUp := game.Tags.Get("FEN"); !setUp && len(game.Moves) > 0 {
+			b.AddGame(game.Moves, game.Result)
+		}
+	}
+}
+
+// Book returns the book built from the games added so far
+func (b *Builder) Book() *Book {
+	maxScore := 0
+	for _, stats := range b.stats {
+		maxScore = max(maxScore, stats.score)
+	}
+
+	book := &Book{}
+	for k, stats := range b.stats {
+		if stats.games < b.MinFrequency || stats.score == 0 {
+			continue
+		}
+		weight := stats.score
+		if maxScore > 0xffff {
+			weight = max(1, int(uint64(weight)*0xffff/uint64(maxScore)))
+		}
+		book.Entries = append(book.Entries, Entry{Key: k.key, Move: k.move, Weight: uint16(weight)})
+	}
+	book.sort()
+	return book
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgn"
+	"github.com/kahnaisehC/chessboard/pkg/polyglot"
+	"github.com/kahnaisehC/chessboard/pkg/tablebase"
+)
+
+// Matcher tells if a position is one that is looked for
+type Matcher func(c *chessboard.Chessboard) bool
+
+// Position matches the position on c, whatever the moves that led to it: the
+// same pieces on the same squares, side to move, castling rights and en
+// passant capture. The polyglot key is checked first, the pieces to rule out
+// collisions
+func Position(c *chessboard.Chessboard) Matcher {
+	key, pieces, whiteToMove := polyglot.Key(c), c.BoardState, c.WhiteToMove
+	return func(board *chessboard.Chessboard) bool {
+		return polyglot.Key(board) == key && board.BoardState == pieces && board.WhiteToMove == whiteToMove
+	}
+}
+
+const signatureOrder = "KQRBNP"
+
+// Material matches positions with the material of signature, written like
+// tablebase signatures, "KRPvKR" or "KRPKR", for either color. A "*" stands
+// for any number of pawns of that side: "KR*vKR*" are the rook endgames
+func Material(signature string) (Matcher, error) {
+	s := strings.ToUpper(signature)
+	white, black, found := strings.Cut(s, "V")
+	if !found {
+		// "KRPKR": the second king starts black's pieces
+		second := strings.IndexByte(s[min(1, len(s)):], 'K') + 1
+		if second <= 0 {
+			return nil, errors.New("signature needs two kings: " + signature)
+		}
+		white, black = s[:second], s[second:]
+	}
+	anyPawns := [2]bool{strings.Contains(white, "*"), strings.Contains(black, "*")}
+	for i, side := range []*string{&white, &black} {
+		*side = strings.ReplaceAll(*side, "*", "")
+		if anyPawns[i] {
+			*side = strings.ReplaceAll(*side, "P", "")
+		}
+		if strings.Count(*side, "K") != 1 {
+			return nil, errors.New("each side needs exactly one king: " + signature)
+		}
+		// in the order of tablebase.Signature
+		letters := []byte(*side)
+		for _, letter := range letters {
+			if strings.IndexByte(signatureOrder, letter) == -1 {
+				return nil, errors.New("unknown piece in signature: " + signature)
+			}
+		}
+		slices.SortFunc(letters, func(a, b byte) int {
+			return strings.IndexByte(signatureOrder, a) - strings.IndexByte(signatureOrder, b)
+		})
+		*side = string(letters)
+	}
+
+	// pieces of the two sides of a board as the signature counts them
+	sides := func(first, second string) (string, string) {
+		if anyPawns[0] {
+			first = strings.TrimRight(first, "P")
+		}
+		if anyPawns[1] {
+			second = strings.TrimRight(second, "P")
+		}
+		return first, second
+	}
+	return func(board *chessboard.Chessboard) bool {
+		w, b, _ := strings.Cut(tablebase.Signature(board), "v")
+		if x, y := sides(w, b); x == white && y == black {
+			return true
+		}
+		x, y := sides(b, w)
+		return x == white && y == black
+	}, nil
+}
+
+// constraint is a piece that has to be, or not be, on one of a set of squares
+type constraint struct {
+	piece   int    // 0 for any piece, -1 for an empty square
+	squares uint64 // bits numbered like BoardState, 8*row + col
+	not     bool
+}
+
+var letterToPiece = map[byte]int{
+	'K': chessboard.WKING, 'Q': chessboard.WQUEEN, 'R': chessboard.WROOK,
+	'B': chessboard.WBISHOP, 'N': chessboard.WKNIGHT, 'P': chessboard.WPAWN,
+	'k': chessboard.BKING, 'q': chessboard.BQUEEN, 'r': chessboard.BROOK,
+	'b': chessboard.BBISHOP, 'n': chessboard.BKNIGHT, 'p': chessboard.BPAWN,
+	'?': 0, '.': -1,
+}
+
+// Pattern matches positions with pieces on squares. pattern is a list of
+// constraints separated by spaces or commas: a piece letter, uppercase for
+// white, "?" for any piece or "." for an empty square, then a square. "*"
+// stands for any file or any rank. A constraint holds if one of its squares
+// has the piece, or none of them with "!" in front:
+//
+//	Kg1 Rf1 pe5  white king on g1, rook on f1, black pawn on e5
+//	R*7 !q**     a white rook on the 7th rank, black has no queen
+//	?d5 .d4      something on d5, d4 empty
+func Pattern(pattern string) (Matcher, error) {
+	var constraints []constraint
+	for _, token := range strings.FieldsFunc(pattern, func(r rune) bool { return r == ' ' || r == ',' }) {
+		c := constraint{}
+		if token[0] == '!' {
+			c.not, token = true, token[1:]
+		}
+		if len(token) != 3 {
+			return nil, errors.New("invalid constraint: " + token)
+		}
+		piece, ok := letterToPiece[token[0]]
+		file, rank := token[1], token[2]
+		if !ok || (file != '*' && (file < 'a' || file > 'h')) || (rank != '*' && (rank < '1' || rank > '8')) {
+			return nil, errors.New("invalid constraint: " + token)
+		}
+		c.piece = piece
+		for row := 0; row < 8; row++ {
+			for col := 0; col < 8; col++ {
+				if (file == '*' || int(file-'a') == col) && (rank == '*' || int(rank-'1') == row) {
+					c.squares |= 1 << (8*row + col)
+				}
+			}
+		}
+		constraints = append(constraints, c)
+	}
+	if len(constraints) == 0 {
+		return nil, errors.New("empty pattern")
+	}
+
+	return func(board *chessboard.Chessboard) bool {
+		var occupied uint64
+		for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+			occupied |= board.BoardState[piece]
+		}
+		for _, c := range constraints {
+			var bitboard uint64
+			switch c.piece {
+			case 0:
+				bitboard = occupied
+			case -1:
+				bitboard = ^occupied
+			default:
+				bitboard = board.BoardState[c.piece]
+			}
+			if (bitboard&c.squares != 0) == c.not {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// All matches the positions every matcher matches
+func All(matchers ...Matcher) Matcher {
+	return func(board *chessboard.Chessboard) bool {
+		for _, match := range matchers {
+			if !match(board) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Match is a game with a position that matched
+type Match struct {
+	Game   *pgn.Game
+	Number int // of the game in the collection, from 1
+	Ply    int // plies played before the position, 0 for the starting position
+	Board  chessboard.Chessboard
+}
+
+// Searcher looks for positions in the main lines of a PGN collection
+type Searcher struct {
+	Match Matcher
+	// MinPlies is how many plies in a row the positions have to match, 1 if
+	// 0. It keeps out material that only lasts during an exchange
+	MinPlies int
+}
+
+// Search calls visit with the first position of every game that matches.
+// A game is searched up to its first move that can't be played. Search stops
+// when visit returns false
+func (s Searcher) Search(r io.Reader, visit func(Match) bool) error {
+	reader := pgn.NewReader(r)
+	for number := 1; ; number++ {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var match *Match
+		streak := 0
+		var first chessboard.Chessboard
+		game.Replay(func(ply int, board *chessboard.Chessboard) bool {
+			if !s.Match(board) {
+				streak = 0
+				return true
+			}
+			if streak == 0 {
+				first = *board
+			}
+			streak++
+			if streak >= max(1, s.MinPlies) {
+				match = &Match{Game: game, Number: number, Ply: ply - streak + 1, Board: first}
+				return false
+			}
+			return true
+		})
+		if match != nil && !visit(*match) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+const games = `[White "Ann"] [Black "Bob"] [FEN "4k2r/8/8/8/8/8/4P3/R3K3 w - - 0 1"] [Result "0-1"]
+1. e4 Rh1+ 2. Kd2 Rxa1 0-1
+
+[White "Bob"] [Black "Cid"] [Result "*"]
+1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 *
+
+[White "Cid"] [Black "Ann"] [Result "*"]
+1. Nf3 Nc6 2. e4 e5 3. Bb5 *
+`
+
+func find(t *testing.T, s Searcher) []Match {
+	var matches []Match
+	if err := s.Search(strings.NewReader(games), func(m Match) bool {
+		matches = append(matches, m)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+// plies returns the game numbers and plies of the matches, like "1:0 3:4"
+func plies(matches []Match) string {
+	var s []string
+	for _, m := range matches {
+		s = append(s, string(rune('0'+m.Number))+":"+string(rune('0'+m.Ply)))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestSearch(t *testing.T) {
+	board := chessboard.CreateChessboard("")
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6"} {
+		board.MakeSANMove(san)
+	}
+	matches := find(t, Searcher{Match: Position(&board)})
+	if got := plies(matches); got != "2:4 3:4" {
+		t.Errorf("position found at %q, should be at \"2:4 3:4\"", got)
+	}
+	if len(matches) > 0 && (matches[1].Game.Tags.White != "Cid" || matches[1].Board.BoardState != board.BoardState) {
+		t.Errorf("wrong match: %+v", matches[1])
+	}
+
+	for _, test := range []struct{ signature, want string }{
+		{"KRPvKR", "1:0"},
+		{"krpkr", "1:0"},
+		{"KR*vKR*", "1:0"},
+		{"KRvKP", "1:4"}, // black has the rook
+		{"KPvKR", "1:4"},
+		{"KR*vK*", "1:4"}, // rook against pawns
+		{"KQRRBBNNPPPPPPPPvKQRRBBNNPPPPPPPP", "2:0 3:0"},
+	} {
+		m, err := Material(test.signature)
+		if err != nil {
+			t.Errorf("%s: %v", test.signature, err)
+			continue
+		}
+		if got := plies(find(t, Searcher{Match: m})); got != test.want {
+			t.Errorf("%s found at %q, should be at %q", test.signature, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		pattern  string
+		minPlies int
+		want     string
+	}{
+		{"Bb5 pa6", 0, "2:6"},
+		{"Bb5, !pa6", 0, "2:5 3:5"},
+		{"?e4 .e2", 0, "1:1 2:1 3:3"},
+		{"r*1 !R**", 0, "1:4"},
+		{"n*6 P*4", 0, "2:4 3:3"},
+		{"Ke1", 3, "1:0 2:0 3:0"},
+		{"Ke1 !p*5", 4, "3:0"},
+		{"Ke1 kg8", 0, ""},
+	} {
+		m, err := Pattern(test.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", test.pattern, err)
+			continue
+		}
+		if got := plies(find(t, Searcher{Match: m, MinPlies: test.minPlies})); got != test.want {
+			t.Errorf("%s found at %q, should be at %q", test.pattern, got, test.want)
+		}
+	}
+
+	m, _ := Material("KR*vKR*")
+	p, _ := Pattern("Ke1")
+	if got := plies(find(t, Searcher{Match: All(m, p), MinPlies: 3})); got != "1:0" {
+		t.Errorf("all found at %q, should be at \"1:0\"", got)
+	}
+
+	count := 0
+	Searcher{Match: p}.Search(strings.NewReader(games), func(Match) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("search went on after visit returned false")
+	}
+
+	for _, pattern := range []string{"", "Xe4", "Ki9", "Kee4", "!"} {
+		if _, err := Pattern(pattern); err == nil {
+			t.Errorf("pattern %q should be invalid", pattern)
+		}
+	}
+	for _, signature := range []string{"KRR", "KRvR", "KXvK"} {
+		if _, err := Material(signature); err == nil {
+			t.Errorf("signature %q should be invalid", signature)
+		}
+	}
+}
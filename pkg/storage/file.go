@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+const journalExtension = ".journal"
+
+// errEmptyJournal is a journal whose header wasn't fully written
+var errEmptyJournal = errors.New("empty journal")
+
+// FileStorage keeps each game in a file of its own in Dir, one JSON record
+// per line: the header, then a line per move, then the result. Every write
+// is synced, a move that was accepted survives a crash. A line cut short by
+// a crash is ignored when the journal is read
+type FileStorage struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// record is a line of a journal file, one of the fields is set
+type record struct {
+	Header *Header `json:",omitempty"`
+	Move   *Entry  `json:",omitempty"`
+	Result string  `json:",omitempty"`
+}
+
+// NewFileStorage keeps games in dir, creating it if needed
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStorage{Dir: dir}, nil
+}
+
+func (fs *FileStorage) path(id string) (string, error) {
+	if !validID(id) {
+		return "", errors.New("invalid game ID: " + id)
+	}
+	return filepath.Join(fs.Dir, id+journalExtension), nil
+}
+
+func (fs *FileStorage) Create(header Header) error {
+	path, err := fs.path(header.ID)
+	if err != nil {
+		return err
+	}
+	if header.Tags.Result == "" {
+		header.Tags.Result = chessboard.ONGOING.String()
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return errors.New("game " + header.ID + " already exists")
+		}
+		return err
+	}
+	return writeRecord(f, record{Header: &header})
+}
+
+func (fs *FileStorage) Append(id string, entry Entry) error {
+	return fs.append(id, record{Move: &entry})
+}
+
+func (fs *FileStorage) Finish(id string, result chessboard.Result) error {
+	return fs.append(id, record{Result: result.String()})
+}
+
+func (fs *FileStorage) append(id string, r record) error {
+	path, err := fs.path(id)
+	if err != nil {
+		return err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := cutTornLine(f); err != nil {
+		f.Close()
+		return err
+	}
+	return writeRecord(f, r)
+}
+
+// cutTornLine removes a last line a crash cut short, so the next record
+// starts a line of its own, and leaves f at its end
+func cutTornLine(f *os.File) error {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		size := int64(bytes.LastIndexByte(data, '\n') + 1)
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	return err
+}
+
+// writeRecord writes r as a line, syncs and closes f
+func writeRecord(f *os.File, r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (fs *FileStorage) Load(id string) (Journal, error) {
+	path, err := fs.path(id)
+	if err != nil {
+		return Journal{}, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return Journal{}, err
+	}
+	defer f.Close()
+	return readJournal(f)
+}
+
+// readJournal reads a journal file. The last line may be cut short
+func readJournal(r io.Reader) (Journal, error) {
+	var journal Journal
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without its newline wasn't fully written
+			break
+		}
+		if err != nil {
+			return Journal{}, err
+		}
+
+		var r record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &r); err != nil {
+			return Journal{}, errors.New("journal line " + strconv.Itoa(lineNumber) + ": " + err.Error())
+		}
+		switch {
+		case lineNumber == 1 && r.Header == nil, lineNumber > 1 && r.Header != nil:
+			return Journal{}, errors.New("journal line " + strconv.Itoa(lineNumber) + ": the header should be the first line")
+		case r.Header != nil:
+			journal.Header = *r.Header
+		case r.Move != nil:
+			journal.Entries = append(journal.Entries, *r.Move)
+		case r.Result != "":
+			journal.Header.Tags.Result = r.Result
+		}
+	}
+	if journal.Header.ID == "" {
+		return Journal{}, errEmptyJournal
+	}
+	return journal, nil
+}
+
+func (fs *FileStorage) List(query Query) ([]Header, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	entries, err := os.ReadDir(fs.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var headers []Header
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), journalExtension) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(fs.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		journal, err := readJournal(f)
+		f.Close()
+		if err == errEmptyJournal {
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(entry.Name() + ": " + err.Error())
+		}
+		if query.Match(journal.Header) {
+			headers = append(headers, journal.Header)
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool {
+		return headers[i].Created.Before(headers[j].Created)
+	})
+	return headers, nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/clock"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+// Storage keeps games as a journal: how each game started and every move
+// accepted since. A game is rebuilt by replaying its journal, see Journal.Replay
+type Storage interface {
+	// Create starts the journal of a new game. IDs are unique per storage
+	Create(header Header) error
+	// Append adds an accepted move to the journal of a game
+	Append(id string, entry Entry) error
+	// Finish records the result of a game, also when it wasn't decided on
+	// the board: resignation, agreement, time
+	Finish(id string, result chessboard.Result) error
+	Load(id string) (Journal, error)
+	// List returns the headers of the games that match query, oldest first
+	List(query Query) ([]Header, error)
+}
+
+// Header is how a game started. Tags.Result is "*" until the game is finished
+type Header struct {
+	ID string
+	// Variant is a registered variant name or "Chess960", "" for standard chess
+	Variant     string
+	FEN         string // "" for the starting position of the variant
+	TimeControl string // like the PGN tag, "" for no clock
+	Created     time.Time
+	Tags        pgntags.PGNTags
+}
+
+// Entry is a move in a journal: the move, when it was made and the time
+// both sides had left after it
+type Entry struct {
+	UCI       string
+	Time      time.Time
+	WhiteTime time.Duration `json:",omitempty"`
+	BlackTime time.Duration `json:",omitempty"`
+}
+
+type Journal struct {
+	Header  Header
+	Entries []Entry
+}
+
+// Query selects games by their PGN tags. Zero fields match every game
+type Query struct {
+	Player string // white or black, case is ignored
+	Result string // "1-0", "0-1", "1/2-1/2" or "*"
+	// From and To bound the Date tag, both days included. Games with an
+	// unknown date don't match a bound
+	From time.Time
+	To   time.Time
+}
+
+const dateFormat = "2006.01.02"
+
+// Match reports if the game of header matches q
+func (q Query) Match(header Header) bool {
+	tags := header.Tags
+	if q.Player != "" && !strings.EqualFold(q.Player, tags.White) && !strings.EqualFold(q.Player, tags.Black) {
+		return false
+	}
+	if q.Result != "" && q.Result != tags.Result {
+		return false
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	// dates in the tag format compare like strings
+	if _, err := time.Parse(dateFormat, tags.Date); err != nil {
+		return false
+	}
+	if !q.From.IsZero() && tags.Date < q.From.Format(dateFormat) {
+		return false
+	}
+	if !q.To.IsZero() && tags.Date > q.To.Format(dateFormat) {
+		return false
+	}
+	return true
+}
+
+// Board sets up the board a game started from, with its clock
+func (h Header) Board() (chessboard.Chessboard, error) {
+	var board chessboard.Chessboard
+	switch h.Variant {
+	case "", "Standard", "Chess960":
+		if h.FEN == "" && h.Variant == "Chess960" {
+			// the header should have the FEN of the position it got
+			return chessboard.Chessboard{}, errors.New("a Chess960 game needs a FEN")
+		}
+		if h.FEN != "" {
+			if ok, logs := chessboard.ValidateFEN(h.FEN); !ok {
+				return chessboard.Chessboard{}, errors.New("invalid FEN: " + logs)
+			}
+		}
+		board = chessboard.CreateChessboard(h.FEN)
+		board.Chess960 = h.Variant == "Chess960"
+	default:
+		variant, ok := chessboard.VariantByName(h.Variant)
+		if !ok {
+			return chessboard.Chessboard{}, errors.New("unknown variant " + h.Variant)
+		}
+		var err error
+		if board, err = chessboard.CreateVariantChessboard(variant, h.FEN); err != nil {
+			return chessboard.Chessboard{}, err
+		}
+	}
+
+	if h.TimeControl != "" {
+		control, err := clock.ParseTimeControl(h.TimeControl)
+		if err != nil {
+			return chessboard.Chessboard{}, err
+		}
+		board.Clock = clock.New(control, nil)
+	}
+	board.PGNTags = h.Tags
+	return board, nil
+}
+
+// Replay rebuilds the board of a game from its journal. The clock gets the
+// times of the last entry and runs for the side to move from now on, the
+// time the game spent stored is not counted
+func (j Journal) Replay() (chessboard.Chessboard, error) {
+	board, err := j.Header.Board()
+	if err != nil {
+		return chessboard.Chessboard{}, err
+	}
+	for _, entry := range j.Entries {
+		if err := board.MakeUCIMove(entry.UCI); err != nil {
+			return chessboard.Chessboard{}, errors.New("game " + j.Header.ID + ": " + err.Error())
+		}
+		if board.Clock != nil {
+			board.Clock.Adjust(entry.WhiteTime, entry.BlackTime)
+		}
+	}
+	return board, nil
+}
+
+// NewEntry returns the entry of the last move played on board
+func NewEntry(board *chessboard.Chessboard) Entry {
+	entry := Entry{Time: time.Now()}
+	if len(board.Moves) > 0 {
+		entry.UCI = board.Moves[len(board.Moves)-1]
+	}
+	if board.Clock != nil {
+		entry.WhiteTime = board.Clock.Remaining(chessboard.WHITE)
+		entry.BlackTime = board.Clock.Remaining(chessboard.BLACK)
+	}
+	return entry
+}
+
+const idChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// NewID returns a random game ID, 8 letters and digits
+func NewID() string {
+	id := make([]byte, 8)
+	for i := range id {
+		id[i] = idChars[rand.IntN(len(idChars))]
+	}
+	return string(id)
+}
+
+// validID keeps IDs usable as file names
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !strings.ContainsRune(idChars+"-_", rune(id[i])) {
+			return false
+		}
+	}
+	return true
+}
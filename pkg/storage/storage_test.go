@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/pgntags"
+)
+
+func TestJournal(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := Header{
+		ID:          "game1",
+		TimeControl: "300+2",
+		Created:     time.Now(),
+		Tags:        pgntags.PGNTags{White: "Ann", Black: "Bob", Date: "2024.03.01"},
+	}
+	if err := fs.Create(header); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Create(header); err == nil {
+		t.Errorf("creating game1 twice should fail")
+	}
+	if err := fs.Create(Header{ID: "../escape"}); err == nil {
+		t.Errorf("IDs should not be paths")
+	}
+
+	// the game as a server plays it
+	board, err := header.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range []string{"e2e4", "e7e5", "g1f3"} {
+		if err := board.MakeUCIMove(move); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Append("game1", NewEntry(&board)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	board.Clock.Adjust(250*time.Second, 280*time.Second)
+	if err := fs.Append("game1", Entry{UCI: "b8c6", Time: time.Now(), WhiteTime: 250 * time.Second, BlackTime: 280 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while writing the next move
+	path := filepath.Join(fs.Dir, "game1"+journalExtension)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Move":{"UCI":"f1b`)
+	f.Close()
+
+	journal, err := fs.Load("game1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Entries) != 4 {
+		t.Fatalf("journal has %d moves, should have 4", len(journal.Entries))
+	}
+	replayed, err := journal.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := replayed.GetFEN(); fen != "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3" {
+		t.Errorf("replayed FEN = %s", fen)
+	}
+	if white, black := replayed.Clock.Remaining(chessboard.WHITE), replayed.Clock.Remaining(chessboard.BLACK); white > 250*time.Second || white < 249*time.Second || black != 280*time.Second {
+		t.Errorf("replayed clock = %v, %v, should be 250s, 280s", white, black)
+	}
+	if _, running := replayed.Clock.Running(); !running {
+		t.Errorf("the replayed clock should run for white")
+	}
+
+	// the torn line is replaced by the next move
+	if err := fs.Append("game1", Entry{UCI: "f1b5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Finish("game1", chessboard.WHITEWINS); err != nil {
+		t.Fatal(err)
+	}
+	if journal, err = fs.Load("game1"); err != nil || len(journal.Entries) != 5 || journal.Header.Tags.Result != "1-0" {
+		t.Errorf("after the crash: %+v, %v", journal, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	games := []pgntags.PGNTags{
+		{White: "Ann", Black: "Bob", Date: "2024.01.05"},
+		{White: "Bob", Black: "Carl", Date: "2024.02.10"},
+		{White: "Carl", Black: "Ann", Date: "????.??.??"},
+	}
+	for i, tags := range games {
+		header := Header{ID: NewID(), Created: start.Add(time.Duration(i) * time.Hour), Tags: tags}
+		if err := fs.Create(header); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			fs.Finish(header.ID, chessboard.DRAW)
+		}
+	}
+	// a game whose header a crash cut short
+	os.WriteFile(filepath.Join(fs.Dir, "torn"+journalExtension), []byte(`{"Header":{"ID":"to`), 0o644)
+
+	tests := []struct {
+		query Query
+		want  []string // white players
+	}{
+		{Query{}, []string{"Ann", "Bob", "Carl"}},
+		{Query{Player: "ann"}, []string{"Ann", "Carl"}},
+		{Query{Result: "*"}, []string{"Ann", "Carl"}},
+		{Query{Player: "Bob", Result: "1/2-1/2"}, []string{"Bob"}},
+		{Query{From: time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)}, []string{"Ann", "Bob"}},
+		{Query{To: time.Date(2024, 2, 9, 0, 0, 0, 0, time.UTC)}, []string{"Ann"}},
+	}
+	for _, test := range tests {
+		headers, err := fs.List(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, header := range headers {
+			got = append(got, header.Tags.White)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("List(%+v) = %v, should be %v", test.query, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("List(%+v) = %v, should be %v", test.query, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package syzygy
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+/*
+	Position indexes, as the Syzygy generator computes them
+
+	Squares are numbered a1 = 0, h1 = 7, a8 = 63, like the BoardState bits.
+	Pieces are coded like in the files: 1 to 6 for white pawn, knight,
+	bishop, rook, queen and king, plus 8 for black.
+
+	Tables without pawns are reduced by the 8 symmetries of the board: the
+	first piece goes to the a1-d1-d4 triangle, and the first piece that is off
+	the a1-h8 diagonal below it. When there are 3 unique pieces, kings
+	included, they are indexed together (31332 ways), else the two kings are
+	(462 ways).
+	Tables with pawns are split by the file of the leading pawn, a to d after
+	a horizontal flip. The other groups of identical pieces are indexed as
+	combinations of the squares left.
+*/
+
+var (
+	mapA1D1D4     [64]int // a1-d1-d4 triangle to 0..9, the diagonal last
+	mapB1H1H7     [64]int // squares below the a1-h8 diagonal to 0..27
+	mapKK         [10][64]int
+	mapPawns      [64]int // a2-h7 to 0..47, toward the edges and the first rank is higher
+	binomial      [TBPIECES][64]uint64
+	leadPawnIdx   [TBPIECES][64]uint64
+	leadPawnsSize [TBPIECES][4]uint64
+)
+
+func init() {
+	code := 0
+	for s := 0; s < 64; s++ {
+		if offA1H8(s) < 0 {
+			mapB1H1H7[s] = code
+			code++
+		}
+	}
+
+	var diagonal []int
+	code = 0
+	for s := 0; s <= 27; s++ {
+		if offA1H8(s) < 0 && s&7 <= 3 {
+			mapA1D1D4[s] = code
+			code++
+		} else if offA1H8(s) == 0 && s&7 <= 3 {
+			diagonal = append(diagonal, s)
+		}
+	}
+	for _, s := range diagonal {
+		mapA1D1D4[s] = code
+		code++
+	}
+
+	// the first king in the triangle, the second not next to it, and not
+	// above the diagonal if the first is on it. Both on the diagonal go last
+	var bothOnDiagonal [][2]int
+	code = 0
+	for i := 0; i < 10; i++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			// squares out of the triangle are 0 too, b1 is the real 0
+			if mapA1D1D4[s1] != i || (i == 0 && s1 != 1) {
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				switch {
+				case distance(s1, s2) <= 1:
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, [2]int{i, s2})
+				default:
+					mapKK[i][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		mapKK[p[0]][p[1]] = code
+		code++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < TBPIECES && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for count := 1; count < TBPIECES-1; count++ {
+		for file := 0; file < 4; file++ {
+			idx := uint64(0)
+			for rank := 1; rank <= 6; rank++ {
+				s := 8*rank + file
+				if count == 1 {
+					mapPawns[s] = available
+					mapPawns[s^7] = available - 1
+					available -= 2
+				}
+				leadPawnIdx[count][s] = idx
+				idx += binomial[count-1][mapPawns[s]]
+			}
+			leadPawnsSize[count][file] = idx
+		}
+	}
+}
+
+// offA1H8 is 0 on the a1-h8 diagonal, negative below it
+func offA1H8(s int) int {
+	return s>>3 - s&7
+}
+
+func distance(a, b int) int {
+	return max(abs(a>>3-b>>3), abs(a&7-b&7))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func flipDiagonal(s int) int {
+	return (s>>3 | s<<3) & 63
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pieceCodes maps the BoardState indexes to the piece codes of the files
+var pieceCodes = [13]byte{
+	chessboard.WPAWN: 1, chessboard.WKNIGHT: 2, chessboard.WBISHOP: 3, chessboard.WROOK: 4, chessboard.WQUEEN: 5, chessboard.WKING: 6,
+	chessboard.BPAWN: 9, chessboard.BKNIGHT: 10, chessboard.BBISHOP: 11, chessboard.BROOK: 12, chessboard.BQUEEN: 13, chessboard.BKING: 14,
+}
+
+// index finds the compressed table of the position on c and its index in
+// it. changeSide is true if c has the side to move a DTZ table doesn't store
+func (t *table) index(c *chessboard.Chessboard) (d *pairsData, file int, idx uint64, changeSide bool) {
+	// the table may be stored with the colors the other way around. When both
+	// sides have the same pieces only white to move is stored
+	flip := material(c) != t.name || (t.symmetric && !c.WhiteToMove)
+	flipColor, flipSquares := byte(0), 0
+	if flip {
+		flipColor, flipSquares = 8, 56
+	}
+	stm := b2i(flip == c.WhiteToMove)
+
+	var squares [TBPIECES]int
+	var pieces [TBPIECES]byte
+	size := 0
+	leadPawns := uint64(0)
+	if t.hasPawns {
+		// the leading pawns come first in every table of the file
+		leadPawns = c.BoardState[chessboard.WPAWN]
+		if t.items[0][0].pieces[0]^flipColor == 9 {
+			leadPawns = c.BoardState[chessboard.BPAWN]
+		}
+		for b := leadPawns; b != 0; b &= b - 1 {
+			squares[size] = bits.TrailingZeros64(b) ^ flipSquares
+			size++
+		}
+		lead := 0
+		for i := 1; i < size; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+		file = squares[0] & 7
+		if file > 3 {
+			file = (squares[0] ^ 7) & 7
+		}
+	}
+	leadCount := size
+
+	if t.dtz {
+		flags := t.items[0][file].flags
+		if int(flags&stmFlag) != stm && !(t.symmetric && !t.hasPawns) {
+			return nil, file, 0, true
+		}
+	}
+
+	for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+		for b := c.BoardState[piece] &^ leadPawns; b != 0; b &= b - 1 {
+			squares[size] = bits.TrailingZeros64(b) ^ flipSquares
+			pieces[size] = pieceCodes[piece] ^ flipColor
+			size++
+		}
+	}
+
+	d = t.items[stm%t.sides()][file]
+
+	// put the pieces in the order of the table
+	for i := leadCount; i < size-1; i++ {
+		for j := i; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if t.hasPawns {
+		idx = leadPawnIdx[leadCount][squares[0]]
+		others := squares[1:leadCount]
+		sort.SliceStable(others, func(i, j int) bool { return mapPawns[others[i]] < mapPawns[others[j]] })
+		for i := 1; i < leadCount; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		if squares[0]>>3 > 3 {
+			for i := 0; i < size; i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			off := offA1H8(squares[i])
+			if off == 0 {
+				continue
+			}
+			if off > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = flipDiagonal(squares[j])
+				}
+			}
+			break
+		}
+
+		if t.hasUniquePieces {
+			s0, s1, s2 := squares[0], squares[1], squares[2]
+			adjust1 := b2i(s1 > s0)
+			adjust2 := b2i(s2 > s0) + b2i(s2 > s1)
+			switch {
+			case offA1H8(s0) != 0:
+				idx = uint64((mapA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2)
+			case offA1H8(s1) != 0:
+				idx = uint64((6*63+(s0>>3)*28+mapB1H1H7[s1])*62 + s2 - adjust2)
+			case offA1H8(s2) != 0:
+				idx = uint64(6*63*62 + 4*28*62 + (s0>>3)*7*28 + (s1>>3-adjust1)*28 + mapB1H1H7[s2])
+			default:
+				idx = uint64(6*63*62 + 4*28*62 + 4*7*28 + (s0>>3)*7*6 + (s1>>3-adjust1)*6 + s2>>3 - adjust2)
+			}
+		} else {
+			idx = uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	// the other groups, each one a combination of the squares left
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		n := uint64(0)
+		for i, s := range group {
+			adjust := 0
+			for _, previous := range squares[:start] {
+				adjust += b2i(s > previous)
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][s-adjust]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return d, file, idx, false
+}
+
+// setGroups splits the pieces of d in groups of identical pieces, and finds
+// the factor of every group in the index. order gives the place of the
+// leading group and of the other side's pawns among the factors
+func (t *table) setGroups(d *pairsData, order [2]int, file int) {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+	n := 0
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	if pp {
+		next = 2
+	}
+	free := 64 - d.groupLen[0]
+	if pp {
+		free -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= leadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][free]
+			free -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
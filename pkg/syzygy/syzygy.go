@@ -0,0 +1,403 @@
+package syzygy
+
+// Syzygy endgame tablebases: WDL and DTZ probing of the .rtbw and .rtbz
+// files, ported from the reference prober (Fathom, Stockfish).
+// WDL tables tell if a position is won, drawn or lost, counting the 50 move
+// rule. DTZ tables give the distance to the next capture or pawn move, in
+// plies, on the way to that result
+
+import (
+	"errors"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// TBPIECES is the largest number of pieces, kings included, of a Syzygy table
+const TBPIECES = 7
+
+// Results of a position for the side to move. A cursed win is a win that
+// the 50 move rule makes a draw, a blessed loss a loss it saves
+const (
+	LOSS = iota - 2
+	BLESSEDLOSS
+	DRAW
+	CURSEDWIN
+	WIN
+)
+
+const (
+	wdlExtension = ".rtbw"
+	dtzExtension = ".rtbz"
+)
+
+// Tablebase probes the tables of some directories. Tables are opened when
+// they are first needed. A Tablebase is safe for concurrent use
+type Tablebase struct {
+	mu        sync.Mutex
+	paths     map[string]string // file names, eg "KQvK.rtbw", to their path
+	tables    map[string]*table
+	maxPieces int
+}
+
+// New finds the tables in path, a directory or several separated like in
+// PATH. The first table found for a material is used
+func New(path string) (*Tablebase, error) {
+	tb := &Tablebase{paths: map[string]string{}, tables: map[string]*table{}}
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			extension := filepath.Ext(name)
+			if extension != wdlExtension && extension != dtzExtension || !validName(strings.TrimSuffix(name, extension)) {
+				continue
+			}
+			if _, ok := tb.paths[name]; !ok {
+				tb.paths[name] = filepath.Join(dir, name)
+			}
+			if extension == wdlExtension {
+				tb.maxPieces = max(tb.maxPieces, len(name)-len(extension)-1)
+			}
+		}
+	}
+	return tb, nil
+}
+
+// validName reports if name is a material like "KRPvKR"
+func validName(name string) bool {
+	white, black, found := strings.Cut(name, "v")
+	if !found || len(white)+len(black) > TBPIECES {
+		return false
+	}
+	for _, side := range []string{white, black} {
+		if strings.Count(side, "K") != 1 || side[0] != 'K' || strings.Trim(side, "KQRBNP") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxPieces is the largest number of pieces of the WDL tables found, 0 if none
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// Close closes the files of the tables opened so far
+func (tb *Tablebase) Close() error {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	var err error
+	for name, t := range tb.tables {
+		if closeErr := t.file.Close(); err == nil {
+			err = closeErr
+		}
+		delete(tb.tables, name)
+	}
+	return err
+}
+
+// material names the pieces of c, white first, like the file names: "KQvKR"
+func material(c *chessboard.Chessboard) string {
+	sides := [2]string{}
+	for color, king := range []int{chessboard.WKING, chessboard.BKING} {
+		// the kings come first, then the pieces from the queen down to the pawns
+		for piece := king; piece < king+6; piece++ {
+			sides[color] += strings.Repeat(string("KQRBNP"[piece-king]), bits.OnesCount64(c.BoardState[piece]))
+		}
+	}
+	return sides[0] + "v" + sides[1]
+}
+
+// open returns the table of the material of c, which may be stored with
+// the colors the other way around
+func (tb *Tablebase) open(c *chessboard.Chessboard, dtz bool) (*table, error) {
+	name := material(c)
+	white, black, _ := strings.Cut(name, "v")
+	extension := wdlExtension
+	if dtz {
+		extension = dtzExtension
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	for _, fileName := range []string{name, black + "v" + white} {
+		if t, ok := tb.tables[fileName+extension]; ok {
+			return t, nil
+		}
+		path, ok := tb.paths[fileName+extension]
+		if !ok {
+			continue
+		}
+		t, err := openTable(path, fileName, dtz)
+		if err != nil {
+			return nil, err
+		}
+		tb.tables[fileName+extension] = t
+		return t, nil
+	}
+	return nil, errors.New("no Syzygy table for " + name)
+}
+
+// probeable checks that the position on c can be in a table
+func (tb *Tablebase) probeable(c *chessboard.Chessboard) error {
+	if c.Variant != nil && c.Variant.Name() != (chessboard.Standard{}).Name() {
+		return errors.New("Syzygy tables are for standard chess")
+	}
+	if c.WhiteKingCastle || c.WhiteQueenCastle || c.BlackKingCastle || c.BlackQueenCastle {
+		return errors.New("positions with castling rights are not in the tables")
+	}
+	if pieces := len(material(c)) - 1; pieces > tb.maxPieces {
+		return errors.New("too many pieces to probe: " + material(c))
+	}
+	return nil
+}
+
+// ProbeWDL returns the result of the position on c for the side to move,
+// LOSS to WIN
+func (tb *Tablebase) ProbeWDL(c *chessboard.Chessboard) (int, error) {
+	if err := tb.probeable(c); err != nil {
+		return 0, err
+	}
+	wdl, _, err := tb.search(c, false)
+	return wdl, err
+}
+
+// ProbeDTZ returns the distance in plies to the next capture or pawn move
+// that keeps the result, or to mate. It is positive when the side to move
+// wins, negative when it loses, 0 for draws and -1 when mated. Cursed wins
+// and blessed losses are 100 plies further than the rule allows.
+// The distance can be one ply longer than the real one in tables that store
+// moves instead of plies
+func (tb *Tablebase) ProbeDTZ(c *chessboard.Chessboard) (int, error) {
+	if err := tb.probeable(c); err != nil {
+		return 0, err
+	}
+	return tb.probeDTZ(c)
+}
+
+func (tb *Tablebase) probeDTZ(c *chessboard.Chessboard) (int, error) {
+	wdl, zeroingBest, err := tb.search(c, true)
+	if err != nil || wdl == DRAW {
+		return 0, err
+	}
+	// the table doesn't store positions where the best move zeroes
+	if zeroingBest {
+		return beforeZeroing(wdl), nil
+	}
+
+	dtz, changeSide, err := tb.probeTable(c, true, wdl)
+	if err != nil {
+		return 0, err
+	}
+	if !changeSide {
+		if wdl == CURSEDWIN || wdl == BLESSEDLOSS {
+			dtz += 100
+		}
+		return dtz * sign(wdl), nil
+	}
+
+	// the table stores the other side to move, look one ply ahead for the
+	// move that wins fastest or loses slowest
+	best := 0xffff
+	for _, m := range c.GetMoveList() {
+		capture, pawn := kind(c, m)
+		zeroing := capture || pawn
+		next := play(c, m)
+		if zeroing {
+			childWDL, _, err := tb.search(&next, false)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -beforeZeroing(childWDL)
+		} else {
+			childDTZ, err := tb.probeDTZ(&next)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -childDTZ
+		}
+		if dtz == 1 && next.InCheck() && len(next.GetMoveList()) == 0 {
+			// mate
+			best = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < best && sign(dtz) == sign(wdl) {
+			best = dtz
+		}
+	}
+	if best == 0xffff {
+		return -1, nil
+	}
+	return best, nil
+}
+
+// ProbeRoot returns the moves of c, in UCI, that keep the result of the
+// position and the result, LOSS to WIN. Of the winning moves only those that
+// reach the next capture or pawn move, or mate, soonest are kept, of the
+// losing moves those that put it off longest. A search restricted to them
+// can't spoil a won game or lose a drawn one
+func (tb *Tablebase) ProbeRoot(c *chessboard.Chessboard) ([]string, int, error) {
+	if err := tb.probeable(c); err != nil {
+		return nil, 0, err
+	}
+	var moves []string
+	bestWDL, bestDTZ := LOSS-1, 0
+	for _, m := range c.GetMoveList() {
+		next := play(c, m)
+		childWDL, _, err := tb.search(&next, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		wdl, dtz := -childWDL, 0
+		if capture, pawn := kind(c, m); capture || pawn {
+			dtz = beforeZeroing(wdl)
+		} else if wdl != DRAW {
+			childDTZ, err := tb.probeDTZ(&next)
+			if err != nil {
+				return nil, 0, err
+			}
+			dtz = -childDTZ
+			if dtz != 1 || !next.InCheck() || len(next.GetMoveList()) != 0 {
+				// one ply more, unless it mates
+				dtz += sign(dtz)
+			}
+		}
+		switch {
+		case wdl > bestWDL || wdl == bestWDL && dtz < bestDTZ:
+			// for a loss the smallest DTZ is the longest
+			moves, bestWDL, bestDTZ = []string{m.String()}, wdl, dtz
+		case wdl == bestWDL && dtz == bestDTZ:
+			moves = append(moves, m.String())
+		}
+	}
+	if moves == nil {
+		return nil, 0, errors.New("no legal moves")
+	}
+	return moves, bestWDL, nil
+}
+
+// beforeZeroing is the DTZ of a position whose best move zeroes
+func beforeZeroing(wdl int) int {
+	switch wdl {
+	case WIN:
+		return 1
+	case CURSEDWIN:
+		return 101
+	case BLESSEDLOSS:
+		return -101
+	case LOSS:
+		return -1
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// search finds the result of c from the table and the captures, and with
+// zeroing from the pawn moves too. The tables store any value that
+// compresses well for positions that a capture wins, and maybe a loss for
+// positions that a capture draws. zeroingBest is true when the best move is
+// a capture, or a pawn move, that the DTZ table doesn't count
+func (tb *Tablebase) search(c *chessboard.Chessboard, zeroing bool) (wdl int, zeroingBest bool, err error) {
+	moves := c.GetMoveList()
+	best := LOSS
+	searched := 0
+	for _, m := range moves {
+		capture, pawn := kind(c, m)
+		if !capture && (!zeroing || !pawn) {
+			continue
+		}
+		searched++
+		next := play(c, m)
+		value, _, err := tb.search(&next, false)
+		if err != nil {
+			return 0, false, err
+		}
+		if -value > best {
+			best = -value
+			if best >= WIN {
+				return best, true, nil
+			}
+		}
+	}
+
+	// when every move was searched, the table is wrong for positions with
+	// an en passant capture, and isn't needed
+	allSearched := searched > 0 && searched == len(moves)
+	value := best
+	if !allSearched {
+		value, _, err = tb.probeTable(c, false, DRAW)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if best >= value {
+		return best, best > DRAW || allSearched, nil
+	}
+	return value, false, nil
+}
+
+// probeTable looks c up in its WDL table, or its DTZ table for a position
+// whose result is wdl
+func (tb *Tablebase) probeTable(c *chessboard.Chessboard, dtz bool, wdl int) (value int, changeSide bool, err error) {
+	if material(c) == "KvK" {
+		return DRAW, false, nil
+	}
+	t, err := tb.open(c, dtz)
+	if err != nil {
+		return 0, false, err
+	}
+	d, file, idx, changeSide := t.index(c)
+	if changeSide {
+		return 0, true, nil
+	}
+	value, err = t.decompress(d, idx)
+	if err != nil {
+		return 0, false, errors.New(t.name + ": " + err.Error())
+	}
+	if !dtz {
+		return value - 2, false, nil
+	}
+	value, err = t.dtzScore(file, value, wdl)
+	return value, false, err
+}
+
+// kind tells if the legal move m captures, and if it moves a pawn
+func kind(c *chessboard.Chessboard, m chessboard.Move) (capture, pawn bool) {
+	uci := m.String()
+	from := int(uci[1]-'1')*8 + int(uci[0]-'a')
+	to := int(uci[3]-'1')*8 + int(uci[2]-'a')
+	occupied := uint64(0)
+	for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+		occupied |= c.BoardState[piece]
+	}
+	pawn = (c.BoardState[chessboard.WPAWN]|c.BoardState[chessboard.BPAWN])&(1<<from) != 0
+	// a pawn that changes files takes, en passant too
+	capture = occupied&(1<<to) != 0 || (pawn && from%8 != to%8)
+	return capture, pawn
+}
+
+// play returns the board after the legal move m
+func play(c *chessboard.Chessboard, m chessboard.Move) chessboard.Chessboard {
+	next := *c
+	next.Moves, next.Clock = nil, nil
+	next.MakeUCIMove(m.String())
+	return next
+}
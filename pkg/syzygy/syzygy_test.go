@@ -0,0 +1,702 @@
+package syzygy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+	"github.com/kahnaisehC/chessboard/pkg/tablebase"
+)
+
+// positions calls visit with every legal position of a material, eg "KQvK"
+func positions(name string, visit func(c *chessboard.Chessboard)) {
+	var pieces []int
+	for color, side := range strings.Split(name, "v") {
+		for _, letter := range side {
+			pieces = append(pieces, strings.IndexRune("KQRBNP", letter)+chessboard.WKING+6*color)
+		}
+	}
+	squares := make([]int, len(pieces))
+	var place func(i int, occupied uint64)
+	place = func(i int, occupied uint64) {
+		if i == len(pieces) {
+			for _, whiteToMove := range []bool{true, false} {
+				c := chessboard.Chessboard{WhiteToMove: whiteToMove, FullmoveCounter: 1}
+				for j, piece := range pieces {
+					c.BoardState[piece] |= 1 << squares[j]
+				}
+				// the side that just moved is not in check
+				if !c.SquareIsThreatened(c.WhiteToMove, c.GetKingPosition(!c.WhiteToMove)) {
+					visit(&c)
+				}
+			}
+			return
+		}
+		pawn := pieces[i] == chessboard.WPAWN || pieces[i] == chessboard.BPAWN
+		for s := 0; s < 64; s++ {
+			if occupied&(1<<s) == 0 && (!pawn || (s >= 8 && s < 56)) {
+				squares[i] = s
+				place(i+1, occupied|1<<s)
+			}
+		}
+	}
+	place(0, 0)
+}
+
+// mirror swaps the colors of c
+func mirror(c *chessboard.Chessboard) chessboard.Chessboard {
+	m := chessboard.Chessboard{WhiteToMove: !c.WhiteToMove, FullmoveCounter: 1}
+	for piece := chessboard.WKING; piece <= chessboard.WPAWN; piece++ {
+		m.BoardState[piece] = bits.ReverseBytes64(c.BoardState[piece+6])
+		m.BoardState[piece+6] = bits.ReverseBytes64(c.BoardState[piece])
+	}
+	return m
+}
+
+// spec describes a table for writeTable
+type spec struct {
+	name   string
+	dtz    bool
+	stm    byte // DTZ: stmFlag to store black to move
+	mapped bool // DTZ: store the distances in maps
+	// WDL: LOSS to WIN, DTZ: plies, negative for losses
+	value func(c *chessboard.Chessboard) int
+}
+
+// writeTable writes a table in the Syzygy format. It uses the package's
+// indexes, and fails if two positions with different values share one
+func writeTable(t *testing.T, dir string, s spec) {
+	tbl := newTable(s.name, s.dtz)
+	white, black, _ := strings.Cut(s.name, "v")
+	codes := map[byte]byte{'P': 1, 'N': 2, 'B': 3, 'R': 4, 'Q': 5, 'K': 6}
+
+	// the leading pawns, the other side's pawns, then the other pieces. The
+	// kings lead without pawns, with a unique piece if there is one
+	var pieces, rest []byte
+	lead := byte(0)
+	if wp, bp := strings.Count(white, "P"), strings.Count(black, "P"); bp > 0 && (wp == 0 || bp < wp) {
+		lead = 8
+	}
+	for color, side := range []string{white, black} {
+		for _, letter := range []byte(side) {
+			if letter != 'P' && (letter != 'K' || tbl.hasPawns) {
+				rest = append(rest, codes[letter]|byte(8*color))
+			}
+		}
+	}
+	if tbl.hasPawns {
+		pieces = append(bytes.Repeat([]byte{1 | lead}, tbl.pawnCount[0]), bytes.Repeat([]byte{1 | lead ^ 8}, tbl.pawnCount[1])...)
+	} else {
+		pieces = []byte{6, 14}
+		for i, code := range rest {
+			if tbl.hasUniquePieces && bytes.Count(rest, []byte{code}) == 1 {
+				rest = append([]byte{code}, append(rest[:i:i], rest[i+1:]...)...)
+				break
+			}
+		}
+	}
+	pieces = append(pieces, rest...)
+
+	pp := tbl.hasPawns && tbl.pawnCount[1] > 0
+	order := [2]int{0, 0xf}
+	if pp {
+		order[1] = 1
+	}
+	values := map[*pairsData][]int{}
+	const unset = 1 << 20
+	for file := 0; file < tbl.files(); file++ {
+		for side := 0; side < tbl.sides(); side++ {
+			d := &pairsData{}
+			copy(d.pieces[:], pieces)
+			if s.dtz {
+				d.flags = s.stm | winPliesFlag | lossPliesFlag
+				if s.mapped {
+					d.flags |= mappedFlag
+				}
+			}
+			tbl.setGroups(d, order, file)
+			tbl.items[side][file] = d
+			n := 0
+			for d.groupLen[n] != 0 {
+				n++
+			}
+			values[d] = make([]int, d.groupIdx[n])
+			for i := range values[d] {
+				values[d][i] = unset
+			}
+		}
+	}
+
+	positions(s.name, func(c *chessboard.Chessboard) {
+		d, _, idx, changeSide := tbl.index(c)
+		if changeSide {
+			return
+		}
+		value := s.value(c)
+		if idx >= uint64(len(values[d])) {
+			t.Fatalf("%s: index %d of %s out of the table", s.name, idx, c.GetFEN())
+		}
+		if stored := values[d][idx]; stored != unset && stored != value {
+			t.Fatalf("%s: %s has index %d, with another value", s.name, c.GetFEN(), idx)
+		}
+		values[d][idx] = value
+	})
+
+	// the stored values: WDL + 2, or plies - 1 through the maps
+	var maps [4][4][]int
+	for file := 0; file < tbl.files(); file++ {
+		for side := 0; side < tbl.sides(); side++ {
+			d := tbl.items[side][file]
+			for i, value := range values[d] {
+				switch {
+				case !s.dtz && value == unset:
+					values[d][i] = DRAW + 2
+				case !s.dtz:
+					values[d][i] = value + 2
+				case value == unset || value == 0:
+					values[d][i] = 0
+				case s.mapped:
+					m := &maps[file][wdlMap[2*sign(value)+2]]
+					j := sort.SearchInts(*m, abs(value)-1)
+					if j == len(*m) || (*m)[j] != abs(value)-1 {
+						*m = append((*m)[:j], append([]int{abs(value) - 1}, (*m)[j:]...)...)
+					}
+					values[d][i] = value // mapped below, once the maps are complete
+				default:
+					values[d][i] = abs(value) - 1
+				}
+			}
+			if s.mapped {
+				for i, value := range values[d] {
+					if value != 0 {
+						values[d][i] = sort.SearchInts(maps[file][wdlMap[2*sign(value)+2]], abs(value)-1)
+					}
+				}
+			}
+		}
+	}
+
+	flags := byte(0)
+	if !tbl.symmetric {
+		flags |= splitFlag
+	}
+	if tbl.hasPawns {
+		flags |= hasPawnsFlag
+	}
+	data := append([]byte(nil), wdlMagic...)
+	if s.dtz {
+		data = append([]byte(nil), dtzMagic...)
+	}
+	data = append(data, flags)
+	for file := 0; file < tbl.files(); file++ {
+		data = append(data, byte(order[0]|order[0]<<4))
+		if pp {
+			data = append(data, byte(order[1]|order[1]<<4))
+		}
+		for _, piece := range pieces {
+			data = append(data, piece|piece<<4)
+		}
+	}
+	align := func(n int) {
+		for len(data)%n != 0 {
+			data = append(data, 0)
+		}
+	}
+	align(2)
+
+	var sections []compressed
+	for file := 0; file < tbl.files(); file++ {
+		for side := 0; side < tbl.sides(); side++ {
+			d := tbl.items[side][file]
+			section := compress(values[d], d.flags)
+			data = append(data, section.sizes...)
+			sections = append(sections, section)
+		}
+	}
+	if s.dtz {
+		for file := 0; file < tbl.files(); file++ {
+			if s.mapped {
+				for _, m := range maps[file] {
+					data = append(data, byte(len(m)))
+					for _, value := range m {
+						data = append(data, byte(value))
+					}
+				}
+			}
+		}
+		align(2)
+	}
+	for _, section := range sections {
+		data = append(data, section.sparse...)
+	}
+	for _, section := range sections {
+		data = append(data, section.lengths...)
+	}
+	for _, section := range sections {
+		align(64)
+		data = append(data, section.blocks...)
+	}
+
+	extension := wdlExtension
+	if s.dtz {
+		extension = dtzExtension
+	}
+	if err := os.WriteFile(filepath.Join(dir, s.name+extension), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type compressed struct {
+	sizes, sparse, lengths, blocks []byte
+}
+
+const (
+	testBlockSize = 64
+	testSpan      = 64
+)
+
+// compress codes values with symbols for one, two and four times a value
+func compress(values []int, flags byte) compressed {
+	single := true
+	for _, value := range values {
+		single = single && value == values[0]
+	}
+	if single {
+		return compressed{sizes: []byte{flags | singleValueFlag, byte(values[0])}}
+	}
+
+	type symbol struct{ value, count int }
+	var stream []symbol
+	frequency := map[symbol]int{}
+	for i := 0; i < len(values); {
+		run := 1
+		for run < 4 && i+run < len(values) && values[i+run] == values[i] {
+			run++
+		}
+		if run == 3 {
+			run = 2
+		}
+		stream = append(stream, symbol{values[i], run})
+		frequency[symbol{values[i], run}]++
+		i += run
+	}
+	var symbols []symbol
+	for _, value := range values {
+		if _, ok := frequency[symbol{value, 0}]; !ok {
+			frequency[symbol{value, 0}] = 0
+			symbols = append(symbols, symbol{value, 1}, symbol{value, 2}, symbol{value, 4})
+		}
+	}
+
+	// Huffman code lengths, then the ids: the longest codes first
+	length := map[symbol]int{}
+	type node struct {
+		weight  int
+		symbols []symbol
+	}
+	var nodes []node
+	for _, sym := range symbols {
+		nodes = append(nodes, node{frequency[sym] + 1, []symbol{sym}})
+	}
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+		merged := node{nodes[0].weight + nodes[1].weight, append(append([]symbol(nil), nodes[0].symbols...), nodes[1].symbols...)}
+		for _, sym := range merged.symbols {
+			length[sym]++
+		}
+		nodes = append([]node{merged}, nodes[2:]...)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return length[symbols[i]] > length[symbols[j]] })
+	id := map[symbol]int{}
+	count := map[int]int{}
+	for i, sym := range symbols {
+		id[sym] = i
+		count[length[sym]]++
+	}
+	maxLen, minLen := length[symbols[0]], length[symbols[len(symbols)-1]]
+
+	lowest := map[int]int{}
+	base := map[int]int{maxLen: 0}
+	for l, longer := maxLen, 0; l >= minLen; l-- {
+		lowest[l] = longer
+		longer += count[l]
+		if l < maxLen {
+			base[l] = (base[l+1] + count[l+1]) / 2
+		}
+	}
+
+	var c compressed
+	var lengths []int
+	block := make([]byte, testBlockSize)
+	used, inBlock := 0, 0
+	flush := func() {
+		c.blocks = append(c.blocks, block...)
+		lengths = append(lengths, inBlock-1)
+		block = make([]byte, testBlockSize)
+		used, inBlock = 0, 0
+	}
+	for _, sym := range stream {
+		l := length[sym]
+		if used+l > 8*testBlockSize {
+			flush()
+		}
+		code := base[l] + id[sym] - lowest[l]
+		for bit := l - 1; bit >= 0; bit-- {
+			if code>>bit&1 != 0 {
+				block[used/8] |= 0x80 >> (used % 8)
+			}
+			used++
+		}
+		inBlock += sym.count
+	}
+	flush()
+
+	// the block and offset of the value in the middle of every span
+	starts := []int{0}
+	for _, l := range lengths {
+		starts = append(starts, starts[len(starts)-1]+l+1)
+	}
+	for k := 0; k*testSpan < len(values); k++ {
+		middle := k*testSpan + testSpan/2
+		b := min(sort.SearchInts(starts, middle+1)-1, len(lengths)-1)
+		c.sparse = binary.LittleEndian.AppendUint32(c.sparse, uint32(b))
+		c.sparse = binary.LittleEndian.AppendUint16(c.sparse, uint16(middle-starts[b]))
+	}
+	for _, l := range lengths {
+		c.lengths = binary.LittleEndian.AppendUint16(c.lengths, uint16(l))
+	}
+
+	c.sizes = []byte{flags, 6, 6, 0}
+	c.sizes = binary.LittleEndian.AppendUint32(c.sizes, uint32(len(lengths)))
+	c.sizes = append(c.sizes, byte(maxLen), byte(minLen))
+	for l := minLen; l <= maxLen; l++ {
+		c.sizes = binary.LittleEndian.AppendUint16(c.sizes, uint16(lowest[l]))
+	}
+	c.sizes = binary.LittleEndian.AppendUint16(c.sizes, uint16(len(symbols)))
+	for _, sym := range symbols {
+		left, right := sym.value, 0xfff
+		if sym.count > 1 {
+			left = id[symbol{sym.value, sym.count / 2}]
+			right = left
+		}
+		c.sizes = append(c.sizes, byte(left), byte(left>>8&0xf|right<<4), byte(right>>4))
+	}
+	if len(symbols)%2 == 1 {
+		c.sizes = append(c.sizes, 0)
+	}
+	return c
+}
+
+// chebyshev is the distance of a king between the squares of two pieces
+func chebyshev(a, b uint64) int {
+	from, to := bits.TrailingZeros64(a), bits.TrailingZeros64(b)
+	return max(abs(from%8-to%8), abs(from/8-to/8))
+}
+
+// captures reports if the side to move can capture something
+func captures(c *chessboard.Chessboard) bool {
+	for _, m := range c.GetMoveList() {
+		if capture, _ := kind(c, m); capture {
+			return true
+		}
+	}
+	return false
+}
+
+// wdlTruth is the result of KQvK, KRvK and KNvK, and the other way around:
+// the side with the piece wins unless it is stalemated, or the piece hangs,
+// the knight never wins
+func wdlTruth(c *chessboard.Chessboard) int {
+	if c.BoardState[chessboard.WKNIGHT]|c.BoardState[chessboard.BKNIGHT] != 0 {
+		return DRAW
+	}
+	stronger := c.BoardState[chessboard.WQUEEN]|c.BoardState[chessboard.WROOK] != 0
+	if c.WhiteToMove == stronger {
+		return WIN
+	}
+	moves := c.GetMoveList()
+	if len(moves) == 0 && !c.InCheck() || captures(c) {
+		return DRAW
+	}
+	return LOSS
+}
+
+// made up values, the same for positions the symmetries of a table give
+// the same index, to check that tables are read right
+func madeUpWDL(c *chessboard.Chessboard) int {
+	pawn := c.BoardState[chessboard.WPAWN]
+	return (chebyshev(c.BoardState[chessboard.WKING], c.BoardState[chessboard.BKING])+bits.TrailingZeros64(pawn)/8)%5 - 2
+}
+
+func madeUpDTZ(c *chessboard.Chessboard) int {
+	piece := c.BoardState[chessboard.WQUEEN] | c.BoardState[chessboard.WROOK]
+	plies := 1 + chebyshev(c.BoardState[chessboard.WKING], c.BoardState[chessboard.BKING]) + 8*chebyshev(c.BoardState[chessboard.BKING], piece)
+	switch wdlTruth(c) {
+	case WIN:
+		return plies
+	case LOSS:
+		return -plies
+	}
+	return 0
+}
+
+// known are results any KQvK, KRvK, KPvK and KNvK tables give
+var known = []struct {
+	FEN string
+	wdl int
+	dtz int
+}{
+	{"7k/8/6K1/8/8/8/Q7/8 w - - 0 1", WIN, 1},
+	{"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", LOSS, -1},
+	// stalemate, and a queen that can be taken
+	{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", DRAW, 0},
+	{"8/8/8/8/8/8/1Q6/k6K b - - 0 1", DRAW, 0},
+	// the colors the other way around, from the same table
+	{"8/q7/8/8/8/6k1/8/7K b - - 0 1", WIN, 1},
+	{"8/8/8/8/8/6k1/6q1/7K w - - 0 1", LOSS, -1},
+	{"8/8/8/8/8/8/6R1/k5K1 b - - 0 1", LOSS, 0},
+	{"8/8/8/3n4/8/8/8/K6k w - - 0 1", DRAW, 0},
+	// KPvK: the king on the sixth row in front of the pawn wins, not with a rook pawn
+	{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", LOSS, 0},
+	{"k7/8/K7/P7/8/8/8/8 w - - 0 1", DRAW, 0},
+}
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"KQvK", "KRvK", "KNvK"} {
+		writeTable(t, dir, spec{name: name, value: wdlTruth})
+	}
+	writeTable(t, dir, spec{name: "KPvK", value: madeUpWDL})
+	writeTable(t, dir, spec{name: "KQvK", dtz: true, mapped: true, value: madeUpDTZ})
+	writeTable(t, dir, spec{name: "KRvK", dtz: true, stm: stmFlag, value: madeUpDTZ})
+
+	tb, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	if tb.MaxPieces() != 3 {
+		t.Errorf("MaxPieces() = %d, should be 3", tb.MaxPieces())
+	}
+
+	for _, test := range known {
+		c := chessboard.CreateChessboard(test.FEN)
+		if strings.Contains(test.FEN, "P") {
+			continue // the KPvK table is made up
+		}
+		if got, err := tb.ProbeWDL(&c); err != nil || got != test.wdl {
+			t.Errorf("ProbeWDL(%s) = %d, %v, should be %d", test.FEN, got, err, test.wdl)
+		}
+	}
+
+	// samples of every table, with the colors either way
+	sampled := 0
+	sample := func() bool {
+		sampled++
+		return sampled%11 == 0
+	}
+	for _, name := range []string{"KQvK", "KRvK", "KNvK", "KvKQ", "KvKR", "KvKN"} {
+		positions(name, func(c *chessboard.Chessboard) {
+			if !sample() {
+				return
+			}
+			if got, err := tb.ProbeWDL(c); err != nil || got != wdlTruth(c) {
+				t.Fatalf("ProbeWDL(%s) = %d, %v, should be %d", c.GetFEN(), got, err, wdlTruth(c))
+			}
+		})
+	}
+	// the made up values where no capture changes them, the table stores
+	// white to move, and black to move with the roles swapped
+	for _, name := range []string{"KPvK", "KvKP"} {
+		positions(name, func(c *chessboard.Chessboard) {
+			if !sample() || captures(c) {
+				return
+			}
+			truth := c
+			if name[1] == 'v' {
+				m := mirror(c)
+				truth = &m
+			}
+			if got, err := tb.ProbeWDL(c); err != nil || got != madeUpWDL(truth) {
+				t.Fatalf("ProbeWDL(%s) = %d, %v, should be %d", c.GetFEN(), got, err, madeUpWDL(truth))
+			}
+		})
+	}
+	// the side the DTZ tables store: white to move in KQvK, black in KRvK
+	positions("KQvK", func(c *chessboard.Chessboard) {
+		if c.WhiteToMove && sample() {
+			if got, err := tb.ProbeDTZ(c); err != nil || got != madeUpDTZ(c) {
+				t.Fatalf("ProbeDTZ(%s) = %d, %v, should be %d", c.GetFEN(), got, err, madeUpDTZ(c))
+			}
+		}
+	})
+	positions("KRvK", func(c *chessboard.Chessboard) {
+		if !c.WhiteToMove && sample() && wdlTruth(c) == LOSS && len(c.GetMoveList()) > 0 {
+			if got, err := tb.ProbeDTZ(c); err != nil || got != madeUpDTZ(c) {
+				t.Fatalf("ProbeDTZ(%s) = %d, %v, should be %d", c.GetFEN(), got, err, madeUpDTZ(c))
+			}
+		}
+	})
+
+	// the mates, and the only move that doesn't lose
+	rootTests := []struct {
+		FEN   string
+		moves string
+		wdl   int
+	}{
+		{"7k/8/6K1/8/8/8/Q7/8 w - - 0 1", "a2a8", WIN},
+		{"8/8/8/8/8/8/1Q6/k6K b - - 0 1", "a1b2", DRAW},
+	}
+	for _, test := range rootTests {
+		c := chessboard.CreateChessboard(test.FEN)
+		moves, wdl, err := tb.ProbeRoot(&c)
+		sort.Strings(moves)
+		if err != nil || strings.Join(moves, " ") != test.moves || wdl != test.wdl {
+			t.Errorf("ProbeRoot(%s) = %v, %d, %v, should be %s, %d", test.FEN, moves, wdl, err, test.moves, test.wdl)
+		}
+	}
+
+	errorTests := []string{
+		// castling rights, too many pieces, no table
+		"r3k3/8/8/8/8/8/8/4K3 b q - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"4k3/8/8/8/8/8/8/2B1K3 w - - 0 1",
+		// the king must move, which the KPvK DTZ table would give
+		"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1",
+	}
+	for _, FEN := range errorTests[:3] {
+		c := chessboard.CreateChessboard(FEN)
+		if _, err := tb.ProbeWDL(&c); err == nil {
+			t.Errorf("ProbeWDL(%s) should fail", FEN)
+		}
+		if _, _, err := tb.ProbeRoot(&c); err == nil {
+			t.Errorf("ProbeRoot(%s) should fail", FEN)
+		}
+	}
+	for _, FEN := range errorTests {
+		c := chessboard.CreateChessboard(FEN)
+		if _, err := tb.ProbeDTZ(&c); err == nil {
+			t.Errorf("ProbeDTZ(%s) should fail", FEN)
+		}
+	}
+}
+
+// TestRealTables probes the tables of SYZYGY_PATH, eg the KQvK, KRvK, KPvK
+// and KNvK files of tablebase.lichess.ovh or syzygy-tables.info, which are
+// too big to ship with the tests
+func TestRealTables(t *testing.T) {
+	path := os.Getenv("SYZYGY_PATH")
+	if path == "" {
+		t.Skip("SYZYGY_PATH is not set")
+	}
+	tb, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	for _, test := range known {
+		c := chessboard.CreateChessboard(test.FEN)
+		got, err := tb.ProbeWDL(&c)
+		if err != nil && strings.HasPrefix(err.Error(), "no Syzygy table") {
+			continue
+		}
+		if err != nil || got != test.wdl {
+			t.Errorf("ProbeWDL(%s) = %d, %v, should be %d", test.FEN, got, err, test.wdl)
+		}
+		if test.dtz == 0 && test.wdl != DRAW {
+			continue
+		}
+		if got, err := tb.ProbeDTZ(&c); err != nil || got != test.dtz {
+			t.Errorf("ProbeDTZ(%s) = %d, %v, should be %d", test.FEN, got, err, test.dtz)
+		}
+	}
+}
+
+func TestAgainstDTM(t *testing.T) {
+	// real values, from the distance to mate tables. The two agree as there
+	// are no captures but of the last piece and no 50 move draws
+	dtm := tablebase.New("")
+	probe := func(c *chessboard.Chessboard) tablebase.Result {
+		result, err := dtm.Probe(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	wdl := func(c *chessboard.Chessboard) int {
+		return 2 * probe(c).WDL
+	}
+	dtz := func(c *chessboard.Chessboard) int {
+		switch result := probe(c); result.WDL {
+		case tablebase.WIN:
+			return result.Plies
+		case tablebase.LOSS:
+			return -max(1, result.Plies)
+		}
+		return 0
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"KQvK", "KRvK", "KPvK", "KNvK"} {
+		writeTable(t, dir, spec{name: name, value: wdl})
+	}
+	writeTable(t, dir, spec{name: "KQvK", dtz: true, mapped: true, value: dtz})
+	writeTable(t, dir, spec{name: "KRvK", dtz: true, stm: stmFlag, value: dtz})
+
+	tb, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	if tb.MaxPieces() != 3 {
+		t.Errorf("MaxPieces() = %d, should be 3", tb.MaxPieces())
+	}
+
+	for _, test := range known {
+		c := chessboard.CreateChessboard(test.FEN)
+		if got, err := tb.ProbeWDL(&c); err != nil || got != test.wdl {
+			t.Errorf("ProbeWDL(%s) = %d, %v, should be %d", test.FEN, got, err, test.wdl)
+		}
+		if test.dtz == 0 && test.wdl != DRAW {
+			continue
+		}
+		if got, err := tb.ProbeDTZ(&c); err != nil || got != test.dtz {
+			t.Errorf("ProbeDTZ(%s) = %d, %v, should be %d", test.FEN, got, err, test.dtz)
+		}
+	}
+	// the pawn runs, the DTZ table isn't needed
+	c := chessboard.CreateChessboard("7k/8/8/8/8/8/P7/K7 w - - 0 1")
+	if got, err := tb.ProbeDTZ(&c); err != nil || got != 1 {
+		t.Errorf("ProbeDTZ(%s) = %d, %v, should be 1", c.GetFEN(), got, err)
+	}
+
+	// samples of every table, with the colors either way
+	for _, name := range []string{"KQvK", "KRvK", "KPvK", "KNvK", "KvKQ", "KvKR", "KvKP"} {
+		sampled := 0
+		positions(name, func(c *chessboard.Chessboard) {
+			sampled++
+			if sampled%17 != 0 {
+				return
+			}
+			truth := c
+			if name[1] == 'v' {
+				m := mirror(c)
+				truth = &m
+			}
+			if got, err := tb.ProbeWDL(c); err != nil || got != wdl(truth) {
+				t.Fatalf("ProbeWDL(%s) = %d, %v, should be %d", c.GetFEN(), got, err, wdl(truth))
+			}
+			if strings.ContainsAny(name, "QR") && sampled%(17*7) == 0 {
+				if got, err := tb.ProbeDTZ(c); err != nil || got != dtz(truth) {
+					t.Fatalf("ProbeDTZ(%s) = %d, %v, should be %d", c.GetFEN(), got, err, dtz(truth))
+				}
+			}
+		})
+	}
+}
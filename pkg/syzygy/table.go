@@ -0,0 +1,473 @@
+package syzygy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+	file format, all numbers little endian but the compressed blocks:
+	magic, 4 bytes
+	flags: 1 the sides to move are stored apart (WDL only), 2 there are pawns
+	for every file of the leading pawn (a to d, or only one without pawns):
+		order of the leading group, and of the other side's pawns if both
+		sides have pawns, a nibble for each side to move
+		the piece codes in index order, a nibble for each side to move
+	for every table, see setSizes:
+		flags, the block and span sizes, the Huffman code and the pair tree
+	DTZ: the maps from stored values to distances
+	for every table: the sparse index, the block lengths, the blocks
+
+	Values are compressed by recursive pairing: a symbol is a value or a
+	pair of symbols. Symbols are written with a canonical Huffman code, longer
+	codes are lower numbers, in blocks of a fixed size.
+*/
+
+var (
+	wdlMagic = []byte{0x71, 0xe8, 0x23, 0x5d}
+	dtzMagic = []byte{0xd7, 0x66, 0x0c, 0xa5}
+)
+
+// file flags
+const (
+	splitFlag    = 1
+	hasPawnsFlag = 2
+)
+
+// table flags
+const (
+	stmFlag         = 1 // DTZ: black to move is stored
+	mappedFlag      = 2
+	winPliesFlag    = 4
+	lossPliesFlag   = 8
+	wideFlag        = 16
+	singleValueFlag = 128
+)
+
+// pairsData is one compressed table of a file: one side to move and, with
+// pawns, one file of the leading pawn
+type pairsData struct {
+	flags    byte
+	pieces   [TBPIECES]byte
+	groupLen [TBPIECES + 1]int
+	groupIdx [TBPIECES + 1]uint64
+
+	blockSize       int
+	span            uint64
+	sparseSize      int
+	numBlocks       int
+	blockLengthSize int
+	minSymLen       int // the value of a single value table
+	lowestSym       []uint16
+	base64          []uint64
+	symlen          []uint8 // values of a symbol - 1
+	btree           []byte  // 3 bytes per symbol: the left and right symbols, 12 bits each
+
+	sparseIndex []byte // a block and an offset for every span of values
+	blockLength []uint16
+	data        int64  // offset of the first block in the file
+	mapIdx      [4]int // DTZ: offsets in table.dtzMap of the map of every result
+}
+
+// table is a WDL or DTZ file
+type table struct {
+	name            string // the material of the file name, eg "KQvK"
+	dtz             bool
+	file            *os.File
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool // a piece other than a king that both sides don't have twice
+	pawnCount       [2]int
+	symmetric       bool // both sides have the same pieces
+	items           [2][4]*pairsData
+	dtzMap          []byte
+}
+
+// newTable sets up the table of a material, eg "KRPvKR"
+func newTable(name string, dtz bool) *table {
+	white, black, _ := strings.Cut(name, "v")
+	t := &table{name: name, dtz: dtz, pieceCount: len(white) + len(black), symmetric: white == black}
+	for _, letter := range "QRBNP" {
+		if strings.Count(white, string(letter)) == 1 || strings.Count(black, string(letter)) == 1 {
+			t.hasUniquePieces = true
+		}
+	}
+	whitePawns, blackPawns := strings.Count(white, "P"), strings.Count(black, "P")
+	t.hasPawns = whitePawns+blackPawns > 0
+	// the side with fewer pawns leads, it compresses better
+	t.pawnCount = [2]int{whitePawns, blackPawns}
+	if blackPawns > 0 && (whitePawns == 0 || blackPawns < whitePawns) {
+		t.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+	return t
+}
+
+// sides is the number of sides to move stored apart
+func (t *table) sides() int {
+	if !t.dtz && !t.symmetric {
+		return 2
+	}
+	return 1
+}
+
+func (t *table) files() int {
+	if t.hasPawns {
+		return 4
+	}
+	return 1
+}
+
+var errCorrupt = errors.New("corrupt table")
+
+// cursor reads numbers one after the other. Reading past the end sets err
+type cursor struct {
+	r    io.ReaderAt
+	size int64
+	off  int64
+	err  error
+}
+
+func (r *cursor) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.off+int64(n) > r.size {
+		r.err = errCorrupt
+		return make([]byte, max(n, 0))
+	}
+	b := make([]byte, n)
+	if _, err := r.r.ReadAt(b, r.off); err != nil {
+		r.err = err
+	}
+	r.off += int64(n)
+	return b
+}
+
+func (r *cursor) u8() int {
+	return int(r.bytes(1)[0])
+}
+
+func (r *cursor) u16() int {
+	return int(binary.LittleEndian.Uint16(r.bytes(2)))
+}
+
+func (r *cursor) u32() int {
+	return int(binary.LittleEndian.Uint32(r.bytes(4)))
+}
+
+// align skips to the next multiple of n
+func (r *cursor) align(n int64) {
+	r.off = (r.off + n - 1) / n * n
+}
+
+// openTable reads the header of a table file, the blocks are read when probed
+func openTable(path, name string, dtz bool) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t := newTable(name, dtz)
+	t.file = f
+	if err := t.read(&cursor{r: f, size: info.Size()}); err != nil {
+		f.Close()
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return t, nil
+}
+
+func (t *table) read(r *cursor) error {
+	magic := wdlMagic
+	if t.dtz {
+		magic = dtzMagic
+	}
+	if !bytes.Equal(r.bytes(4), magic) {
+		return errors.New("not a Syzygy table")
+	}
+	flags := r.u8()
+	if (flags&hasPawnsFlag != 0) != t.hasPawns || (flags&splitFlag != 0) == t.symmetric {
+		return errors.New("the table doesn't match its file name")
+	}
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	for file := 0; file < t.files(); file++ {
+		first, second := r.u8(), 0xff
+		if pp {
+			second = r.u8()
+		}
+		orders := [2][2]int{{first & 0xf, second & 0xf}, {first >> 4, second >> 4}}
+		pieces := r.bytes(t.pieceCount)
+		if t.pieceCount > TBPIECES {
+			return errCorrupt
+		}
+		for side := 0; side < t.sides(); side++ {
+			d := &pairsData{}
+			for i, piece := range pieces {
+				d.pieces[i] = piece & 0xf
+				if side == 1 {
+					d.pieces[i] = piece >> 4
+				}
+			}
+			t.setGroups(d, orders[side], file)
+			t.items[side][file] = d
+		}
+	}
+	r.align(2)
+
+	for file := 0; file < t.files(); file++ {
+		for side := 0; side < t.sides(); side++ {
+			t.items[side][file].setSizes(r)
+		}
+	}
+
+	if t.dtz {
+		start := r.off
+		for file := 0; file < t.files(); file++ {
+			d := t.items[0][file]
+			if d.flags&mappedFlag == 0 {
+				continue
+			}
+			for i := range d.mapIdx {
+				if d.flags&wideFlag != 0 {
+					r.align(2)
+					d.mapIdx[i] = int(r.off-start) + 2
+					r.off += 2 * int64(r.u16())
+				} else {
+					d.mapIdx[i] = int(r.off-start) + 1
+					r.off += int64(r.u8())
+				}
+			}
+		}
+		r.align(2)
+		end := r.off
+		r.off = start
+		t.dtzMap = r.bytes(int(end - start))
+	}
+
+	for file := 0; file < t.files(); file++ {
+		for side := 0; side < t.sides(); side++ {
+			d := t.items[side][file]
+			d.sparseIndex = r.bytes(6 * d.sparseSize)
+		}
+	}
+	for file := 0; file < t.files(); file++ {
+		for side := 0; side < t.sides(); side++ {
+			d := t.items[side][file]
+			lengths := r.bytes(2 * d.blockLengthSize)
+			d.blockLength = make([]uint16, d.blockLengthSize)
+			for i := range d.blockLength {
+				d.blockLength[i] = binary.LittleEndian.Uint16(lengths[2*i:])
+			}
+		}
+	}
+	for file := 0; file < t.files(); file++ {
+		for side := 0; side < t.sides(); side++ {
+			d := t.items[side][file]
+			r.align(64)
+			d.data = r.off
+			r.off += int64(d.numBlocks) * int64(d.blockSize)
+		}
+	}
+	if r.err == nil && r.off > r.size {
+		return errCorrupt
+	}
+	return r.err
+}
+
+// setSizes reads the sizes and the Huffman code of d
+func (d *pairsData) setSizes(r *cursor) {
+	d.flags = byte(r.u8())
+	if d.flags&singleValueFlag != 0 {
+		d.minSymLen = r.u8()
+		return
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	size := d.groupIdx[n]
+
+	d.blockSize = 1 << r.u8()
+	d.span = 1 << r.u8()
+	d.sparseSize = int((size + d.span - 1) / d.span)
+	padding := r.u8()
+	d.numBlocks = r.u32()
+	d.blockLengthSize = d.numBlocks + padding
+	maxSymLen := r.u8()
+	d.minSymLen = r.u8()
+	if d.minSymLen < 1 || maxSymLen < d.minSymLen || maxSymLen > 32 || d.blockSize < 8 || d.span < 2 {
+		r.err = errCorrupt
+		return
+	}
+
+	// base64[i] is the lowest code of length minSymLen+i, left aligned
+	lengths := maxSymLen - d.minSymLen + 1
+	lowest := r.bytes(2 * lengths)
+	d.lowestSym = make([]uint16, lengths)
+	for i := range d.lowestSym {
+		d.lowestSym[i] = binary.LittleEndian.Uint16(lowest[2*i:])
+	}
+	d.base64 = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSym[i]) - uint64(d.lowestSym[i+1])) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= 64 - i - d.minSymLen
+	}
+
+	symbols := r.u16()
+	d.btree = r.bytes(3 * symbols)
+	r.off += int64(symbols & 1)
+	d.symlen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for s := range d.symlen {
+		if !visited[s] && !d.setSymlen(s, visited) {
+			r.err = errCorrupt
+			return
+		}
+	}
+}
+
+func (d *pairsData) left(s int) int {
+	return int(d.btree[3*s+1]&0xf)<<8 | int(d.btree[3*s])
+}
+
+func (d *pairsData) right(s int) int {
+	return int(d.btree[3*s+2])<<4 | int(d.btree[3*s+1]>>4)
+}
+
+// setSymlen counts the values of symbol s, its children first. ok is false
+// if the tree points out of itself
+func (d *pairsData) setSymlen(s int, visited []bool) (ok bool) {
+	visited[s] = true
+	right := d.right(s)
+	if right == 0xfff {
+		d.symlen[s] = 0
+		return true
+	}
+	left := d.left(s)
+	if left >= len(d.symlen) || right >= len(d.symlen) {
+		return false
+	}
+	for _, child := range []int{left, right} {
+		if !visited[child] && !d.setSymlen(child, visited) {
+			return false
+		}
+	}
+	d.symlen[s] = d.symlen[left] + d.symlen[right] + 1
+	return true
+}
+
+// decompress returns the value at idx
+func (t *table) decompress(d *pairsData, idx uint64) (int, error) {
+	if d.flags&singleValueFlag != 0 {
+		return d.minSymLen, nil
+	}
+
+	// the sparse index gives the block and offset of the value in the middle
+	// of every span, walk the block lengths from there
+	k := int(idx / d.span)
+	if k >= d.sparseSize {
+		return 0, errCorrupt
+	}
+	block := int(binary.LittleEndian.Uint32(d.sparseIndex[6*k:]))
+	offset := int(binary.LittleEndian.Uint16(d.sparseIndex[6*k+4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+	for offset < 0 {
+		block--
+		if block < 0 {
+			return 0, errCorrupt
+		}
+		offset += int(d.blockLength[block]) + 1
+	}
+	for block < len(d.blockLength) && offset > int(d.blockLength[block]) {
+		offset -= int(d.blockLength[block]) + 1
+		block++
+	}
+	if block >= d.numBlocks {
+		return 0, errCorrupt
+	}
+
+	// the codes are read ahead of the one being decoded, up to 4 bytes past the block
+	data := make([]byte, d.blockSize+4)
+	if _, err := t.file.ReadAt(data[:d.blockSize], d.data+int64(block)*int64(d.blockSize)); err != nil {
+		return 0, err
+	}
+	buf64 := binary.BigEndian.Uint64(data)
+	next := 8
+	bufSize := 64
+	sym := 0
+	for {
+		length := 0
+		for buf64 < d.base64[length] {
+			length++
+		}
+		sym = int((buf64-d.base64[length])>>(64-length-d.minSymLen)) + int(d.lowestSym[length])
+		if sym >= len(d.symlen) {
+			return 0, errCorrupt
+		}
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int(d.symlen[sym]) + 1
+		length += d.minSymLen
+		buf64 <<= length
+		bufSize -= length
+		if bufSize <= 32 {
+			if next+4 > len(data) {
+				return 0, errCorrupt
+			}
+			bufSize += 32
+			buf64 |= uint64(binary.BigEndian.Uint32(data[next:])) << (64 - bufSize)
+			next += 4
+		}
+	}
+
+	// the value is in the pairs the symbol stands for
+	for d.symlen[sym] != 0 {
+		left := d.left(sym)
+		if offset < int(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym), nil
+}
+
+// wdlMap orders the results in the maps of DTZ tables
+var wdlMap = [5]int{LOSS + 2: 1, BLESSEDLOSS + 2: 3, DRAW + 2: 0, CURSEDWIN + 2: 2, WIN + 2: 0}
+
+// dtzScore turns a stored DTZ value into plies, for a position of result wdl
+func (t *table) dtzScore(file int, value int, wdl int) (int, error) {
+	d := t.items[0][file]
+	if d.flags&mappedFlag != 0 {
+		i := d.mapIdx[wdlMap[wdl+2]]
+		if d.flags&wideFlag != 0 {
+			i += 2 * value
+			if i+2 > len(t.dtzMap) {
+				return 0, errCorrupt
+			}
+			value = int(binary.LittleEndian.Uint16(t.dtzMap[i:]))
+		} else {
+			i += value
+			if i >= len(t.dtzMap) {
+				return 0, errCorrupt
+			}
+			value = int(t.dtzMap[i])
+		}
+	}
+	// stored in moves unless flagged
+	if (wdl == WIN && d.flags&winPliesFlag == 0) || (wdl == LOSS && d.flags&lossPliesFlag == 0) ||
+		wdl == CURSEDWIN || wdl == BLESSEDLOSS {
+		value *= 2
+	}
+	return value + 1, nil
+}
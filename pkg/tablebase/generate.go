@@ -0,0 +1,277 @@
+package tablebase
+
+import (
+	"math/bits"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+/*
+	Retrograde analysis
+
+	1. every legal position is expanded once with Chessboard.GetMoveList.
+	   Checkmates are lost in 0 plies, stalemates are draws. Captures and
+	   promotions lead to smaller tables (generated first, recursively) and
+	   give the position a known way to win or lose. Moves that stay in the
+	   table are counted.
+	2. positions are then resolved ply by ply. When a position is lost in n,
+	   every position that can move into it is won in n+1. When a position is
+	   won, every position that can move into it has one less move left to
+	   refute; once none are left it is lost.
+	3. whatever is not resolved at the end is a draw.
+
+	Predecessors are found by un-moving the pieces of the side that just moved.
+	Positions in the table never have castling rights or an en passant square.
+*/
+
+const (
+	unknown  = 0xfe
+	draw     = 0
+	notFound = -1
+)
+
+type generator struct {
+	tb     *Tablebase
+	pieces []int
+
+	values   []uint8 // plies+1, 0 for draws, INVALID for illegal positions
+	count    []uint8 // moves inside the table not yet known to lose
+	lossIn   []uint8 // longest loss through moves to other tables, plies+1
+	notLost  []bool  // a move to another table draws or wins
+	schedule map[int][]int32
+}
+
+func (tb *Tablebase) generate(signature string) (*Table, error) {
+	pieces := signaturePieces(signature)
+	size := tableSize(pieces)
+	g := &generator{
+		tb:       tb,
+		pieces:   pieces,
+		values:   make([]uint8, size),
+		count:    make([]uint8, size),
+		lossIn:   make([]uint8, size),
+		notLost:  make([]bool, size),
+		schedule: map[int][]int32{},
+	}
+
+	if err := g.expand(); err != nil {
+		return nil, err
+	}
+	g.resolve()
+
+	for i, value := range g.values {
+		if value == unknown {
+			g.values[i] = draw
+		}
+	}
+	return &Table{Signature: signature, pieces: pieces, values: g.values}, nil
+}
+
+func (g *generator) scheduleAt(plies int, index int) {
+	g.schedule[plies] = append(g.schedule[plies], int32(index))
+}
+
+// expand does step 1
+func (g *generator) expand() error {
+	for index := range g.values {
+		c, ok := indexBoard(index, g.pieces)
+		if !ok || !legal(&c) {
+			g.values[index] = INVALID
+			continue
+		}
+		g.values[index] = unknown
+
+		moves := c.GetMoveList()
+		if len(moves) == 0 {
+			if c.InCheck() {
+				g.scheduleAt(0, index)
+			} else {
+				g.values[index] = draw
+			}
+			continue
+		}
+
+		winIn := notFound
+		for _, move := range moves {
+			next, leavesTable := applyMove(c, move.String())
+			if !leavesTable {
+				g.count[index]++
+				continue
+			}
+
+			result, err := g.tb.Probe(&next)
+			if err != nil {
+				return err
+			}
+			switch result.WDL {
+			case DRAW:
+				g.notLost[index] = true
+			case LOSS:
+				g.notLost[index] = true
+				if winIn == notFound || result.Plies+1 < winIn {
+					winIn = result.Plies + 1
+				}
+			case WIN:
+				g.lossIn[index] = max(g.lossIn[index], uint8(result.Plies+2))
+			}
+		}
+
+		switch {
+		case winIn != notFound:
+			g.scheduleAt(winIn, index)
+		case g.count[index] == 0 && !g.notLost[index]:
+			// every move leaves the table and loses
+			g.scheduleAt(int(g.lossIn[index])-1, index)
+		}
+	}
+	return nil
+}
+
+// resolve does step 2
+func (g *generator) resolve() {
+	for plies := 0; len(g.schedule) > 0; plies++ {
+		for len(g.schedule[plies]) > 0 {
+			// resolving can schedule more positions at the same ply
+			current := g.schedule[plies]
+			g.schedule[plies] = nil
+			for _, index := range current {
+				if g.values[index] != unknown {
+					continue
+				}
+				g.values[index] = uint8(plies + 1)
+				g.propagate(int(index), plies)
+			}
+		}
+		delete(g.schedule, plies)
+	}
+}
+
+func (g *generator) propagate(index int, plies int) {
+	c, _ := indexBoard(int(index), g.pieces)
+	lost := plies%2 == 0
+	for _, previous := range g.predecessors(&c) {
+		if g.values[previous] != unknown {
+			continue
+		}
+		if lost {
+			g.scheduleAt(plies+1, previous)
+			continue
+		}
+		g.count[previous]--
+		if g.count[previous] == 0 && !g.notLost[previous] {
+			g.scheduleAt(max(plies+1, int(g.lossIn[previous])-1), previous)
+		}
+	}
+}
+
+var (
+	knightOffsets = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingOffsets   = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	rookOffsets   = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopOffsets = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// predecessors returns the indexes of the legal positions that reach c with
+// a move that doesn't capture or promote
+func (g *generator) predecessors(c *chessboard.Chessboard) []int {
+	var indexes []int
+	occupied := uint64(0)
+	for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+		occupied |= c.BoardState[piece]
+	}
+
+	// the side that just moved is the one not to move
+	moved := []int{chessboard.BKING, chessboard.BQUEEN, chessboard.BROOK, chessboard.BBISHOP, chessboard.BKNIGHT, chessboard.BPAWN}
+	pawnStep, doubleStepRow := -1, 4
+	if !c.WhiteToMove {
+		moved = []int{chessboard.WKING, chessboard.WQUEEN, chessboard.WROOK, chessboard.WBISHOP, chessboard.WKNIGHT, chessboard.WPAWN}
+		pawnStep, doubleStepRow = 1, 3
+	}
+
+	unmove := func(piece, from, to int) {
+		previous := *c
+		previous.BoardState[piece] ^= 1<<from | 1<<to
+		previous.WhiteToMove = !c.WhiteToMove
+		if legal(&previous) {
+			indexes = append(indexes, boardIndex(&previous, g.pieces))
+		}
+	}
+
+	for _, piece := range moved {
+		for bitboard := c.BoardState[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			to := bits.TrailingZeros64(bitboard)
+			col, row := to%8, to/8
+
+			var offsets [][2]int
+			slides := false
+			switch piece {
+			case chessboard.WKING, chessboard.BKING:
+				offsets = kingOffsets
+			case chessboard.WKNIGHT, chessboard.BKNIGHT:
+				offsets = knightOffsets
+			case chessboard.WROOK, chessboard.BROOK:
+				offsets, slides = rookOffsets, true
+			case chessboard.WBISHOP, chessboard.BBISHOP:
+				offsets, slides = bishopOffsets, true
+			case chessboard.WQUEEN, chessboard.BQUEEN:
+				offsets, slides = append(append([][2]int{}, rookOffsets...), bishopOffsets...), true
+			case chessboard.WPAWN, chessboard.BPAWN:
+				// pawns move forward, so they come from behind
+				back := to - 8*pawnStep
+				if back < 8 || back >= 56 || occupied&(1<<back) != 0 {
+					continue
+				}
+				unmove(piece, back, to)
+				if row == doubleStepRow {
+					if twoBack := back - 8*pawnStep; occupied&(1<<twoBack) == 0 {
+						unmove(piece, twoBack, to)
+					}
+				}
+				continue
+			}
+
+			for _, offset := range offsets {
+				for fromCol, fromRow := col+offset[0], row+offset[1]; fromCol >= 0 && fromCol < 8 && fromRow >= 0 && fromRow < 8; fromCol, fromRow = fromCol+offset[0], fromRow+offset[1] {
+					from := 8*fromRow + fromCol
+					if occupied&(1<<from) != 0 {
+						break
+					}
+					unmove(piece, from, to)
+					if !slides {
+						break
+					}
+				}
+			}
+		}
+	}
+	return indexes
+}
+
+// applyMove plays a UCI move on a board without castling rights or en
+// passant square. leavesTable is true for captures and promotions
+func applyMove(c chessboard.Chessboard, uci string) (next chessboard.Chessboard, leavesTable bool) {
+	from := int(uci[1]-'1')*8 + int(uci[0]-'a')
+	to := int(uci[3]-'1')*8 + int(uci[2]-'a')
+	moving := 0
+	for piece := chessboard.WKING; piece <= chessboard.BPAWN; piece++ {
+		if c.BoardState[piece]&(1<<to) != 0 {
+			c.BoardState[piece] &^= 1 << to
+			leavesTable = true
+		}
+		if c.BoardState[piece]&(1<<from) != 0 {
+			moving = piece
+		}
+	}
+	c.BoardState[moving] ^= 1<<from | 1<<to
+	if len(uci) > 4 {
+		promotion := letterToPiece[uci[4]-'a'+'A'][0]
+		if !c.WhiteToMove {
+			promotion = letterToPiece[uci[4]-'a'+'A'][1]
+		}
+		c.BoardState[moving] &^= 1 << to
+		c.BoardState[promotion] |= 1 << to
+		leavesTable = true
+	}
+	c.WhiteToMove = !c.WhiteToMove
+	return c, leavesTable
+}
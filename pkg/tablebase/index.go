@@ -0,0 +1,153 @@
+package tablebase
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// order of the pieces after the king in a signature
+const pieceOrder = "QRBNP"
+
+var letterToPiece = map[byte][2]int{
+	'K': {chessboard.WKING, chessboard.BKING},
+	'Q': {chessboard.WQUEEN, chessboard.BQUEEN},
+	'R': {chessboard.WROOK, chessboard.BROOK},
+	'B': {chessboard.WBISHOP, chessboard.BBISHOP},
+	'N': {chessboard.WKNIGHT, chessboard.BKNIGHT},
+	'P': {chessboard.WPAWN, chessboard.BPAWN},
+}
+
+var pieceToLetter = map[int]byte{}
+
+func init() {
+	for letter, pieces := range letterToPiece {
+		pieceToLetter[pieces[0]] = letter
+		pieceToLetter[pieces[1]] = letter
+	}
+}
+
+// Signature names the material of a position, white first: "KQvK", "KBNvK", "KvKP"
+func Signature(c *chessboard.Chessboard) string {
+	white, black := "", ""
+	for _, letter := range "K" + pieceOrder {
+		pieces := letterToPiece[byte(letter)]
+		white += strings.Repeat(string(letter), bits.OnesCount64(c.BoardState[pieces[0]]))
+		black += strings.Repeat(string(letter), bits.OnesCount64(c.BoardState[pieces[1]]))
+	}
+	return white + "v" + black
+}
+
+// NormalizeSignature accepts signatures without the "v", like "KQK", and sorts the pieces
+func NormalizeSignature(signature string) (string, error) {
+	signature = strings.ToUpper(signature)
+	white, black, found := strings.Cut(signature, "V")
+	if !found {
+		// "KQK": the second king starts black's pieces
+		second := strings.IndexByte(signature[min(1, len(signature)):], 'K') + 1
+		if second <= 0 {
+			return "", errors.New("signature needs two kings: " + signature)
+		}
+		white, black = signature[:second], signature[second:]
+	}
+
+	sortSide := func(side string) (string, error) {
+		if strings.Count(side, "K") != 1 {
+			return "", errors.New("each side needs exactly one king: " + signature)
+		}
+		letters := []byte(strings.Replace(side, "K", "", 1))
+		for _, letter := range letters {
+			if strings.IndexByte(pieceOrder, letter) == -1 {
+				return "", errors.New("unknown piece in signature: " + signature)
+			}
+		}
+		sort.Slice(letters, func(i, j int) bool {
+			return strings.IndexByte(pieceOrder, letters[i]) < strings.IndexByte(pieceOrder, letters[j])
+		})
+		return "K" + string(letters), nil
+	}
+
+	white, err := sortSide(white)
+	if err != nil {
+		return "", err
+	}
+	black, err = sortSide(black)
+	if err != nil {
+		return "", err
+	}
+	if len(white)+len(black) > MAXPIECES {
+		return "", errors.New("too many pieces for a table: " + signature)
+	}
+	return white + "v" + black, nil
+}
+
+// pieces of a signature in index order: white king and pieces, then black's
+func signaturePieces(signature string) []int {
+	var pieces []int
+	color := 0
+	for i := 0; i < len(signature); i++ {
+		if signature[i] == 'v' {
+			color = 1
+			continue
+		}
+		pieces = append(pieces, letterToPiece[signature[i]][color])
+	}
+	return pieces
+}
+
+func tableSize(pieces []int) int {
+	return 2 << (6 * len(pieces))
+}
+
+/*
+	index layout, most significant first:
+	side to move (1 for white), then the square of every piece in signature order.
+	squares are numbered like the BoardState bits, 8*row + col
+*/
+
+// boardIndex returns the index of c in the table of pieces. Pieces of the
+// same kind take their squares in increasing order
+func boardIndex(c *chessboard.Chessboard, pieces []int) int {
+	index := 0
+	if c.WhiteToMove {
+		index = 1
+	}
+	for i := 0; i < len(pieces); {
+		bitboard := c.BoardState[pieces[i]]
+		for ; i < len(pieces) && bitboard != 0; i++ {
+			index = index<<6 | bits.TrailingZeros64(bitboard)
+			bitboard &= bitboard - 1
+		}
+	}
+	return index
+}
+
+// indexBoard builds the board of index. ok is false if two pieces share a
+// square or a pawn is on the first or last row
+func indexBoard(index int, pieces []int) (c chessboard.Chessboard, ok bool) {
+	c.FullmoveCounter = 1
+	occupied := uint64(0)
+	for i := len(pieces) - 1; i >= 0; i-- {
+		square := index & 63
+		index >>= 6
+		bit := uint64(1) << square
+		if occupied&bit != 0 {
+			return c, false
+		}
+		if (pieces[i] == chessboard.WPAWN || pieces[i] == chessboard.BPAWN) && (square < 8 || square >= 56) {
+			return c, false
+		}
+		occupied |= bit
+		c.BoardState[pieces[i]] |= bit
+	}
+	c.WhiteToMove = index == 1
+	return c, true
+}
+
+// legal reports if the side that just moved is not left in check
+func legal(c *chessboard.Chessboard) bool {
+	return !c.SquareIsThreatened(c.WhiteToMove, c.GetKingPosition(!c.WhiteToMove))
+}
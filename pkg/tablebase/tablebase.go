@@ -0,0 +1,232 @@
+package tablebase
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+// MAXPIECES is the largest number of pieces, kings included, a table can have
+const MAXPIECES = 4
+
+// INVALID marks illegal positions in a table
+const INVALID = 0xff
+
+// Results of a position for the side to move
+const (
+	LOSS = iota - 1
+	DRAW
+	WIN
+)
+
+// Result of a position for the side to move. Plies is the distance to mate
+// with best play, 0 if the side to move is already mated, unused for draws
+type Result struct {
+	WDL   int
+	Plies int
+}
+
+// Table holds the distance to mate of every position with one material signature
+type Table struct {
+	Signature string
+	pieces    []int
+	values    []uint8 // plies+1, 0 for draws, INVALID for illegal positions
+}
+
+// Tablebase finds tables by signature. Tables are loaded from Dir, or
+// generated and saved there if they are not in it yet. Generating a table
+// generates the smaller tables it depends on. Three piece tables take
+// seconds, four piece ones minutes.
+// A Tablebase is not safe for concurrent use.
+type Tablebase struct {
+	Dir    string // empty to keep tables in memory only
+	tables map[string]*Table
+}
+
+func New(dir string) *Tablebase {
+	return &Tablebase{Dir: dir, tables: map[string]*Table{}}
+}
+
+// Generate makes sure the table of signature, "KBNK" or "KBNvK", exists
+func (tb *Tablebase) Generate(signature string) (*Table, error) {
+	signature, err := NormalizeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	return tb.table(signature)
+}
+
+func (tb *Tablebase) table(signature string) (*Table, error) {
+	if table, ok := tb.tables[signature]; ok {
+		return table, nil
+	}
+
+	if tb.Dir != "" {
+		table, err := Load(filepath.Join(tb.Dir, signature+fileExtension))
+		if err == nil {
+			tb.tables[signature] = table
+			return table, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	table, err := tb.generate(signature)
+	if err != nil {
+		return nil, err
+	}
+	tb.tables[signature] = table
+	if tb.Dir != "" {
+		if err := table.Save(filepath.Join(tb.Dir, signature+fileExtension)); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// Probe looks up the position on c. Castling rights and en passant are ignored
+func (tb *Tablebase) Probe(c *chessboard.Chessboard) (Result, error) {
+	signature := Signature(c)
+	if len(signature)-1 > MAXPIECES {
+		return Result{}, errors.New("too many pieces to probe: " + signature)
+	}
+	table, err := tb.table(signature)
+	if err != nil {
+		return Result{}, err
+	}
+	return table.Probe(c)
+}
+
+func (t *Table) Probe(c *chessboard.Chessboard) (Result, error) {
+	if Signature(c) != t.Signature {
+		return Result{}, errors.New("position does not belong to table " + t.Signature)
+	}
+	value := t.values[boardIndex(c, t.pieces)]
+	switch {
+	case value == INVALID:
+		return Result{}, errors.New("illegal position")
+	case value == draw:
+		return Result{WDL: DRAW}, nil
+	case (value-1)%2 == 0:
+		return Result{WDL: LOSS, Plies: int(value - 1)}, nil
+	default:
+		return Result{WDL: WIN, Plies: int(value - 1)}, nil
+	}
+}
+
+// BestMove returns the move, in UCI notation, that wins fastest, draws, or
+// loses slowest, and the result after it. It returns an error if there are
+// no moves
+func (tb *Tablebase) BestMove(c *chessboard.Chessboard) (move string, result Result, err error) {
+	var best Result
+	found := false
+	for _, m := range c.GetMoveList() {
+		next := *c
+		next.Moves, next.Clock = nil, nil
+		if err := next.MakeUCIMove(m.String()); err != nil {
+			return "", Result{}, err
+		}
+		child, err := tb.Probe(&next)
+		if err != nil {
+			return "", Result{}, err
+		}
+		// the child's result is from the opponent's point of view
+		mine := Result{WDL: -child.WDL, Plies: child.Plies + 1}
+		if mine.WDL == DRAW {
+			mine.Plies = 0
+		}
+		if !found || better(mine, best) {
+			move, best, found = m.String(), mine, true
+		}
+	}
+	if !found {
+		return "", Result{}, errors.New("no legal moves")
+	}
+	return move, best, nil
+}
+
+func better(a, b Result) bool {
+	if a.WDL != b.WDL {
+		return a.WDL > b.WDL
+	}
+	if a.WDL == WIN {
+		return a.Plies < b.Plies
+	}
+	return a.Plies > b.Plies
+}
+
+/*
+	file format:
+	magic "CBDTM1"
+	1 byte signature length, signature
+	gzip stream with one byte per index, same values as Table.values
+*/
+
+const (
+	fileMagic     = "CBDTM1"
+	fileExtension = ".dtm"
+)
+
+func (t *Table) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(f)
+	writer.WriteString(fileMagic)
+	writer.WriteByte(byte(len(t.Signature)))
+	writer.WriteString(t.Signature)
+	compressed := gzip.NewWriter(writer)
+	if _, err := compressed.Write(t.values); err != nil {
+		f.Close()
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+
+	header := make([]byte, len(fileMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:len(fileMagic)]) != fileMagic {
+		return nil, errors.New("not a table file: " + path)
+	}
+	signature := make([]byte, header[len(fileMagic)])
+	if _, err := io.ReadFull(reader, signature); err != nil {
+		return nil, errors.New("not a table file: " + path)
+	}
+	normalized, err := NormalizeSignature(string(signature))
+	if err != nil || normalized != string(signature) {
+		return nil, errors.New("bad signature in table file: " + path)
+	}
+
+	table := &Table{Signature: normalized, pieces: signaturePieces(normalized)}
+	table.values = make([]uint8, tableSize(table.pieces))
+	compressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(compressed, table.values); err != nil {
+		return nil, errors.New("truncated table file: " + path)
+	}
+	return table, nil
+}
@@ -0,0 +1,100 @@
+package tablebase
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kahnaisehC/chessboard"
+)
+
+func longestWin(t *Table) int {
+	longest := 0
+	for _, value := range t.values {
+		if value != INVALID && value != draw && (value-1)%2 == 1 {
+			longest = max(longest, int(value-1))
+		}
+	}
+	return longest
+}
+
+func TestGenerate(t *testing.T) {
+	tb := New("")
+	// longest mates with best play: KQK mates in 10 moves, KRK in 16
+	for signature, plies := range map[string]int{"KQK": 19, "KRK": 31} {
+		table, err := tb.Generate(signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := longestWin(table); got != plies {
+			t.Errorf("longest win in %s = %d plies, should be %d", signature, got, plies)
+		}
+	}
+
+	tests := []struct {
+		FEN    string
+		result Result
+	}{
+		{"7k/8/6K1/8/8/8/Q7/8 w - - 0 1", Result{WDL: WIN, Plies: 1}},
+		{"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", Result{WDL: LOSS, Plies: 0}},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", Result{WDL: DRAW}},
+		// the defending king takes the undefended queen
+		{"8/8/8/8/8/8/1Q6/k6K b - - 0 1", Result{WDL: DRAW}},
+		// KPK: the king on the sixth row in front of the pawn wins, unless it is a rook pawn
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Result{WDL: WIN, Plies: 0}},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", Result{WDL: LOSS, Plies: 0}},
+		{"k7/8/K7/P7/8/8/8/8 w - - 0 1", Result{WDL: DRAW}},
+	}
+	for _, test := range tests {
+		c := chessboard.CreateChessboard(test.FEN)
+		result, err := tb.Probe(&c)
+		if err != nil {
+			t.Fatalf("Probe(%s): %v", test.FEN, err)
+		}
+		if test.result.WDL != DRAW && test.result.Plies == 0 {
+			// only the result is known for sure
+			result.Plies = 0
+		}
+		if result != test.result {
+			t.Errorf("Probe(%s) = %v, should equal %v", test.FEN, result, test.result)
+		}
+	}
+
+	c := chessboard.CreateChessboard("7k/8/6K1/8/8/8/Q7/8 w - - 0 1")
+	if move, _, _ := tb.BestMove(&c); move != "a2a8" {
+		t.Errorf("BestMove(%s) = %s, should mate", c.GetFEN(), move)
+	}
+	c = chessboard.CreateChessboard("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	if _, _, err := tb.BestMove(&c); err == nil {
+		t.Errorf("BestMove(%s) of a stalemate should fail", c.GetFEN())
+	}
+}
+
+func TestGenerateKBNK(t *testing.T) {
+	if testing.Short() {
+		t.Skip("KBNK takes minutes to generate")
+	}
+	// the longest mate with a bishop and a knight is 33 moves
+	table, err := New("").Generate("KBNK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := longestWin(table); got != 65 {
+		t.Errorf("longest win in KBNK = %d plies, should be 65", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	table, err := New(dir).Generate("KQvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(filepath.Join(dir, "KQvK"+fileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Signature != "KQvK" || string(loaded.values) != string(table.values) {
+		t.Errorf("loaded table does not match the generated one")
+	}
+}